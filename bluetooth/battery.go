@@ -0,0 +1,55 @@
+package bluetooth
+
+// BatteryPoint is a point of a discharge curve: the battery voltage in millivolts and the remaining capacity.
+type BatteryPoint struct {
+	MilliVolts uint16
+	Percent    int
+}
+
+// BatteryModel is implemented by decoders of sensors that report the battery voltage but no percentage. The
+// curve converts the voltage into the remaining capacity.
+type BatteryModel interface {
+
+	// BatteryCurve returns the discharge curve ordered by descending voltage.
+	BatteryCurve() []BatteryPoint
+}
+
+// coinCellCurve is the discharge curve of a 3V lithium coin cell (CR2032, CR2477). The voltage stays almost flat
+// for most of the capacity and drops quickly at the end.
+var coinCellCurve = []BatteryPoint{
+	{MilliVolts: 3000, Percent: 100},
+	{MilliVolts: 2900, Percent: 80},
+	{MilliVolts: 2800, Percent: 60},
+	{MilliVolts: 2700, Percent: 40},
+	{MilliVolts: 2600, Percent: 20},
+	{MilliVolts: 2500, Percent: 10},
+	{MilliVolts: 2200, Percent: 0},
+}
+
+// batteryPercent interpolates the remaining capacity of the voltage linearly between the points of the curve.
+// Voltages above the first point are 100%, voltages below the last point 0%.
+func batteryPercent(milliVolts uint16, curve []BatteryPoint) int {
+	if len(curve) == 0 {
+		return 0
+	}
+	if milliVolts >= curve[0].MilliVolts {
+		return curve[0].Percent
+	}
+	for i := 1; i < len(curve); i++ {
+		upper, lower := curve[i-1], curve[i]
+		if milliVolts >= lower.MilliVolts {
+			share := float64(milliVolts-lower.MilliVolts) / float64(upper.MilliVolts-lower.MilliVolts)
+			return lower.Percent + int(share*float64(upper.Percent-lower.Percent)+0.5)
+		}
+	}
+	return curve[len(curve)-1].Percent
+}
+
+// withBatteryPercent adds the battery percentage to a reading with a voltage but no percentage, if the decoder
+// has a battery model.
+func withBatteryPercent(reading Reading, decoder Decoder) Reading {
+	if model, ok := decoder.(BatteryModel); ok && reading.BatteryPercent == 0 && reading.BatteryMV > 0 {
+		reading.BatteryPercent = batteryPercent(reading.BatteryMV, model.BatteryCurve())
+	}
+	return reading
+}
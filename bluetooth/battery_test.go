@@ -0,0 +1,50 @@
+package bluetooth
+
+import "testing"
+
+func TestBatteryPercent(t *testing.T) {
+	tests := []struct {
+		name       string
+		milliVolts uint16
+		expected   int
+	}{
+		{name: "AboveCurve", milliVolts: 3200, expected: 100},
+		{name: "FirstPoint", milliVolts: 3000, expected: 100},
+		{name: "BetweenPoints", milliVolts: 2950, expected: 90},
+		{name: "Rounded", milliVolts: 2633, expected: 27},
+		{name: "SteepEnd", milliVolts: 2350, expected: 5},
+		{name: "LastPoint", milliVolts: 2200, expected: 0},
+		{name: "BelowCurve", milliVolts: 1900, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := batteryPercent(tt.milliVolts, coinCellCurve); got != tt.expected {
+				t.Errorf("expected %d%% for %dmV, got %d%%", tt.expected, tt.milliVolts, got)
+			}
+		})
+	}
+}
+
+func TestWithBatteryPercent(t *testing.T) {
+	tests := []struct {
+		name     string
+		reading  Reading
+		decoder  Decoder
+		expected int
+	}{
+		{name: "Voltage", reading: Reading{BatteryMV: 2800}, decoder: ws02Decoder{}, expected: 60},
+		{name: "ReportedPercent", reading: Reading{BatteryMV: 2800, BatteryPercent: 85}, decoder: ruuviDecoder{},
+			expected: 85},
+		{name: "NoVoltage", reading: Reading{}, decoder: ws02Decoder{}, expected: 0},
+		{name: "NoBatteryModel", reading: Reading{BatteryMV: 2800}, decoder: goveeDecoder{}, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withBatteryPercent(tt.reading, tt.decoder); got.BatteryPercent != tt.expected {
+				t.Errorf("expected %d%%, got %d%%", tt.expected, got.BatteryPercent)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package bluetooth
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// btHomeUUID is the 16-bit UUID of the BTHome service data.
+const btHomeUUID = 0xFCD2
+
+// btHomeObjectSizes maps the object IDs of BTHome v2 to the size of their value in bytes. It is needed to skip
+// the objects that aren't used. The binary sensors 0x0F-0x11 and 0x15-0x2D, e.g. battery low, door and motion,
+// have one byte.
+var btHomeObjectSizes = map[byte]int{
+	0x00: 1, 0x01: 1, 0x02: 2, 0x03: 2, 0x04: 3, 0x05: 3, 0x06: 2, 0x07: 2, 0x08: 2, 0x09: 1, 0x0A: 3, 0x0B: 3,
+	0x0C: 2, 0x0D: 2, 0x0E: 2, 0x0F: 1, 0x10: 1, 0x11: 1, 0x12: 2, 0x13: 2, 0x14: 2, 0x15: 1, 0x16: 1, 0x17: 1,
+	0x18: 1, 0x19: 1, 0x1A: 1, 0x1B: 1, 0x1C: 1, 0x1D: 1, 0x1E: 1, 0x1F: 1, 0x20: 1, 0x21: 1, 0x22: 1, 0x23: 1,
+	0x24: 1, 0x25: 1, 0x26: 1, 0x27: 1, 0x28: 1, 0x29: 1, 0x2A: 1, 0x2B: 1, 0x2C: 1, 0x2D: 1, 0x2E: 1, 0x2F: 1,
+	0x3A: 1, 0x3C: 2, 0x3D: 2, 0x3E: 4, 0x3F: 2, 0x40: 2, 0x41: 2, 0x42: 3, 0x43: 2, 0x44: 2, 0x45: 2, 0x46: 1,
+	0x47: 2, 0x48: 2, 0x49: 2, 0x4A: 2, 0x4B: 3, 0x4C: 4, 0x4D: 4, 0x4E: 4, 0x4F: 4, 0x50: 4, 0x51: 2, 0x52: 2,
+}
+
+// btHomeDecoder decodes unencrypted BTHome v2 advertisements. The payload doesn't contain the MAC address, so
+// the address of the sender is used.
+type btHomeDecoder struct{}
+
+func (btHomeDecoder) Name() string {
+	return "BTHome"
+}
+
+func (btHomeDecoder) Match() Match {
+	return Match{ServiceUUID: btHomeUUID}
+}
+
+func (btHomeDecoder) Decode(adv Advertisement) (Reading, error) {
+	data := adv.serviceData(btHomeUUID)
+	if len(data) == 0 {
+		return Reading{}, fmt.Errorf("empty service data")
+	}
+	// the device information byte holds the version in the upper 3 bits and the encryption flag in bit 0
+	if version := data[0] >> 5; version != 2 {
+		return Reading{}, fmt.Errorf("unsupported BTHome version %d", version)
+	}
+	if data[0]&0x01 != 0 {
+		return Reading{}, fmt.Errorf("encrypted advertisements are not supported")
+	}
+	reading := Reading{MacAddress: adv.Address}
+	hasTemperature, hasHumidity := false, false
+	for i := 1; i < len(data); {
+		id := data[i]
+		size, ok := btHomeObjectSizes[id]
+		if !ok {
+			if !hasTemperature || !hasHumidity {
+				return Reading{}, fmt.Errorf("unknown object id 0x%02X", id)
+			}
+			// the objects are sorted by id, so the remaining ones aren't needed
+			break
+		}
+		if i+1+size > len(data) {
+			return Reading{}, fmt.Errorf("object id 0x%02X is truncated", id)
+		}
+		value := data[i+1 : i+1+size]
+		switch id {
+		case 0x01:
+			reading.BatteryPercent = int(value[0])
+		case 0x02:
+			reading.Temperature = float64(int16(binary.LittleEndian.Uint16(value))) / 100
+			hasTemperature = true
+		case 0x03:
+			reading.Humidity = float64(binary.LittleEndian.Uint16(value)) / 100
+			hasHumidity = true
+		case 0x0C:
+			reading.BatteryMV = binary.LittleEndian.Uint16(value)
+		case 0x2E:
+			reading.Humidity = float64(value[0])
+			hasHumidity = true
+		case 0x45:
+			reading.Temperature = float64(int16(binary.LittleEndian.Uint16(value))) / 10
+			hasTemperature = true
+		}
+		i += 1 + size
+	}
+	if !hasTemperature || !hasHumidity {
+		return Reading{}, fmt.Errorf("no temperature and humidity")
+	}
+	return reading, nil
+}
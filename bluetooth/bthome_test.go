@@ -0,0 +1,86 @@
+package bluetooth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBTHomeDecoder(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     string
+		expected    Reading
+		expectError bool
+	}{
+		{
+			name:    "TemperatureAndHumidity",
+			payload: "4000010161 02ca09 03bf13 0cb80b",
+			expected: Reading{
+				MacAddress:     "A4:C1:38:8F:1A:2B",
+				Temperature:    25.06,
+				Humidity:       50.55,
+				BatteryPercent: 97,
+				BatteryMV:      3000,
+			},
+		},
+		{
+			name:    "LowResolutionValues",
+			payload: "40 2e37 45e2ff",
+			expected: Reading{
+				MacAddress:  "A4:C1:38:8F:1A:2B",
+				Temperature: -3,
+				Humidity:    55,
+			},
+		},
+		{
+			// battery low (0x15) and window (0x2D) are binary sensors before the humidity
+			name:    "BinaryObjectsBeforeHumidity",
+			payload: "40 02ca09 1501 2d00 2e37",
+			expected: Reading{
+				MacAddress:  "A4:C1:38:8F:1A:2B",
+				Temperature: 25.06,
+				Humidity:    55,
+			},
+		},
+		{
+			name:    "UnknownObjectAfterValues",
+			payload: "40 02ca09 03bf13 f001",
+			expected: Reading{
+				MacAddress:  "A4:C1:38:8F:1A:2B",
+				Temperature: 25.06,
+				Humidity:    50.55,
+			},
+		},
+		{
+			name:        "Encrypted",
+			payload:     "410102ca09",
+			expectError: true,
+		},
+		{
+			name:        "Version1",
+			payload:     "2002ca09",
+			expectError: true,
+		},
+		{
+			name:        "NoHumidity",
+			payload:     "40 0161 02ca09",
+			expectError: true,
+		},
+		{
+			name:        "Truncated",
+			payload:     "40 02ca09 03bf",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adv := Advertisement{
+				Address:     "A4:C1:38:8F:1A:2B",
+				ServiceData: []ServiceData{{UUID: 0xFCD2, Data: fromHex(t, strings.ReplaceAll(tt.payload, " ", ""))}},
+			}
+			got, err := btHomeDecoder{}.Decode(adv)
+			checkReading(t, got, tt.expected, err, tt.expectError)
+		})
+	}
+}
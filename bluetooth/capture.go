@@ -0,0 +1,165 @@
+package bluetooth
+
+import (
+	"bufio"
+	"dpf-bt/sensor"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	bt "tinygo.org/x/bluetooth"
+)
+
+// capturedAdvertisement is a line of a capture file. The data is stored in hex.
+type capturedAdvertisement struct {
+	Time             time.Time                  `json:"time"`
+	Address          string                     `json:"mac"`
+	LocalName        string                     `json:"name,omitempty"`
+	RSSI             int16                      `json:"rssi"`
+	ManufacturerData []capturedManufacturerData `json:"manufacturer_data,omitempty"`
+	ServiceData      []capturedServiceData      `json:"service_data,omitempty"`
+}
+
+// capturedManufacturerData is a manufacturer specific data element of a captured advertisement.
+type capturedManufacturerData struct {
+	CompanyID uint16 `json:"company_id"`
+	Data      string `json:"data"`
+}
+
+// capturedServiceData is a service data element of a captured advertisement.
+type capturedServiceData struct {
+	UUID uint16 `json:"uuid"`
+	Data string `json:"data"`
+}
+
+// Recorder writes the received advertisements to a JSONL file, one advertisement per line, so that they can be
+// replayed on a machine without Bluetooth.
+type Recorder struct {
+	encoder *json.Encoder
+}
+
+// NewRecorder creates a recorder that writes to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{encoder: json.NewEncoder(w)}
+}
+
+// RecordScanResult writes the advertisement of the Bluetooth stack with the current time.
+func (r *Recorder) RecordScanResult(scanResult bt.ScanResult) error {
+	return r.record(newAdvertisement(scanResult), time.Now())
+}
+
+// record writes the advertisement with the given time.
+func (r *Recorder) record(adv Advertisement, received time.Time) error {
+	captured := capturedAdvertisement{
+		Time:      received,
+		Address:   adv.Address,
+		LocalName: adv.LocalName,
+		RSSI:      adv.RSSI,
+	}
+	for _, md := range adv.ManufacturerData {
+		captured.ManufacturerData = append(captured.ManufacturerData,
+			capturedManufacturerData{CompanyID: md.CompanyID, Data: hex.EncodeToString(md.Data)})
+	}
+	for _, sd := range adv.ServiceData {
+		captured.ServiceData = append(captured.ServiceData,
+			capturedServiceData{UUID: sd.UUID, Data: hex.EncodeToString(sd.Data)})
+	}
+	return r.encoder.Encode(captured)
+}
+
+// ReplayClock is the virtual time of a replay. It starts at the captured time of the first advertisement and
+// runs speed times faster than the real time, so that everything that depends on the time, e.g. filters, slopes
+// and averages, behaves as during the capture. With a speed of 0, it stands still at the captured time of the
+// last advertisement. Before the first advertisement, it returns the real time.
+type ReplayClock struct {
+	mu       sync.Mutex
+	speed    float64
+	captured time.Time
+	real     time.Time
+}
+
+// NewReplayClock creates the clock of a replay with the given speed.
+func NewReplayClock(speed float64) *ReplayClock {
+	return &ReplayClock{speed: speed}
+}
+
+// Now returns the current virtual time.
+func (c *ReplayClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.captured.IsZero() {
+		return time.Now()
+	}
+	return c.captured.Add(time.Duration(float64(time.Since(c.real)) * c.speed))
+}
+
+// set sets the virtual time to the captured time of an advertisement.
+func (c *ReplayClock) set(captured time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.captured = captured
+	c.real = time.Now()
+}
+
+// Replay reads a capture file and passes the advertisements to the zones like ProcessAdvertisement. The time
+// between two advertisements is divided by speed, so 1 replays in real time and 10 ten times faster. A speed
+// of 0 replays without delay. The readings get the captured time, and the clock, which the zones should use as
+// well, is set to it.
+func Replay(r io.Reader, speed float64, zones []*sensor.Zone, clock *ReplayClock) error {
+	return replay(r, speed, time.Sleep, func(adv Advertisement, captured time.Time) {
+		clock.set(captured)
+		processAdvertisement(adv, zones, captured)
+	})
+}
+
+// replay implements Replay with an exchangeable sleep function and handler, which gets the captured time.
+func replay(r io.Reader, speed float64, sleep func(time.Duration),
+	handle func(adv Advertisement, captured time.Time)) error {
+	scanner := bufio.NewScanner(r)
+	var last time.Time
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var captured capturedAdvertisement
+		if err := json.Unmarshal(scanner.Bytes(), &captured); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		adv, err := captured.advertisement()
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if speed > 0 && !last.IsZero() && captured.Time.After(last) {
+			sleep(time.Duration(float64(captured.Time.Sub(last)) / speed))
+		}
+		last = captured.Time
+		handle(adv, captured.Time)
+	}
+	return scanner.Err()
+}
+
+// advertisement converts the captured advertisement back.
+func (c capturedAdvertisement) advertisement() (Advertisement, error) {
+	adv := Advertisement{Address: strings.ToUpper(c.Address), LocalName: c.LocalName, RSSI: c.RSSI}
+	for _, md := range c.ManufacturerData {
+		data, err := hex.DecodeString(md.Data)
+		if err != nil {
+			return Advertisement{}, fmt.Errorf("invalid manufacturer data: %w", err)
+		}
+		adv.ManufacturerData = append(adv.ManufacturerData, ManufacturerData{CompanyID: md.CompanyID, Data: data})
+	}
+	for _, sd := range c.ServiceData {
+		data, err := hex.DecodeString(sd.Data)
+		if err != nil {
+			return Advertisement{}, fmt.Errorf("invalid service data: %w", err)
+		}
+		adv.ServiceData = append(adv.ServiceData, ServiceData{UUID: sd.UUID, Data: data})
+	}
+	return adv, nil
+}
@@ -0,0 +1,101 @@
+package bluetooth
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	advertisements := []Advertisement{
+		{
+			Address:          "C4:7C:8D:6A:12:34",
+			LocalName:        "GVH5075_1234",
+			RSSI:             -70,
+			ManufacturerData: []ManufacturerData{{CompanyID: 0xEC88, Data: fromHex(t, "00033a7b6400")}},
+		},
+		{
+			Address:     "A4:C1:38:8F:1A:2B",
+			RSSI:        -55,
+			ServiceData: []ServiceData{{UUID: 0x181A, Data: fromHex(t, "a4c1388f1a2b00e6375a0bb812")}},
+		},
+	}
+
+	var buf bytes.Buffer
+	recorder := NewRecorder(&buf)
+	for i, adv := range advertisements {
+		if err := recorder.record(adv, start.Add(time.Duration(i)*10*time.Second)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if !strings.Contains(buf.String(), `"data":"00033a7b6400"`) {
+		t.Errorf("expected data in hex, got %s", buf.String())
+	}
+
+	var replayed []Advertisement
+	var times []time.Time
+	var sleeps []time.Duration
+	err := replay(strings.NewReader(buf.String()+"\n"), 5, func(d time.Duration) {
+		sleeps = append(sleeps, d)
+	}, func(adv Advertisement, captured time.Time) {
+		replayed = append(replayed, adv)
+		times = append(times, captured)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(replayed, advertisements) {
+		t.Errorf("expected %+v, got %+v", advertisements, replayed)
+	}
+	if !reflect.DeepEqual(sleeps, []time.Duration{2 * time.Second}) {
+		t.Errorf("expected a pause of 2s, got %v", sleeps)
+	}
+	if len(times) != 2 || !times[0].Equal(start) || !times[1].Equal(start.Add(10*time.Second)) {
+		t.Errorf("expected the captured times, got %v", times)
+	}
+}
+
+func TestReplayClock(t *testing.T) {
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	clock := NewReplayClock(100)
+	if time.Since(clock.Now()) > time.Minute {
+		t.Errorf("expected the real time before the first advertisement, got %s", clock.Now())
+	}
+	clock.set(start)
+	time.Sleep(20 * time.Millisecond)
+	// 20ms at 100 times the speed are 2s of the capture
+	if elapsed := clock.Now().Sub(start); elapsed < 2*time.Second || elapsed > time.Minute {
+		t.Errorf("expected the virtual time to run 100 times faster, got %s after 20ms", elapsed)
+	}
+
+	stopped := NewReplayClock(0)
+	stopped.set(start)
+	time.Sleep(time.Millisecond)
+	if !stopped.Now().Equal(start) {
+		t.Errorf("expected the captured time without a speed, got %s", stopped.Now())
+	}
+}
+
+func TestReplayErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		speed   float64
+	}{
+		{name: "InvalidJSON", content: "{\"mac\": \n"},
+		{name: "InvalidHex", content: `{"mac": "C4:7C:8D:6A:12:34", "manufacturer_data": [{"data": "0x12"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := replay(strings.NewReader(tt.content), tt.speed, func(time.Duration) {},
+				func(Advertisement, time.Time) {})
+			if err == nil || !strings.HasPrefix(err.Error(), "line 1:") {
+				t.Errorf("expected an error for line 1, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package bluetooth
+
+import (
+	"errors"
+	"strings"
+
+	bt "tinygo.org/x/bluetooth"
+)
+
+// Advertisement holds the parts of a BLE advertisement that are needed to decode sensor readings. It decouples
+// the decoders from the Bluetooth stack, so that they can be tested with captured payloads.
+type Advertisement struct {
+	// Address is the MAC address of the sender in the form "AA:BB:CC:DD:EE:FF".
+	Address          string
+	LocalName        string
+	RSSI             int16
+	ManufacturerData []ManufacturerData
+	ServiceData      []ServiceData
+}
+
+// ManufacturerData is a manufacturer specific data element of an advertisement.
+type ManufacturerData struct {
+	CompanyID uint16
+	Data      []byte
+}
+
+// ServiceData is a service data element of an advertisement with a 16-bit service UUID.
+type ServiceData struct {
+	UUID uint16
+	Data []byte
+}
+
+// Reading is a decoded sensor reading. MacAddress is the address of the sensor, either from the payload or
+// the address of the sender. BatteryMV and BatteryPercent are 0 if the sensor doesn't report them, Uptime is
+// the time in seconds since the last reset of the sensor and 0 if not reported.
+type Reading struct {
+	MacAddress     string
+	Temperature    float64
+	Humidity       float64
+	BatteryMV      uint16
+	BatteryPercent int
+	Uptime         uint32
+}
+
+// Match defines how the registry finds the decoder of an advertisement. A zero value of a field is not used.
+// The service data UUID is checked first, then the company ID of the manufacturer data and then the prefix
+// of the local name.
+type Match struct {
+	ServiceUUID uint16
+	CompanyID   uint16
+	LocalName   string
+}
+
+// Decoder decodes the sensor readings of a sensor family from its advertisements.
+type Decoder interface {
+
+	// Name returns the name of the sensor family for the log.
+	Name() string
+
+	// Match returns the criteria of the advertisements the decoder handles.
+	Match() Match
+
+	// Decode extracts the reading from the advertisement. It returns an error if the advertisement has an
+	// unexpected format or contains no temperature and humidity.
+	Decode(adv Advertisement) (Reading, error)
+}
+
+// errNoDecoder is returned by Decode for advertisements of unknown devices.
+var errNoDecoder = errors.New("no decoder found")
+
+// registry holds the known decoders. It is only read after the start, so the scanner doesn't need to lock it.
+// Decoders later in the list are only used for advertisements that no earlier decoder matches with the same
+// criterion.
+var registry = []Decoder{
+	ws02Decoder{},
+	lywsd03mmcDecoder{},
+	goveeDecoder{},
+	ruuviDecoder{},
+	btHomeDecoder{},
+}
+
+// FindDecoder returns the decoder for the advertisement or nil if no decoder matches. Decoders are selected by
+// service data first, then by the company ID of the manufacturer data and finally by the local name.
+func FindDecoder(adv Advertisement) Decoder {
+	for _, sd := range adv.ServiceData {
+		for _, decoder := range registry {
+			if uuid := decoder.Match().ServiceUUID; uuid != 0 && uuid == sd.UUID {
+				return decoder
+			}
+		}
+	}
+	for _, md := range adv.ManufacturerData {
+		for _, decoder := range registry {
+			if id := decoder.Match().CompanyID; id != 0 && id == md.CompanyID {
+				return decoder
+			}
+		}
+	}
+	for _, decoder := range registry {
+		if name := decoder.Match().LocalName; name != "" && strings.HasPrefix(adv.LocalName, name) {
+			return decoder
+		}
+	}
+	return nil
+}
+
+// Decode finds the decoder of the advertisement and decodes the reading. The battery percentage of sensors that
+// only report the voltage is calculated with the battery model of the decoder. It returns the decoder, which is
+// nil if no decoder matches.
+func Decode(adv Advertisement) (Reading, Decoder, error) {
+	decoder := FindDecoder(adv)
+	if decoder == nil {
+		return Reading{}, nil, errNoDecoder
+	}
+	reading, err := decoder.Decode(adv)
+	if err != nil {
+		return reading, decoder, err
+	}
+	return withBatteryPercent(reading, decoder), decoder, nil
+}
+
+// DecodeScanResult decodes the reading of a scan result of the Bluetooth stack like Decode.
+func DecodeScanResult(scanResult bt.ScanResult) (Reading, Decoder, error) {
+	return Decode(newAdvertisement(scanResult))
+}
+
+// serviceData returns the data of the service data element with the given UUID or nil.
+func (adv Advertisement) serviceData(uuid uint16) []byte {
+	for _, sd := range adv.ServiceData {
+		if sd.UUID == uuid {
+			return sd.Data
+		}
+	}
+	return nil
+}
+
+// manufacturerData returns the data of the manufacturer data element with the given company ID or nil.
+func (adv Advertisement) manufacturerData(companyID uint16) []byte {
+	for _, md := range adv.ManufacturerData {
+		if md.CompanyID == companyID {
+			return md.Data
+		}
+	}
+	return nil
+}
+
+// newAdvertisement converts the scan result of the Bluetooth stack. Service data with 128-bit UUIDs is skipped.
+func newAdvertisement(scanResult bt.ScanResult) Advertisement {
+	adv := Advertisement{
+		Address:   strings.ToUpper(scanResult.Address.String()),
+		LocalName: scanResult.LocalName(),
+		RSSI:      scanResult.RSSI,
+	}
+	for _, md := range scanResult.ManufacturerData() {
+		adv.ManufacturerData = append(adv.ManufacturerData, ManufacturerData{CompanyID: md.CompanyID, Data: md.Data})
+	}
+	for _, sd := range scanResult.ServiceData() {
+		if sd.UUID.Is16Bit() {
+			adv.ServiceData = append(adv.ServiceData, ServiceData{UUID: sd.UUID.Get16Bit(), Data: sd.Data})
+		}
+	}
+	return adv
+}
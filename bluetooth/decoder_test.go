@@ -0,0 +1,136 @@
+package bluetooth
+
+import (
+	"dpf-bt/sensor"
+	"encoding/hex"
+	"math"
+	"testing"
+	"time"
+)
+
+// fromHex converts a captured payload in hex to bytes.
+func fromHex(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("invalid hex payload %s: %s", s, err)
+	}
+	return data
+}
+
+// checkReading compares the decoded reading with the expected one. Temperature and humidity are compared with
+// a tolerance, since the scaling of the raw values isn't exact in binary floating point.
+func checkReading(t *testing.T, got, expected Reading, err error, expectError bool) {
+	t.Helper()
+	if expectError {
+		if err == nil {
+			t.Errorf("expected an error, got %+v", got)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got.MacAddress != expected.MacAddress || got.BatteryMV != expected.BatteryMV ||
+		got.BatteryPercent != expected.BatteryPercent || got.Uptime != expected.Uptime ||
+		math.Abs(got.Temperature-expected.Temperature) > 1e-9 || math.Abs(got.Humidity-expected.Humidity) > 1e-9 {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestFindDecoder(t *testing.T) {
+	tests := []struct {
+		name     string
+		adv      Advertisement
+		expected string
+	}{
+		{
+			name:     "ByName",
+			adv:      Advertisement{LocalName: "ThermoBeacon"},
+			expected: "ThermoBeacon",
+		},
+		{
+			name:     "ByCompanyID",
+			adv:      Advertisement{LocalName: "GVH5075_1A2B", ManufacturerData: []ManufacturerData{{CompanyID: 0xEC88}}},
+			expected: "Govee H5075",
+		},
+		{
+			name: "ServiceDataBeforeCompanyID",
+			adv: Advertisement{
+				ManufacturerData: []ManufacturerData{{CompanyID: 0x0499}},
+				ServiceData:      []ServiceData{{UUID: 0xFCD2}},
+			},
+			expected: "BTHome",
+		},
+		{
+			name:     "ByServiceData",
+			adv:      Advertisement{LocalName: "ATC_8F1A2B", ServiceData: []ServiceData{{UUID: 0x181A}}},
+			expected: "LYWSD03MMC",
+		},
+		{
+			name:     "UnknownDevice",
+			adv:      Advertisement{LocalName: "Phone", ManufacturerData: []ManufacturerData{{CompanyID: 0x004C}}},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if decoder := FindDecoder(tt.adv); decoder != nil {
+				got = decoder.Name()
+			}
+			if got != tt.expected {
+				t.Errorf("expected decoder '%s', got '%s'", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestProcessAdvertisement(t *testing.T) {
+	shared := sensor.NewZone("North", 10)
+	shared.Devices = []*sensor.Device{
+		sensor.NewDevice("Cellar", "A4:C1:38:8F:1A:2B", sensor.RoleInside, sensor.SensorCalibration{}, 10),
+		sensor.NewDevice("Garden", "C4:7C:8D:6A:12:34", sensor.RoleOutside, sensor.SensorCalibration{}, 10),
+	}
+	other := sensor.NewZone("South", 10)
+	other.Devices = []*sensor.Device{
+		sensor.NewDevice("Garden", "C4:7C:8D:6A:12:34", sensor.RoleOutside,
+			sensor.SensorCalibration{Temperature: -0.5}, 10),
+		sensor.NewDevice("Attic", "C4:7C:8D:6A:56:78", sensor.RoleMonitor, sensor.SensorCalibration{}, 10),
+	}
+	zones := []*sensor.Zone{shared, other}
+
+	processAdvertisement(Advertisement{
+		Address:          "C4:7C:8D:6A:12:34",
+		RSSI:             -70,
+		ManufacturerData: []ManufacturerData{{CompanyID: 0xEC88, Data: fromHex(t, "00033a7b6400")}},
+	}, zones, time.Now())
+
+	if shared.Sensors.OutsideData.Temperature != 21.1 || other.Sensors.OutsideData.Temperature != 20.6 {
+		t.Errorf("expected outside temperatures 21.1 and 20.6, got %.1f and %.1f",
+			shared.Sensors.OutsideData.Temperature, other.Sensors.OutsideData.Temperature)
+	}
+	if shared.Store.Outside.Size() != 1 || other.Store.Outside.Size() != 1 || shared.Store.Inside.Size() != 0 {
+		t.Errorf("expected one outside reading per zone, got %d and %d (inside %d)",
+			shared.Store.Outside.Size(), other.Store.Outside.Size(), shared.Store.Inside.Size())
+	}
+	if shared.Sensors.OutsideData.RSSI != -70 || shared.Sensors.OutsideData.Name != "Outside" {
+		t.Errorf("unexpected outside sensor data %+v", shared.Sensors.OutsideData)
+	}
+	if shared.Devices[1].Data.Name != "Garden" || shared.Devices[1].Store.Size() != 1 {
+		t.Errorf("unexpected device data %+v", shared.Devices[1].Data)
+	}
+
+	processAdvertisement(Advertisement{
+		Address:          "C4:7C:8D:6A:56:78",
+		ManufacturerData: []ManufacturerData{{CompanyID: 0xEC88, Data: fromHex(t, "0000e1446400")}},
+	}, zones, time.Now())
+
+	if other.Devices[1].Data.Temperature != 5.7 || other.Devices[1].Store.Size() != 1 {
+		t.Errorf("expected monitored temperature 5.7, got %+v", other.Devices[1].Data)
+	}
+	if other.Store.Outside.Size() != 1 || other.Sensors.InsideData.Name != "" {
+		t.Errorf("monitor sensor must not change the inside or outside data")
+	}
+}
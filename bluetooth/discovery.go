@@ -0,0 +1,67 @@
+package bluetooth
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	bt "tinygo.org/x/bluetooth"
+)
+
+// DiscoveredSensor is a supported sensor that was found by a discovery scan, with its last reading.
+type DiscoveredSensor struct {
+	MacAddress string
+	Decoder    string
+	RSSI       int16
+	Reading    Reading
+	Count      int
+	LastSeen   time.Time
+}
+
+// Discovery collects the supported sensors of a scan. Every sensor is listed once with its last reading.
+type Discovery struct {
+	sensors map[string]*DiscoveredSensor
+}
+
+// NewDiscovery creates an empty discovery.
+func NewDiscovery() *Discovery {
+	return &Discovery{sensors: make(map[string]*DiscoveredSensor)}
+}
+
+// AddScanResult adds the advertisement of the Bluetooth stack.
+func (d *Discovery) AddScanResult(scanResult bt.ScanResult) {
+	d.add(newAdvertisement(scanResult), time.Now())
+}
+
+// add decodes the advertisement and records the sensor. Advertisements of unknown devices and invalid
+// advertisements are ignored.
+func (d *Discovery) add(adv Advertisement, now time.Time) {
+	reading, decoder, err := Decode(adv)
+	if err != nil {
+		return
+	}
+	found, ok := d.sensors[reading.MacAddress]
+	if !ok {
+		found = &DiscoveredSensor{MacAddress: reading.MacAddress, Decoder: decoder.Name()}
+		d.sensors[reading.MacAddress] = found
+	}
+	found.RSSI = adv.RSSI
+	found.Reading = reading
+	found.Count++
+	found.LastSeen = now
+}
+
+// Sensors returns the discovered sensors, the strongest signal first.
+func (d *Discovery) Sensors() []DiscoveredSensor {
+	sensors := make([]DiscoveredSensor, 0, len(d.sensors))
+	for _, found := range d.sensors {
+		sensors = append(sensors, *found)
+	}
+	slices.SortFunc(sensors, func(a, b DiscoveredSensor) int {
+		if c := cmp.Compare(b.RSSI, a.RSSI); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.MacAddress, b.MacAddress)
+	})
+	return sensors
+}
@@ -0,0 +1,37 @@
+package bluetooth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiscovery(t *testing.T) {
+	now := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	govee := func(address string, rssi int16, payload string) Advertisement {
+		return Advertisement{
+			Address:          address,
+			RSSI:             rssi,
+			ManufacturerData: []ManufacturerData{{CompanyID: goveeCompanyID, Data: fromHex(t, payload)}},
+		}
+	}
+
+	d := NewDiscovery()
+	d.add(govee("C4:7C:8D:6A:12:34", -80, "00033a7b6400"), now)
+	d.add(Advertisement{Address: "11:22:33:44:55:66", LocalName: "Phone", RSSI: -40}, now)
+	d.add(govee("C4:7C:8D:6A:56:78", -65, "0000e1446400"), now)
+	d.add(govee("C4:7C:8D:6A:12:34", -60, "00033a7b6400"), now.Add(time.Second))
+	d.add(govee("C4:7C:8D:6A:9A:BC", -65, "00033a7b"), now)
+
+	sensors := d.Sensors()
+	if len(sensors) != 2 {
+		t.Fatalf("expected 2 sensors, got %+v", sensors)
+	}
+	first, second := sensors[0], sensors[1]
+	if first.MacAddress != "C4:7C:8D:6A:12:34" || first.RSSI != -60 || first.Count != 2 ||
+		!first.LastSeen.Equal(now.Add(time.Second)) || first.Decoder != "Govee H5075" {
+		t.Errorf("unexpected first sensor %+v", first)
+	}
+	if second.MacAddress != "C4:7C:8D:6A:56:78" || second.Reading.Temperature != 5.7 || second.Count != 1 {
+		t.Errorf("unexpected second sensor %+v", second)
+	}
+}
@@ -0,0 +1,88 @@
+package bluetooth
+
+import (
+	"fmt"
+	"time"
+
+	bt "tinygo.org/x/bluetooth"
+)
+
+// gattConnectTimeout is the time the connection to a sensor may take.
+const gattConnectTimeout = 10 * time.Second
+
+// btLink is a GATT connection of the Bluetooth adapter to a service of a sensor.
+type btLink struct {
+	device          bt.Device
+	characteristics map[bt.UUID]*bt.DeviceCharacteristic
+}
+
+// connectGatt connects to the sensor with the MAC address and discovers the characteristics of the service.
+func connectGatt(address string, service bt.UUID) (gattLink, error) {
+	mac, err := bt.ParseMAC(address)
+	if err != nil {
+		return nil, err
+	}
+	device, err := bt.DefaultAdapter.Connect(bt.Address{MACAddress: bt.MACAddress{MAC: mac}},
+		bt.ConnectionParams{ConnectionTimeout: bt.NewDuration(gattConnectTimeout)})
+	if err != nil {
+		return nil, err
+	}
+	link := &btLink{device: device, characteristics: make(map[bt.UUID]*bt.DeviceCharacteristic)}
+	services, err := device.DiscoverServices([]bt.UUID{service})
+	if err == nil && len(services) == 0 {
+		err = fmt.Errorf("service %s not found", service)
+	}
+	if err != nil {
+		_ = device.Disconnect()
+		return nil, err
+	}
+	characteristics, err := services[0].DiscoverCharacteristics(nil)
+	if err != nil {
+		_ = device.Disconnect()
+		return nil, err
+	}
+	for i := range characteristics {
+		link.characteristics[characteristics[i].UUID()] = &characteristics[i]
+	}
+	return link, nil
+}
+
+// characteristic returns the discovered characteristic with the UUID.
+func (l *btLink) characteristic(uuid bt.UUID) (*bt.DeviceCharacteristic, error) {
+	c, ok := l.characteristics[uuid]
+	if !ok {
+		return nil, fmt.Errorf("characteristic %s not found", uuid)
+	}
+	return c, nil
+}
+
+func (l *btLink) Write(characteristic bt.UUID, data []byte) error {
+	c, err := l.characteristic(characteristic)
+	if err != nil {
+		return err
+	}
+	_, err = c.WriteWithoutResponse(data)
+	return err
+}
+
+func (l *btLink) Subscribe(characteristic bt.UUID) (<-chan []byte, error) {
+	c, err := l.characteristic(characteristic)
+	if err != nil {
+		return nil, err
+	}
+	notifications := make(chan []byte, 16)
+	err = c.EnableNotifications(func(buf []byte) {
+		data := make([]byte, len(buf))
+		copy(data, buf)
+		select {
+		case notifications <- data:
+		default:
+			lg.Warnf("Dropped notification of %s", characteristic)
+		}
+	})
+	return notifications, err
+}
+
+func (l *btLink) Disconnect() error {
+	return l.device.Disconnect()
+}
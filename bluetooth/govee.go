@@ -0,0 +1,40 @@
+package bluetooth
+
+import "fmt"
+
+// goveeCompanyID is the company ID the Govee H5075 uses for its manufacturer data.
+const goveeCompanyID = 0xEC88
+
+// goveeDecoder decodes the advertisements of Govee H5075 sensors. The payload doesn't contain the MAC address,
+// so the address of the sender is used.
+type goveeDecoder struct{}
+
+func (goveeDecoder) Name() string {
+	return "Govee H5075"
+}
+
+func (goveeDecoder) Match() Match {
+	return Match{CompanyID: goveeCompanyID}
+}
+
+func (goveeDecoder) Decode(adv Advertisement) (Reading, error) {
+	data := adv.manufacturerData(goveeCompanyID)
+	if len(data) != 6 {
+		return Reading{}, fmt.Errorf("unexpected manufacturer data length %d", len(data))
+	}
+	// temperature and humidity are packed into a 24-bit big endian value: temperature * 10000 + humidity * 10,
+	// the highest bit is the sign of the temperature
+	value := int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+	negative := value&0x800000 != 0
+	value &= 0x7FFFFF
+	temperature := float64(value/1000) / 10
+	if negative {
+		temperature = -temperature
+	}
+	return Reading{
+		MacAddress:     adv.Address,
+		Temperature:    temperature,
+		Humidity:       float64(value%1000) / 10,
+		BatteryPercent: int(data[4]),
+	}, nil
+}
@@ -0,0 +1,49 @@
+package bluetooth
+
+import "testing"
+
+func TestGoveeDecoder(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     string
+		expected    Reading
+		expectError bool
+	}{
+		{
+			name:    "PositiveTemperature",
+			payload: "00033a7b6400",
+			expected: Reading{
+				MacAddress:     "A4:C1:38:5D:3E:01",
+				Temperature:    21.1,
+				Humidity:       57.9,
+				BatteryPercent: 100,
+			},
+		},
+		{
+			name:    "NegativeTemperature",
+			payload: "0080d22a4b00",
+			expected: Reading{
+				MacAddress:     "A4:C1:38:5D:3E:01",
+				Temperature:    -5.3,
+				Humidity:       80.2,
+				BatteryPercent: 75,
+			},
+		},
+		{
+			name:        "WrongLength",
+			payload:     "00033a7b",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adv := Advertisement{
+				Address:          "A4:C1:38:5D:3E:01",
+				ManufacturerData: []ManufacturerData{{CompanyID: 0xEC88, Data: fromHex(t, tt.payload)}},
+			}
+			got, err := goveeDecoder{}.Decode(adv)
+			checkReading(t, got, tt.expected, err, tt.expectError)
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package bluetooth
+
+import (
+	"dpf-bt/sensor"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	bt "tinygo.org/x/bluetooth"
+)
+
+const (
+	// historyTimeout is the time a sensor has to answer a command of the history download.
+	historyTimeout = 10 * time.Second
+	// historyRetryDelay is the time after a failed download before the next attempt.
+	historyRetryDelay = 5 * time.Minute
+)
+
+// errHistoryTimeout is returned if a sensor doesn't answer a command of the history download in time.
+var errHistoryTimeout = errors.New("no answer from the sensor")
+
+// HistoryConfig configures the download of the history of sensors that log their readings. MinGap is the time
+// without advertisements after which the history is downloaded, MaxAge limits the downloaded time span.
+type HistoryConfig struct {
+	Enabled bool
+	MinGap  time.Duration
+	MaxAge  time.Duration
+}
+
+// HistoryRecord is a reading that a sensor has logged, with the time it was logged.
+type HistoryRecord struct {
+	Time        time.Time
+	Temperature float64
+	Humidity    float64
+}
+
+// historyReader is implemented by decoders of sensors that log their readings and offer the log over GATT.
+type historyReader interface {
+
+	// HistoryService returns the UUID of the GATT service of the history.
+	HistoryService() bt.UUID
+
+	// ReadHistory downloads the records that were logged after since. The times of the records are derived from
+	// now, the time of the download.
+	ReadHistory(link gattLink, since, now time.Time) ([]HistoryRecord, error)
+}
+
+// gattLink is a connection to the GATT service of a sensor. It is implemented with the Bluetooth stack and by a
+// fake peripheral in the tests.
+type gattLink interface {
+
+	// Write writes the data to the characteristic of the service.
+	Write(characteristic bt.UUID, data []byte) error
+
+	// Subscribe enables the notifications of the characteristic of the service and returns their channel.
+	Subscribe(characteristic bt.UUID) (<-chan []byte, error)
+
+	// Disconnect closes the connection.
+	Disconnect() error
+}
+
+// HistoryHandler is called with the logged readings of a device that were downloaded after a gap. The readings
+// are calibrated and ordered by time.
+type HistoryHandler func(zone *sensor.Zone, device *sensor.Device, readings []sensor.SensorData)
+
+// HistorySync downloads the logged readings of the configured sensors after a gap in their advertisements, e.g.
+// after a restart or while Bluetooth was down. The download runs in the background; the records are added to
+// the history of the devices with the next advertisement of the sensor, so that the histories are only changed
+// by the scanner.
+type HistorySync struct {
+	mu      sync.Mutex
+	config  HistoryConfig
+	sensors map[string]*historyState
+	connect func(address string, service bt.UUID) (gattLink, error)
+	handle  HistoryHandler
+	wg      sync.WaitGroup
+}
+
+// historyState is the state of the history download of a sensor.
+type historyState struct {
+	// lastSeen is the time of the last advertisement.
+	lastSeen time.Time
+	// gapStart is the start of a gap whose download is pending, zero without a gap.
+	gapStart time.Time
+	retryAt  time.Time
+	running  bool
+	// records are downloaded, but not yet added to the history.
+	records []HistoryRecord
+}
+
+// historySync is the history download of the scanner, nil if it is disabled.
+var historySync *HistorySync
+
+// NewHistorySync creates the history download with the given configuration. The handler is called with the
+// readings that are added to the history of a device.
+func NewHistorySync(config HistoryConfig, handle HistoryHandler) *HistorySync {
+	return &HistorySync{
+		config:  config,
+		sensors: make(map[string]*historyState),
+		connect: connectGatt,
+		handle:  handle,
+	}
+}
+
+// EnableHistorySync lets the scanner download the history of the sensors after a gap.
+func EnableHistorySync(s *HistorySync) {
+	historySync = s
+}
+
+// SetConfig changes the configuration, e.g. after the configuration file has changed.
+func (s *HistorySync) SetConfig(config HistoryConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+}
+
+// State returns for every sensor the time up to which its readings are complete: the start of a pending gap or
+// the time of the last advertisement. It is saved, so that the gap of a restart can be downloaded.
+func (s *HistorySync) State() map[string]time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := make(map[string]time.Time, len(s.sensors))
+	for mac, st := range s.sensors {
+		state[mac] = st.lastSeen
+		if !st.gapStart.IsZero() {
+			state[mac] = st.gapStart
+		}
+	}
+	return state
+}
+
+// Restore sets the times of the last advertisements of the sensors from a saved state.
+func (s *HistorySync) Restore(state map[string]time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for mac, lastSeen := range state {
+		s.state(mac).lastSeen = lastSeen
+	}
+}
+
+// observe records an advertisement of a configured sensor. After a gap, the download of the history is started
+// if the decoder supports it. It returns the records of a finished download.
+func (s *HistorySync) observe(mac string, decoder Decoder, now time.Time) []HistoryRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.state(mac)
+	records := st.records
+	st.records = nil
+	reader, ok := decoder.(historyReader)
+	if ok && s.config.Enabled {
+		if st.gapStart.IsZero() && !st.lastSeen.IsZero() && now.Sub(st.lastSeen) >= s.config.MinGap {
+			st.gapStart = st.lastSeen
+		}
+		if !st.gapStart.IsZero() && !st.running && !now.Before(st.retryAt) {
+			st.gapStart = maxTime(st.gapStart, now.Add(-s.config.MaxAge))
+			st.running = true
+			s.wg.Add(1)
+			go s.download(mac, reader, st.gapStart)
+		}
+	}
+	st.lastSeen = now
+	return records
+}
+
+// download connects to the sensor and downloads the records that were logged after since.
+func (s *HistorySync) download(mac string, reader historyReader, since time.Time) {
+	defer s.wg.Done()
+	lg.Infof("Downloading the history of %s since %s", mac, since.Format(time.DateTime))
+	records, err := s.readHistory(mac, reader, since)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.state(mac)
+	st.running = false
+	if err != nil {
+		lg.Warnf("Couldn't download the history of %s: %s", mac, err)
+		st.retryAt = time.Now().Add(historyRetryDelay)
+		return
+	}
+	lg.Infof("Downloaded %d records of the history of %s", len(records), mac)
+	st.gapStart = time.Time{}
+	st.records = append(st.records, records...)
+}
+
+// readHistory connects to the history service of the sensor and reads the records.
+func (s *HistorySync) readHistory(mac string, reader historyReader, since time.Time) ([]HistoryRecord, error) {
+	link, err := s.connect(mac, reader.HistoryService())
+	if err != nil {
+		return nil, fmt.Errorf("couldn't connect: %w", err)
+	}
+	defer func() {
+		_ = link.Disconnect()
+	}()
+	return reader.ReadHistory(link, since, time.Now())
+}
+
+// state returns the state of the sensor, which is created on first use. The caller must hold the lock.
+func (s *HistorySync) state(mac string) *historyState {
+	st, ok := s.sensors[mac]
+	if !ok {
+		st = &historyState{}
+		s.sensors[mac] = st
+	}
+	return st
+}
+
+// addHistory adds the records to the history of the device and its zone and passes the readings to the
+// handler. The calibration of the device is applied to the records.
+func (s *HistorySync) addHistory(zone *sensor.Zone, device *sensor.Device, records []HistoryRecord) {
+	readings := make([]sensor.SensorData, 0, len(records))
+	for _, record := range records {
+		reading := Reading{MacAddress: device.MacAddress, Temperature: record.Temperature, Humidity: record.Humidity}
+		readings = append(readings, newSensorData(reading, 0, device.Name, device.Calibration, record.Time))
+	}
+	zone.InsertHistory(device, readings)
+	if s.handle != nil {
+		s.handle(zone, device, readings)
+	}
+}
+
+// awaitNotification waits for the next notification that starts with the command byte.
+func awaitNotification(notifications <-chan []byte, command byte) ([]byte, error) {
+	timeout := time.After(historyTimeout)
+	for {
+		select {
+		case data, ok := <-notifications:
+			if !ok {
+				return nil, errors.New("connection closed")
+			}
+			if len(data) > 0 && data[0] == command {
+				return data, nil
+			}
+		case <-timeout:
+			return nil, errHistoryTimeout
+		}
+	}
+}
+
+// maxTime returns the later of two times.
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,217 @@
+package bluetooth
+
+import (
+	"dpf-bt/sensor"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	bt "tinygo.org/x/bluetooth"
+)
+
+// fakePeripheral simulates the GATT service of the log of a ThermoBeacon sensor. It answers the commands with
+// notifications of the logged temperatures and humidities, the oldest first.
+type fakePeripheral struct {
+	temperatures  []float64
+	humidities    []float64
+	wrongIndex    bool
+	notifications chan []byte
+	commands      int
+	disconnected  bool
+}
+
+func newFakePeripheral(temperatures, humidities []float64) *fakePeripheral {
+	return &fakePeripheral{temperatures: temperatures, humidities: humidities, notifications: make(chan []byte, 4)}
+}
+
+func (p *fakePeripheral) Write(characteristic bt.UUID, data []byte) error {
+	if characteristic != ws02CommandUUID || len(data) != 5 {
+		return errors.New("invalid command")
+	}
+	p.commands++
+	frame := make([]byte, 20)
+	frame[0] = data[0]
+	switch data[0] {
+	case ws02CommandCount:
+		count := len(p.temperatures)
+		frame[1], frame[2], frame[3] = byte(count), byte(count>>8), byte(count>>16)
+	case ws02CommandRead:
+		copy(frame[1:5], data[1:5])
+		if p.wrongIndex {
+			frame[1]++
+		}
+		index := ws02Index(data[1:4])
+		for i := range int(data[4]) {
+			binary.LittleEndian.PutUint16(frame[5+2*i:], uint16(int16(p.temperatures[index+i]*16)))
+			binary.LittleEndian.PutUint16(frame[11+2*i:], uint16(p.humidities[index+i]*16))
+		}
+	}
+	// an unrelated notification is ignored
+	p.notifications <- []byte{0x20}
+	p.notifications <- frame
+	return nil
+}
+
+func (p *fakePeripheral) Subscribe(characteristic bt.UUID) (<-chan []byte, error) {
+	if characteristic != ws02NotificationUUID {
+		return nil, errors.New("unknown characteristic")
+	}
+	return p.notifications, nil
+}
+
+func (p *fakePeripheral) Disconnect() error {
+	p.disconnected = true
+	return nil
+}
+
+func TestWS02ReadHistory(t *testing.T) {
+	now := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	temperatures := []float64{20, 20.5, 21, -1.5, 22, 22.5, 23}
+	humidities := []float64{60, 61, 62, 63, 64, 65, 66}
+	tests := []struct {
+		name             string
+		since            time.Time
+		wrongIndex       bool
+		expectedFirst    int
+		expectedCommands int
+		expectError      bool
+	}{
+		{name: "LastRecords", since: now.Add(-25 * time.Minute), expectedFirst: 4, expectedCommands: 2},
+		{name: "ExactInterval", since: now.Add(-30 * time.Minute), expectedFirst: 4, expectedCommands: 2},
+		{name: "AllRecords", since: now.Add(-24 * time.Hour), expectedFirst: 0, expectedCommands: 4},
+		{name: "NothingNew", since: now, expectedFirst: 7, expectedCommands: 1},
+		{name: "WrongIndex", since: now.Add(-time.Hour), wrongIndex: true, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peripheral := newFakePeripheral(temperatures, humidities)
+			peripheral.wrongIndex = tt.wrongIndex
+			records, err := ws02Decoder{}.ReadHistory(peripheral, tt.since, now)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", records)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(records) != len(temperatures)-tt.expectedFirst || peripheral.commands != tt.expectedCommands {
+				t.Fatalf("expected %d records with %d commands, got %d with %d", len(temperatures)-tt.expectedFirst,
+					tt.expectedCommands, len(records), peripheral.commands)
+			}
+			for i, record := range records {
+				index := tt.expectedFirst + i
+				expectedTime := now.Add(-time.Duration(len(temperatures)-1-index) * ws02LogInterval)
+				if !record.Time.Equal(expectedTime) || math.Abs(record.Temperature-temperatures[index]) > 1e-9 ||
+					record.Humidity != humidities[index] {
+					t.Errorf("record %d: expected %.1f°C %.1f%% at %s, got %+v", i, temperatures[index],
+						humidities[index], expectedTime, record)
+				}
+			}
+		})
+	}
+}
+
+func TestHistorySync(t *testing.T) {
+	defer EnableHistorySync(nil)
+	zone := sensor.NewZone("North", 20)
+	device := sensor.NewDevice("Cellar", "BC:9A:78:56:34:12", sensor.RoleInside,
+		sensor.SensorCalibration{Temperature: 0.5}, 20)
+	zone.Devices = []*sensor.Device{device}
+	zones := []*sensor.Zone{zone}
+
+	peripheral := newFakePeripheral([]float64{18, 18.5, 19}, []float64{70, 71, 72})
+	var handled []sensor.SensorData
+	s := NewHistorySync(HistoryConfig{Enabled: true, MinGap: 15 * time.Minute, MaxAge: 24 * time.Hour},
+		func(_ *sensor.Zone, _ *sensor.Device, readings []sensor.SensorData) {
+			handled = readings
+		})
+	connected := ""
+	s.connect = func(address string, service bt.UUID) (gattLink, error) {
+		connected = address
+		if service != ws02HistoryService {
+			return nil, errors.New("unknown service")
+		}
+		return peripheral, nil
+	}
+	s.Restore(map[string]time.Time{device.MacAddress: time.Now().Add(-time.Hour)})
+	EnableHistorySync(s)
+
+	payload := make([]byte, 18)
+	copy(payload[2:8], []byte{0x12, 0x34, 0x56, 0x78, 0x9A, 0xBC})
+	binary.LittleEndian.PutUint16(payload[10:12], 320) // temperature
+	binary.LittleEndian.PutUint16(payload[12:14], 960) // humidity
+	adv := Advertisement{LocalName: "ThermoBeacon", ManufacturerData: []ManufacturerData{{Data: payload}}}
+
+	// the first advertisement after the gap starts the download
+	processAdvertisement(adv, zones, time.Now())
+	s.wg.Wait()
+	if connected != device.MacAddress || !peripheral.disconnected {
+		t.Fatalf("expected a connection to %s, got '%s'", device.MacAddress, connected)
+	}
+	if device.Store.Size() != 1 {
+		t.Fatalf("expected the records to be added with the next advertisement, got %d readings",
+			device.Store.Size())
+	}
+
+	// the next advertisement adds the records before the new reading
+	processAdvertisement(adv, zones, time.Now())
+	if device.Store.Size() != 5 || zone.Store.Inside.Size() != 5 || len(handled) != 3 {
+		t.Fatalf("expected 5 readings in the histories and 3 handled readings, got %d, %d and %d",
+			device.Store.Size(), zone.Store.Inside.Size(), len(handled))
+	}
+	if handled[0].Temperature != 18.5 || handled[0].Name != "Cellar" || handled[0].DewPoint == 0 {
+		t.Errorf("expected the calibrated first record, got %+v", handled[0])
+	}
+	if state := s.State(); len(state) != 1 || time.Since(state[device.MacAddress]) > time.Minute {
+		t.Errorf("expected the time of the last advertisement in the state, got %v", state)
+	}
+
+	// no download without a gap
+	peripheral.commands = 0
+	processAdvertisement(adv, zones, time.Now())
+	s.wg.Wait()
+	if peripheral.commands != 0 {
+		t.Errorf("unexpected download without a gap")
+	}
+}
+
+func TestHistorySyncRetry(t *testing.T) {
+	s := NewHistorySync(HistoryConfig{Enabled: true, MinGap: 15 * time.Minute, MaxAge: 2 * time.Hour}, nil)
+	attempts := 0
+	s.connect = func(string, bt.UUID) (gattLink, error) {
+		attempts++
+		return nil, errors.New("connection failed")
+	}
+	now := time.Now()
+	mac := "BC:9A:78:56:34:12"
+	s.Restore(map[string]time.Time{mac: now.Add(-5 * time.Hour)})
+
+	s.observe(mac, ws02Decoder{}, now)
+	s.wg.Wait()
+	s.observe(mac, ws02Decoder{}, now.Add(time.Minute))
+	s.wg.Wait()
+	if attempts != 1 {
+		t.Errorf("expected no retry within %s, got %d attempts", historyRetryDelay, attempts)
+	}
+	// the pending gap is kept, limited to the maximum age
+	if state := s.State(); !state[mac].Equal(now.Add(-2 * time.Hour)) {
+		t.Errorf("expected the pending gap in the state, got %s", state[mac])
+	}
+	s.observe(mac, ws02Decoder{}, now.Add(historyRetryDelay+time.Minute))
+	s.wg.Wait()
+	if attempts != 2 {
+		t.Errorf("expected a retry, got %d attempts", attempts)
+	}
+	// sensors without a log are never connected
+	s.Restore(map[string]time.Time{"A4:C1:38:8F:1A:2B": now.Add(-time.Hour)})
+	s.observe("A4:C1:38:8F:1A:2B", goveeDecoder{}, now)
+	s.wg.Wait()
+	if attempts != 2 {
+		t.Errorf("unexpected connection to a sensor without a log")
+	}
+}
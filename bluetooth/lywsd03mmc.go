@@ -0,0 +1,51 @@
+package bluetooth
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// environmentalSensingUUID is the 16-bit UUID of the environmental sensing service, which the custom firmwares
+// of the Xiaomi sensors use for their service data.
+const environmentalSensingUUID = 0x181A
+
+// lywsd03mmcDecoder decodes the advertisements of Xiaomi LYWSD03MMC sensors with the custom firmware by pvvx
+// or ATC1441. Both send service data of the environmental sensing service, the ATC1441 format has 13 bytes and
+// the pvvx format has 15 bytes.
+type lywsd03mmcDecoder struct{}
+
+func (lywsd03mmcDecoder) Name() string {
+	return "LYWSD03MMC"
+}
+
+func (lywsd03mmcDecoder) Match() Match {
+	return Match{ServiceUUID: environmentalSensingUUID}
+}
+
+func (lywsd03mmcDecoder) Decode(adv Advertisement) (Reading, error) {
+	data := adv.serviceData(environmentalSensingUUID)
+	switch len(data) {
+	case 13:
+		// MAC (big endian), temperature in 0.1°C, humidity in %, battery in %, battery in mV and a frame counter,
+		// all values big endian
+		return Reading{
+			MacAddress:     formatMAC(data[0:6], false),
+			Temperature:    float64(int16(binary.BigEndian.Uint16(data[6:8]))) / 10,
+			Humidity:       float64(data[8]),
+			BatteryPercent: int(data[9]),
+			BatteryMV:      binary.BigEndian.Uint16(data[10:12]),
+		}, nil
+	case 15:
+		// MAC (little endian), temperature in 0.01°C, humidity in 0.01%, battery in mV, battery in %, a frame
+		// counter and flags, all values little endian
+		return Reading{
+			MacAddress:     formatMAC(data[0:6], true),
+			Temperature:    float64(int16(binary.LittleEndian.Uint16(data[6:8]))) / 100,
+			Humidity:       float64(binary.LittleEndian.Uint16(data[8:10])) / 100,
+			BatteryMV:      binary.LittleEndian.Uint16(data[10:12]),
+			BatteryPercent: int(data[12]),
+		}, nil
+	default:
+		return Reading{}, fmt.Errorf("unexpected service data length %d", len(data))
+	}
+}
@@ -0,0 +1,59 @@
+package bluetooth
+
+import "testing"
+
+func TestLywsd03mmcDecoder(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     string
+		expected    Reading
+		expectError bool
+	}{
+		{
+			name:    "ATC1441",
+			payload: "a4c1388f1a2b00e6375a0bb812",
+			expected: Reading{
+				MacAddress:     "A4:C1:38:8F:1A:2B",
+				Temperature:    23.0,
+				Humidity:       55,
+				BatteryPercent: 90,
+				BatteryMV:      3000,
+			},
+		},
+		{
+			name:    "ATC1441NegativeTemperature",
+			payload: "a4c1388f1a2bffce50640c1c01",
+			expected: Reading{
+				MacAddress:     "A4:C1:38:8F:1A:2B",
+				Temperature:    -5.0,
+				Humidity:       80,
+				BatteryPercent: 100,
+				BatteryMV:      3100,
+			},
+		},
+		{
+			name:    "Pvvx",
+			payload: "2b1a8f38c1a46308f212860b550704",
+			expected: Reading{
+				MacAddress:     "A4:C1:38:8F:1A:2B",
+				Temperature:    21.47,
+				Humidity:       48.5,
+				BatteryMV:      2950,
+				BatteryPercent: 85,
+			},
+		},
+		{
+			name:        "UnknownFormat",
+			payload:     "2b1a8f38c1a46308f212",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adv := Advertisement{ServiceData: []ServiceData{{UUID: 0x181A, Data: fromHex(t, tt.payload)}}}
+			got, err := lywsd03mmcDecoder{}.Decode(adv)
+			checkReading(t, got, tt.expected, err, tt.expectError)
+		})
+	}
+}
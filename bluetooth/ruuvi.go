@@ -0,0 +1,46 @@
+package bluetooth
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ruuviCompanyID is the company ID of Ruuvi Innovations.
+const ruuviCompanyID = 0x0499
+
+// ruuviDecoder decodes the RAWv2 format (data format 5) of RuuviTag sensors.
+type ruuviDecoder struct{}
+
+func (ruuviDecoder) Name() string {
+	return "RuuviTag"
+}
+
+func (ruuviDecoder) Match() Match {
+	return Match{CompanyID: ruuviCompanyID}
+}
+
+// BatteryCurve returns the curve of the lithium coin cell of the sensor.
+func (ruuviDecoder) BatteryCurve() []BatteryPoint {
+	return coinCellCurve
+}
+
+func (ruuviDecoder) Decode(adv Advertisement) (Reading, error) {
+	data := adv.manufacturerData(ruuviCompanyID)
+	if len(data) != 24 || data[0] != 5 {
+		return Reading{}, fmt.Errorf("unsupported data format")
+	}
+	// temperature in 0.005°C and humidity in 0.0025%, 0x8000 and 0xFFFF mark invalid values
+	rawTemperature := binary.BigEndian.Uint16(data[1:3])
+	rawHumidity := binary.BigEndian.Uint16(data[3:5])
+	if rawTemperature == 0x8000 || rawHumidity == 0xFFFF {
+		return Reading{}, fmt.Errorf("invalid temperature or humidity")
+	}
+	// the upper 11 bits of the power info are the battery voltage above 1600mV
+	powerInfo := binary.BigEndian.Uint16(data[13:15])
+	return Reading{
+		MacAddress:  formatMAC(data[18:24], false),
+		Temperature: float64(int16(rawTemperature)) * 0.005,
+		Humidity:    float64(rawHumidity) * 0.0025,
+		BatteryMV:   powerInfo>>5 + 1600,
+	}, nil
+}
@@ -0,0 +1,51 @@
+package bluetooth
+
+import "testing"
+
+func TestRuuviDecoder(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     string
+		expected    Reading
+		expectError bool
+	}{
+		{
+			name:    "ValidData",
+			payload: "0512fc5394c37c0004fffc040cac364200cdcbb8334c884f",
+			expected: Reading{
+				MacAddress:  "CB:B8:33:4C:88:4F",
+				Temperature: 24.3,
+				Humidity:    53.49,
+				BatteryMV:   2977,
+			},
+		},
+		{
+			name:    "NegativeTemperature",
+			payload: "05fc1854ccc37c0004fffc040cac364200cdcbb8334c884f",
+			expected: Reading{
+				MacAddress:  "CB:B8:33:4C:88:4F",
+				Temperature: -5,
+				Humidity:    54.27,
+				BatteryMV:   2977,
+			},
+		},
+		{
+			name:        "InvalidValues",
+			payload:     "058000ffffffff800080008000ffffffffffffffffffffff",
+			expectError: true,
+		},
+		{
+			name:        "OtherDataFormat",
+			payload:     "0302bf1a0000",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adv := Advertisement{ManufacturerData: []ManufacturerData{{CompanyID: 0x0499, Data: fromHex(t, tt.payload)}}}
+			got, err := ruuviDecoder{}.Decode(adv)
+			checkReading(t, got, tt.expected, err, tt.expectError)
+		})
+	}
+}
@@ -3,7 +3,7 @@ package bluetooth
 import (
 	"dpf-bt/sensor"
 	"dpf-bt/utility"
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/d2r2/go-logger"
 	"slices"
@@ -14,94 +14,129 @@ import (
 
 var lg = logger.NewPackageLogger("bt", logger.InfoLevel)
 
-// ProcessAdvertisement processes the Bluetooth advertisement payload to extract sensor data and update the
-// Sensors object. It determines whether the data belongs to the inside or outside sensor and logs the
-// sensor's details if valid.
-func ProcessAdvertisement(scanResult bt.ScanResult,
-	sensors *sensor.Sensors,
-	sensorStore *sensor.SensorStore) {
-	payload := scanResult.AdvertisementPayload.ManufacturerData()[0].Data
-	if len(payload) == 18 {
-		sensorData := parseWS02Data(payload, scanResult.RSSI, *sensors)
-		if sensorData.Name != "" {
-			if sensorData.Name == "Inside" {
-				sensors.InsideData = *sensorData
-				sensorStore.Inside.AddSensorData(*sensorData)
-			} else {
-				sensors.OutsideData = *sensorData
-				sensorStore.Outside.AddSensorData(*sensorData)
-			}
-			lg.Infof("%8s Temp: %.1f°C - Hum: %.1f%% - Bat: %d - RSSI: %d - Uptime: %s",
-				sensorData.Name, sensorData.Temperature, sensorData.Humidity, sensorData.BatLevel,
-				sensorData.RSSI, formatUptime(sensorData.Uptime))
-		}
-	}
+// ProcessAdvertisement decodes the Bluetooth advertisement with the matching decoder of the registry and updates
+// every device of the zones with the MAC address of the sensor, unless the filters of the device reject the
+// reading. Every advertisement, even a rejected one, is added to the statistics of the device. The readings of
+// inside and outside devices are combined into the inside and outside data of the zone. The battery level is
+// added to the battery trend of the device. After a gap in the advertisements of a sensor, its logged readings are
+// downloaded if the history sync is enabled and added to the history with the next advertisement. The backup
+// sensors are updated as well and the sensor's details are logged. A sensor can be used by several zones, e.g. a
+// shared outside sensor.
+func ProcessAdvertisement(scanResult bt.ScanResult, zones []*sensor.Zone) {
+	processAdvertisement(newAdvertisement(scanResult), zones, time.Now())
 }
 
-// parseWS02Data parses WS02 sensor advertisement payload to extract sensor data including temperature,
-// humidity, signal strength, and uptime.
-// It also calculates calibrated values using sensor-specific offsets and formats the identifier and name
-// of the sensor. The input includes the raw payload, RSSI, and a sensor calibration object, and the function
-// returns structured sensor data.
-func parseWS02Data(payload []byte, rssi int16, sensors sensor.Sensors) *sensor.SensorData {
-	// The WS02 advertisement contains temperature and humidity in specific locations.
-	// The mac address starts at offset 2, and the 16-bit value of the battery level starts at offset 8.
-	// The temperature is a 16-bit value starting at offset 10, and humidity is a 16-bit value starting at offset 12.
-	// The uptime in seconds since the last reset is a 32-bit value starting at offset 14.
-	const macOffset = 2
-	const batOffset = 8
-	const tempOffset = 10
-	const humidityOffset = 12
-	const uptimeOffset = 14
-
-	macAdr := ""
-	for _, c := range slices.Backward(payload[macOffset : macOffset+6]) {
-		macAdr = macAdr + fmt.Sprintf("%02X:", c)
+// processAdvertisement implements ProcessAdvertisement for a converted advertisement that was received at now.
+// Every zone is locked while it is updated.
+func processAdvertisement(adv Advertisement, zones []*sensor.Zone, now time.Time) {
+	reading, decoder, err := Decode(adv)
+	if err != nil {
+		if !errors.Is(err, errNoDecoder) {
+			lg.Debugf("Invalid %s advertisement of %s: %s", decoder.Name(), adv.Address, err)
+		}
+		return
 	}
-	macAdr = strings.TrimSuffix(macAdr, ":")
-	name := ""
-	tempCal := 0.0
-	humCal := 0.0
-	if macAdr == sensors.InsideData.MacAddress {
-		name = "Inside"
-		tempCal = sensors.InsideCalibration.Temperature
-		humCal = sensors.InsideCalibration.Humidity
-	} else if macAdr == sensors.OutsideData.MacAddress {
-		name = "Outside"
-		tempCal = sensors.OutsideCalibration.Temperature
-		humCal = sensors.OutsideCalibration.Humidity
+	var history []HistoryRecord
+	if historySync != nil && isConfigured(reading.MacAddress, zones) {
+		history = historySync.observe(reading.MacAddress, decoder, now)
 	}
-	batLevel := binary.LittleEndian.Uint16(payload[batOffset : batOffset+2])
-	uptime := binary.LittleEndian.Uint32(payload[uptimeOffset : uptimeOffset+4])
+	for _, zone := range zones {
+		zone.Lock()
+		for _, device := range zone.Devices {
+			if device.MacAddress != reading.MacAddress {
+				continue
+			}
+			if len(history) > 0 {
+				historySync.addHistory(zone, device, history)
+			}
+			if device.Stats.Add(adv.RSSI, reading.Uptime, now) {
+				lg.Warnf("Sensor %s has rebooted (%d reboots)", device.Name, device.Stats.Reboots)
+			}
+			sensorData, accepted := device.Store.AddFilteredSensorData(
+				newSensorData(reading, adv.RSSI, device.Name, device.Calibration, now))
+			if !accepted {
+				lg.Warnf("Rejected reading of %s: Temp: %.1f°C - Hum: %.1f%%", device.Name,
+					sensorData.Temperature, sensorData.Humidity)
+				continue
+			}
+			device.Data = sensorData
+			device.Battery.Add(sensorData.BatPercent, now)
+			zone.UpdateRole(device.Role, now)
+			logSensorData(zone, sensorData, decoder)
+		}
+		switch reading.MacAddress {
+		case zone.Sensors.InsideBackup.MacAddress:
+			zone.Sensors.InsideBackup = newSensorData(reading, adv.RSSI, "InsideBackup",
+				zone.Sensors.InsideBackupCalibration, now)
+			logSensorData(zone, zone.Sensors.InsideBackup, decoder)
+		case zone.Sensors.OutsideBackup.MacAddress:
+			zone.Sensors.OutsideBackup = newSensorData(reading, adv.RSSI, "OutsideBackup",
+				zone.Sensors.OutsideBackupCalibration, now)
+			logSensorData(zone, zone.Sensors.OutsideBackup, decoder)
+		}
+		zone.Unlock()
+	}
+}
 
-	temperatureInt := binary.LittleEndian.Uint16(payload[tempOffset : tempOffset+2])
-	temperatureRaw := float64(temperatureInt) / 16.0
-	if temperatureRaw > 4000 {
-		temperatureRaw -= 4096
+// isConfigured checks whether a device of the zones has the MAC address.
+func isConfigured(mac string, zones []*sensor.Zone) bool {
+	for _, zone := range zones {
+		for _, device := range zone.Devices {
+			if device.MacAddress == mac {
+				return true
+			}
+		}
 	}
-	temperature := temperatureRaw + tempCal
+	return false
+}
 
-	humidityInt := binary.LittleEndian.Uint16(payload[humidityOffset : humidityOffset+2])
-	humidityRaw := float64(humidityInt) / 16.0
-	if humidityRaw > 4000 {
-		humidityRaw -= 4096
+// logSensorData logs the details of a sensor reading of the zone.
+func logSensorData(zone *sensor.Zone, sensorData sensor.SensorData, decoder Decoder) {
+	name := sensorData.Name
+	if zone.Name != "" {
+		name = zone.Name + "/" + name
 	}
-	humidity := humidityRaw + humCal
+	lg.Infof("%8s Temp: %.1f°C - Hum: %.1f%% - Bat: %d - RSSI: %d - Uptime: %s (%s)",
+		name, sensorData.Temperature, sensorData.Humidity, sensorData.BatLevel,
+		sensorData.RSSI, formatUptime(sensorData.Uptime), decoder.Name())
+}
 
+// newSensorData creates the sensor data of a decoded reading with the given name. The calibration is applied to
+// the temperature and the humidity before the derived values are calculated.
+func newSensorData(reading Reading, rssi int16, name string, calibration sensor.SensorCalibration,
+	scanned time.Time) sensor.SensorData {
+	temperature, humidity := calibration.Apply(reading.Temperature, reading.Humidity)
 	roundedTemperature := utility.RoundDouble(temperature, 1)
 	roundedHumidity := utility.RoundDouble(humidity, 1)
 
-	return &sensor.SensorData{
-		MacAddress:  macAdr,
+	return sensor.SensorData{
+		MacAddress:  reading.MacAddress,
 		Name:        name,
-		BatLevel:    batLevel,
+		BatLevel:    reading.BatteryMV,
+		BatPercent:  reading.BatteryPercent,
 		RSSI:        rssi,
-		Uptime:      uptime,
+		Uptime:      reading.Uptime,
 		Temperature: roundedTemperature,
 		Humidity:    roundedHumidity,
 		DewPoint:    utility.CalcDewPoint(roundedTemperature, roundedHumidity),
-		Scanned:     time.Now(),
+		AbsHumidity: utility.CalcAbsoluteHumidity(roundedTemperature, roundedHumidity),
+		MixingRatio: utility.CalcMixingRatio(roundedTemperature, roundedHumidity),
+		Scanned:     scanned,
+	}
+}
+
+// formatMAC formats the 6 bytes of a MAC address in the form "AA:BB:CC:DD:EE:FF". Some sensors send the
+// address in reversed byte order.
+func formatMAC(address []byte, reversed bool) string {
+	bytes := slices.Clone(address)
+	if reversed {
+		slices.Reverse(bytes)
+	}
+	parts := make([]string, len(bytes))
+	for i, b := range bytes {
+		parts[i] = fmt.Sprintf("%02X", b)
 	}
+	return strings.Join(parts, ":")
 }
 
 // formatUptime converts uptime in seconds to a human-readable format as a string in the form "Xd Yh Zm".
@@ -71,7 +71,8 @@ func TestParseWS02Data(t *testing.T) {
 		name           string
 		payload        []byte
 		rssi           int16
-		sensors        sensor.Sensors
+		sensorName     string
+		calibration    sensor.SensorCalibration
 		expectedResult *sensor.SensorData
 	}{
 		{
@@ -85,15 +86,11 @@ func TestParseWS02Data(t *testing.T) {
 				copy(payload[2:8], []byte{0x12, 0x34, 0x56, 0x78, 0x9A, 0xBC})
 				return payload
 			}(),
-			rssi: -50,
-			sensors: sensor.Sensors{
-				InsideData: sensor.SensorData{
-					MacAddress: "BC:9A:78:56:34:12",
-				},
-				InsideCalibration: sensor.SensorCalibration{
-					Temperature: 0.5,
-					Humidity:    1.0,
-				},
+			rssi:       -50,
+			sensorName: "Inside",
+			calibration: sensor.SensorCalibration{
+				Temperature: 0.5,
+				Humidity:    1.0,
 			},
 			expectedResult: &sensor.SensorData{
 				MacAddress:  "BC:9A:78:56:34:12",
@@ -104,6 +101,8 @@ func TestParseWS02Data(t *testing.T) {
 				Temperature: 80.5,
 				Humidity:    61.0,
 				DewPoint:    utility.CalcDewPoint(80.5, 61.0),
+				AbsHumidity: utility.CalcAbsoluteHumidity(80.5, 61.0),
+				MixingRatio: utility.CalcMixingRatio(80.5, 61.0),
 				Scanned:     time.Now(), // Should not be compared directly in test
 			},
 		},
@@ -118,15 +117,11 @@ func TestParseWS02Data(t *testing.T) {
 				copy(payload[2:8], []byte{0xAB, 0xCD, 0xEF, 0x12, 0x34, 0x56})
 				return payload
 			}(),
-			rssi: -60,
-			sensors: sensor.Sensors{
-				OutsideData: sensor.SensorData{
-					MacAddress: "56:34:12:EF:CD:AB",
-				},
-				OutsideCalibration: sensor.SensorCalibration{
-					Temperature: -0.5,
-					Humidity:    -1.0,
-				},
+			rssi:       -60,
+			sensorName: "Outside",
+			calibration: sensor.SensorCalibration{
+				Temperature: -0.5,
+				Humidity:    -1.0,
 			},
 			expectedResult: &sensor.SensorData{
 				MacAddress:  "56:34:12:EF:CD:AB",
@@ -137,11 +132,44 @@ func TestParseWS02Data(t *testing.T) {
 				Temperature: 93.3,
 				Humidity:    30.3,
 				DewPoint:    utility.CalcDewPoint(93.3, 30.3),
+				AbsHumidity: utility.CalcAbsoluteHumidity(93.3, 30.3),
+				MixingRatio: utility.CalcMixingRatio(93.3, 30.3),
 				Scanned:     time.Now(), // Should not be compared directly in test
 			},
 		},
 		{
-			name: "Invalid MAC, no matching sensor",
+			name: "Valid outside backup sensor data",
+			payload: func() []byte {
+				payload := make([]byte, 18)
+				binary.LittleEndian.PutUint16(payload[8:10], 60)    // battery level
+				binary.LittleEndian.PutUint32(payload[14:18], 600)  // uptime
+				binary.LittleEndian.PutUint16(payload[10:12], 160)  // temperature
+				binary.LittleEndian.PutUint16(payload[12:14], 1200) // humidity
+				copy(payload[2:8], []byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66})
+				return payload
+			}(),
+			rssi:       -80,
+			sensorName: "OutsideBackup",
+			calibration: sensor.SensorCalibration{
+				Temperature: 0.2,
+				Humidity:    -2.0,
+			},
+			expectedResult: &sensor.SensorData{
+				MacAddress:  "66:55:44:33:22:11",
+				Name:        "OutsideBackup",
+				BatLevel:    60,
+				RSSI:        -80,
+				Uptime:      600,
+				Temperature: 10.2,
+				Humidity:    73.0,
+				DewPoint:    utility.CalcDewPoint(10.2, 73.0),
+				AbsHumidity: utility.CalcAbsoluteHumidity(10.2, 73.0),
+				MixingRatio: utility.CalcMixingRatio(10.2, 73.0),
+				Scanned:     time.Now(), // Should not be compared directly in test
+			},
+		},
+		{
+			name: "Uncalibrated data without name",
 			payload: func() []byte {
 				payload := make([]byte, 18)
 				binary.LittleEndian.PutUint16(payload[8:10], 100)   // battery level
@@ -152,14 +180,6 @@ func TestParseWS02Data(t *testing.T) {
 				return payload
 			}(),
 			rssi: -70,
-			sensors: sensor.Sensors{
-				InsideData: sensor.SensorData{
-					MacAddress: "BC:9A:78:56:34:12",
-				},
-				OutsideData: sensor.SensorData{
-					MacAddress: "56:34:12:EF:CD:AB",
-				},
-			},
 			expectedResult: &sensor.SensorData{
 				MacAddress:  "AB:89:67:45:23:01",
 				Name:        "",
@@ -169,6 +189,8 @@ func TestParseWS02Data(t *testing.T) {
 				Temperature: 125.0,
 				Humidity:    187.5,
 				DewPoint:    utility.CalcDewPoint(125.0, 187.5),
+				AbsHumidity: utility.CalcAbsoluteHumidity(125.0, 187.5),
+				MixingRatio: utility.CalcMixingRatio(125.0, 187.5),
 				Scanned:     time.Now(), // Should not be compared directly in test
 			},
 		},
@@ -176,7 +198,7 @@ func TestParseWS02Data(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := parseWS02Data(tt.payload, tt.rssi, tt.sensors)
+			result := newSensorData(parseWS02Data(tt.payload), tt.rssi, tt.sensorName, tt.calibration, time.Now())
 
 			if result.MacAddress != tt.expectedResult.MacAddress {
 				t.Errorf("MacAddress = %v, want %v", result.MacAddress, tt.expectedResult.MacAddress)
@@ -202,6 +224,12 @@ func TestParseWS02Data(t *testing.T) {
 			if result.DewPoint != tt.expectedResult.DewPoint {
 				t.Errorf("DewPoint = %v, want %v", result.DewPoint, tt.expectedResult.DewPoint)
 			}
+			if result.AbsHumidity != tt.expectedResult.AbsHumidity {
+				t.Errorf("AbsHumidity = %v, want %v", result.AbsHumidity, tt.expectedResult.AbsHumidity)
+			}
+			if result.MixingRatio != tt.expectedResult.MixingRatio {
+				t.Errorf("MixingRatio = %v, want %v", result.MixingRatio, tt.expectedResult.MixingRatio)
+			}
 		})
 	}
 }
@@ -0,0 +1,210 @@
+package bluetooth
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	bt "tinygo.org/x/bluetooth"
+)
+
+const (
+	// minRecoveryBackoff is the first delay before the adapter is enabled again after a failure. The delay doubles
+	// with every failure that follows without an advertisement, up to the maximum backoff.
+	minRecoveryBackoff = 5 * time.Second
+	// stopScanTimeout is the time the scan may take to stop before the adapter is enabled again anyway.
+	stopScanTimeout = 10 * time.Second
+)
+
+// errScanStalled is the reason of a recovery when no advertisements were received within the timeout.
+var errScanStalled = errors.New("no advertisements received")
+
+// SupervisorConfig configures the scan watchdog. Timeout is the time without advertisements after which the scan is
+// restarted, MaxBackoff the longest delay between two attempts to enable the adapter.
+type SupervisorConfig struct {
+	Timeout    time.Duration
+	MaxBackoff time.Duration
+}
+
+// SupervisorStatus describes the state of the scanner for the REST API.
+type SupervisorStatus struct {
+	Scanning          bool
+	LastAdvertisement time.Time
+	Recoveries        int
+	LastRecovery      time.Time
+	LastError         string
+}
+
+// scanAdapter is the part of the Bluetooth adapter the supervisor uses. It is implemented by the adapter of the
+// Bluetooth stack and by a fake adapter in the tests.
+type scanAdapter interface {
+	Enable() error
+	Scan(callback func(*bt.Adapter, bt.ScanResult)) error
+	StopScan() error
+}
+
+// Supervisor runs the Bluetooth scan and restarts it if it fails or if no advertisements arrive for the timeout,
+// e.g. after BlueZ was restarted. The adapter is enabled again with an increasing backoff until it works.
+type Supervisor struct {
+	adapter     scanAdapter
+	onScan      func(*bt.Adapter, bt.ScanResult)
+	onRecovery  func(reason error, recoveries int)
+	minBackoff  time.Duration
+	stopTimeout time.Duration
+	mu          sync.Mutex
+	config      SupervisorConfig
+	status      SupervisorStatus
+	// received is set when an advertisement arrives during the current scan.
+	received bool
+}
+
+// NewSupervisor creates the supervisor of the scan of the adapter. onScan is called with every advertisement,
+// onRecovery, which may be nil, with the reason of every restart of the scan.
+func NewSupervisor(adapter *bt.Adapter, config SupervisorConfig, onScan func(*bt.Adapter, bt.ScanResult),
+	onRecovery func(reason error, recoveries int)) *Supervisor {
+	return &Supervisor{
+		adapter:     adapter,
+		onScan:      onScan,
+		onRecovery:  onRecovery,
+		minBackoff:  minRecoveryBackoff,
+		stopTimeout: stopScanTimeout,
+		config:      config,
+	}
+}
+
+// SetConfig changes the configuration, e.g. after the configuration file has changed.
+func (s *Supervisor) SetConfig(config SupervisorConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+}
+
+// Status returns the current state of the scanner.
+func (s *Supervisor) Status() SupervisorStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Run enables the adapter and scans until stop is closed, forever with a nil channel. A failed or stalled scan is
+// stopped and the adapter is enabled again after the backoff.
+func (s *Supervisor) Run(stop <-chan struct{}) {
+	backoff := s.minBackoff
+	for {
+		err := s.adapter.Enable()
+		if err == nil {
+			err = s.scan(stop)
+		}
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if s.recover(err) {
+			backoff = s.minBackoff
+		}
+		lg.Warnf("Bluetooth scan failed: %s - enabling the adapter again in %s", err, backoff)
+		select {
+		case <-stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, max(s.maxBackoff(), s.minBackoff))
+	}
+}
+
+// scan runs the scan until it fails, stalls or stop is closed. It returns the reason why the scan ended.
+func (s *Supervisor) scan(stop <-chan struct{}) error {
+	s.mu.Lock()
+	s.status.Scanning = true
+	s.status.LastAdvertisement = time.Now()
+	s.received = false
+	s.mu.Unlock()
+	lg.Info("Bluetooth scan started")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.adapter.Scan(s.handle)
+	}()
+	reason := s.watch(done, stop)
+	s.mu.Lock()
+	s.status.Scanning = false
+	s.mu.Unlock()
+	return reason
+}
+
+// watch waits until the scan ends or no advertisement was received for the timeout. A stalled scan is stopped.
+func (s *Supervisor) watch(done <-chan error, stop <-chan struct{}) error {
+	ticker := time.NewTicker(s.checkInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			if err == nil {
+				err = errors.New("scan stopped")
+			}
+			return err
+		case <-stop:
+			s.stopScan(done)
+			return nil
+		case <-ticker.C:
+			s.mu.Lock()
+			stalled := time.Since(s.status.LastAdvertisement) >= s.config.Timeout
+			s.mu.Unlock()
+			if stalled {
+				s.stopScan(done)
+				return errScanStalled
+			}
+		}
+	}
+}
+
+// stopScan stops the scan and waits until it has ended or the stop timeout has passed.
+func (s *Supervisor) stopScan(done <-chan error) {
+	_ = s.adapter.StopScan()
+	select {
+	case <-done:
+	case <-time.After(s.stopTimeout):
+		lg.Warn("Bluetooth scan didn't stop in time")
+	}
+}
+
+// handle records the time of the advertisement and passes it on.
+func (s *Supervisor) handle(adapter *bt.Adapter, scanResult bt.ScanResult) {
+	s.mu.Lock()
+	s.status.LastAdvertisement = time.Now()
+	s.received = true
+	s.mu.Unlock()
+	s.onScan(adapter, scanResult)
+}
+
+// recover counts the recovery and reports it. A scan that was still running is stopped, so that the adapter
+// can be enabled again. It returns whether advertisements were received before the failure.
+func (s *Supervisor) recover(reason error) bool {
+	_ = s.adapter.StopScan()
+	s.mu.Lock()
+	s.status.Recoveries++
+	s.status.LastRecovery = time.Now()
+	s.status.LastError = reason.Error()
+	recoveries, received := s.status.Recoveries, s.received
+	s.received = false
+	s.mu.Unlock()
+	if s.onRecovery != nil {
+		s.onRecovery(reason, recoveries)
+	}
+	return received
+}
+
+// maxBackoff returns the configured maximum backoff.
+func (s *Supervisor) maxBackoff() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config.MaxBackoff
+}
+
+// checkInterval returns the interval in which the time of the last advertisement is checked.
+func (s *Supervisor) checkInterval() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return max(s.config.Timeout/10, time.Millisecond)
+}
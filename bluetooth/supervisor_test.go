@@ -0,0 +1,163 @@
+package bluetooth
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	bt "tinygo.org/x/bluetooth"
+)
+
+// fakeAdapter simulates a Bluetooth adapter. Enable fails for the first failures calls, then every scan delivers
+// adverts advertisements and stalls until it is stopped.
+type fakeAdapter struct {
+	mu       sync.Mutex
+	failures int
+	adverts  int
+	enables  int
+	scans    int
+	stop     chan struct{}
+}
+
+func (a *fakeAdapter) Enable() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.enables++
+	if a.enables <= a.failures {
+		return errors.New("adapter not found")
+	}
+	return nil
+}
+
+func (a *fakeAdapter) Scan(callback func(*bt.Adapter, bt.ScanResult)) error {
+	a.mu.Lock()
+	if a.stop != nil {
+		a.mu.Unlock()
+		return errors.New("already scanning")
+	}
+	a.scans++
+	stop := make(chan struct{})
+	a.stop = stop
+	adverts := a.adverts
+	a.mu.Unlock()
+	for range adverts {
+		callback(nil, bt.ScanResult{})
+	}
+	<-stop
+	return nil
+}
+
+func (a *fakeAdapter) StopScan() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.stop == nil {
+		return errors.New("not scanning")
+	}
+	close(a.stop)
+	a.stop = nil
+	return nil
+}
+
+func (a *fakeAdapter) counts() (int, int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.enables, a.scans
+}
+
+func TestSupervisor(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		adverts  int
+		reason   error
+	}{
+		{name: "StalledScan", adverts: 3, reason: errScanStalled},
+		{name: "AdapterMissing", failures: 2, reason: errScanStalled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := &fakeAdapter{failures: tt.failures, adverts: tt.adverts}
+			var mu sync.Mutex
+			received := 0
+			var reasons []error
+			s := NewSupervisor(nil, SupervisorConfig{Timeout: 20 * time.Millisecond, MaxBackoff: 40 * time.Millisecond},
+				func(*bt.Adapter, bt.ScanResult) {
+					mu.Lock()
+					received++
+					mu.Unlock()
+				},
+				func(reason error, _ int) {
+					mu.Lock()
+					reasons = append(reasons, reason)
+					mu.Unlock()
+				})
+			s.adapter = adapter
+			s.minBackoff = 5 * time.Millisecond
+			s.stopTimeout = 100 * time.Millisecond
+
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				s.Run(stop)
+				close(done)
+			}()
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				if _, scans := adapter.counts(); scans >= 2 || time.Now().After(deadline) {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+			close(stop)
+			<-done
+
+			enables, scans := adapter.counts()
+			if scans < 2 || enables < tt.failures+2 {
+				t.Fatalf("expected the scan to be restarted, got %d enables and %d scans", enables, scans)
+			}
+			status := s.Status()
+			mu.Lock()
+			defer mu.Unlock()
+			if status.Scanning || status.Recoveries != len(reasons) || status.Recoveries < tt.failures+1 {
+				t.Errorf("unexpected status %+v with %d recoveries reported", status, len(reasons))
+			}
+			if !errors.Is(reasons[len(reasons)-1], tt.reason) || status.LastError != tt.reason.Error() {
+				t.Errorf("expected the last recovery because of '%s', got %v", tt.reason, reasons)
+			}
+			if received < tt.adverts*scans-tt.adverts {
+				t.Errorf("expected the advertisements of every scan, got %d", received)
+			}
+			if status.LastAdvertisement.IsZero() {
+				t.Errorf("expected the time of the last advertisement")
+			}
+		})
+	}
+}
+
+func TestSupervisorBackoff(t *testing.T) {
+	adapter := &fakeAdapter{failures: 100}
+	s := NewSupervisor(nil, SupervisorConfig{Timeout: time.Second, MaxBackoff: 20 * time.Millisecond},
+		func(*bt.Adapter, bt.ScanResult) {}, nil)
+	s.adapter = adapter
+	s.minBackoff = 5 * time.Millisecond
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		s.Run(stop)
+		close(done)
+	}()
+	// the backoff doubles from 5ms up to 20ms: 5 + 10 + 20 + 20 + ... milliseconds
+	time.Sleep(200 * time.Millisecond)
+	close(stop)
+	<-done
+	enables, scans := adapter.counts()
+	if scans != 0 || enables < 4 || enables > 15 {
+		t.Errorf("expected about 10 attempts to enable the adapter and no scan, got %d and %d", enables, scans)
+	}
+	if status := s.Status(); status.Recoveries < enables-1 || status.LastError != "adapter not found" {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
@@ -0,0 +1,142 @@
+package bluetooth
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	bt "tinygo.org/x/bluetooth"
+)
+
+const (
+	// ws02LogInterval is the interval in which the sensors log a reading.
+	ws02LogInterval = 10 * time.Minute
+	// ws02CommandCount requests the number of logged records.
+	ws02CommandCount = 0x01
+	// ws02CommandRead requests logged records, starting at an index.
+	ws02CommandRead = 0x07
+	// ws02RecordsPerFrame is the number of records that fit into one notification.
+	ws02RecordsPerFrame = 3
+)
+
+var (
+	// ws02HistoryService is the GATT service of the log with a characteristic for the commands and one for the
+	// notifications with the answers.
+	ws02HistoryService   = bt.New16BitUUID(0xffe0)
+	ws02CommandUUID      = bt.New16BitUUID(0xfff5)
+	ws02NotificationUUID = bt.New16BitUUID(0xfff3)
+)
+
+// ws02Decoder decodes the advertisements of the Brifit WS02 (ThermoBeacon) sensors.
+type ws02Decoder struct{}
+
+func (ws02Decoder) Name() string {
+	return "ThermoBeacon"
+}
+
+func (ws02Decoder) Match() Match {
+	return Match{LocalName: "ThermoBeacon"}
+}
+
+// BatteryCurve returns the curve of the lithium coin cell of the sensor.
+func (ws02Decoder) BatteryCurve() []BatteryPoint {
+	return coinCellCurve
+}
+
+func (ws02Decoder) Decode(adv Advertisement) (Reading, error) {
+	for _, md := range adv.ManufacturerData {
+		if len(md.Data) == 18 {
+			return parseWS02Data(md.Data), nil
+		}
+	}
+	return Reading{}, fmt.Errorf("no manufacturer data with 18 bytes")
+}
+
+// parseWS02Data parses WS02 sensor advertisement payload to extract the MAC address, temperature, humidity,
+// battery voltage and uptime.
+func parseWS02Data(payload []byte) Reading {
+	// The WS02 advertisement contains temperature and humidity in specific locations.
+	// The mac address starts at offset 2, and the 16-bit value of the battery level starts at offset 8.
+	// The temperature is a 16-bit value starting at offset 10, and humidity is a 16-bit value starting at offset 12.
+	// The uptime in seconds since the last reset is a 32-bit value starting at offset 14.
+	const macOffset = 2
+	const batOffset = 8
+	const tempOffset = 10
+	const humidityOffset = 12
+	const uptimeOffset = 14
+
+	return Reading{
+		MacAddress:  formatMAC(payload[macOffset:macOffset+6], true),
+		Temperature: ws02Value(payload[tempOffset : tempOffset+2]),
+		Humidity:    ws02Value(payload[humidityOffset : humidityOffset+2]),
+		BatteryMV:   binary.LittleEndian.Uint16(payload[batOffset : batOffset+2]),
+		Uptime:      binary.LittleEndian.Uint32(payload[uptimeOffset : uptimeOffset+4]),
+	}
+}
+
+// ws02Value converts a 16-bit temperature or humidity value of 1/16 units. Values above 4000 are negative.
+func ws02Value(data []byte) float64 {
+	value := float64(binary.LittleEndian.Uint16(data)) / 16.0
+	if value > 4000 {
+		value -= 4096
+	}
+	return value
+}
+
+// HistoryService returns the GATT service of the log of the sensor.
+func (ws02Decoder) HistoryService() bt.UUID {
+	return ws02HistoryService
+}
+
+// ReadHistory reads the logged records after since. The command 01 00 00 00 00 is answered with the number of
+// records as a 24-bit value at offset 1. The command 07 followed by the 24-bit index of the first record and the
+// number of records (up to 3) is answered with the index at offset 1, the number at offset 4 and the temperatures
+// and humidities of the records as 16-bit values at offset 5 and 11. The newest record is the last one; as the
+// sensor logs every 10 minutes, the time of a record is derived from its distance to the newest record, which is
+// assumed to be logged at now.
+func (ws02Decoder) ReadHistory(link gattLink, since, now time.Time) ([]HistoryRecord, error) {
+	notifications, err := link.Subscribe(ws02NotificationUUID)
+	if err != nil {
+		return nil, err
+	}
+	if err = link.Write(ws02CommandUUID, []byte{ws02CommandCount, 0, 0, 0, 0}); err != nil {
+		return nil, err
+	}
+	frame, err := awaitNotification(notifications, ws02CommandCount)
+	if err != nil {
+		return nil, err
+	}
+	if len(frame) < 4 {
+		return nil, fmt.Errorf("invalid count of %d bytes", len(frame))
+	}
+	count := ws02Index(frame[1:4])
+	// the records of the last n intervals are logged after since
+	n := min(count, int((now.Sub(since)+ws02LogInterval-1)/ws02LogInterval))
+	records := make([]HistoryRecord, 0, max(n, 0))
+	for index := count - n; index < count; index += ws02RecordsPerFrame {
+		number := min(ws02RecordsPerFrame, count-index)
+		command := []byte{ws02CommandRead, byte(index), byte(index >> 8), byte(index >> 16), byte(number)}
+		if err = link.Write(ws02CommandUUID, command); err != nil {
+			return nil, err
+		}
+		if frame, err = awaitNotification(notifications, ws02CommandRead); err != nil {
+			return nil, err
+		}
+		if len(frame) < 17 || ws02Index(frame[1:4]) != index || int(frame[4]) != number {
+			return nil, fmt.Errorf("invalid records %x for index %d", frame, index)
+		}
+		for i := range number {
+			records = append(records, HistoryRecord{
+				Time:        now.Add(-time.Duration(count-1-index-i) * ws02LogInterval),
+				Temperature: ws02Value(frame[5+2*i : 7+2*i]),
+				Humidity:    ws02Value(frame[11+2*i : 13+2*i]),
+			})
+		}
+	}
+	return records, nil
+}
+
+// ws02Index converts a 24-bit little endian record index or count.
+func ws02Index(data []byte) int {
+	return int(data[0]) | int(data[1])<<8 | int(data[2])<<16
+}
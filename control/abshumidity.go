@@ -0,0 +1,41 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"dpf-bt/utility"
+)
+
+// AbsHumidityName is the name of the absolute humidity controller as used in the configuration file.
+const AbsHumidityName = "absolute"
+
+// AbsHumidityController switches the fan based on the difference of the absolute humidity (g/m³) between
+// inside and outside. The fan is switched on when the difference reaches MinAbsDiff + AbsHysteresis and
+// switched off when it drops below MinAbsDiff. In between, the fan state is kept.
+type AbsHumidityController struct{}
+
+// Name returns the name of the strategy as used in the configuration file.
+func (c *AbsHumidityController) Name() string {
+	return AbsHumidityName
+}
+
+// Compute evaluates the temperature and humidity limits and the absolute humidity difference.
+func (c *AbsHumidityController) Compute(input Input, result *sensor.ResultData) {
+	if !checkLimits(input, result) {
+		return
+	}
+	cfg := input.Config
+	deltaAh := utility.RoundDouble(input.Inside.AbsHumidity-input.Outside.AbsHumidity, 1)
+	result.AhDiff = deltaAh
+	if !input.Trace.Check("ah diff", deltaAh, GreaterOrEqual, cfg.MinAbsDiff) {
+		result.ShouldBeOn = false
+		result.Reason = sensor.ReasonAbsHumidityUnderHyst
+		return
+	}
+	if input.Trace.Check("ah diff", deltaAh, GreaterOrEqual, cfg.MinAbsDiff+cfg.AbsHysteresis) {
+		result.ShouldBeOn = true
+		result.Reason = sensor.ReasonAbsHumidityOverHyst
+		return
+	}
+	// we don't change the fan state inside the hysteresis band
+	result.Reason = sensor.ReasonAbsHumidityInBetween
+}
@@ -0,0 +1,71 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"reflect"
+	"testing"
+)
+
+func TestAbsHumidityController(t *testing.T) {
+	fanConfig := sensor.FanConfig{MinAbsDiff: 2.0, AbsHysteresis: 1.0}
+	tests := []struct {
+		name           string
+		inside         sensor.SensorData
+		outside        sensor.SensorData
+		fanConfig      sensor.FanConfig
+		expectedResult sensor.ResultData
+		lastResult     sensor.ResultData
+	}{
+		{
+			name:           "LowInsideTemperature",
+			inside:         sensor.SensorData{Temperature: 8.0, AbsHumidity: 12.0},
+			outside:        sensor.SensorData{AbsHumidity: 5.0},
+			fanConfig:      sensor.FanConfig{MinAbsDiff: 2.0, AbsHysteresis: 1.0, MinTempInside: 10.0},
+			expectedResult: sensor.ResultData{ShouldBeOn: false, Reason: sensor.ReasonInsideTempTooLow},
+			lastResult:     sensor.ResultData{ShouldBeOn: true},
+		},
+		{
+			name:           "UnderHysteresis",
+			inside:         sensor.SensorData{AbsHumidity: 9.0},
+			outside:        sensor.SensorData{AbsHumidity: 7.5},
+			fanConfig:      fanConfig,
+			expectedResult: sensor.ResultData{ShouldBeOn: false, AhDiff: 1.5, Reason: sensor.ReasonAbsHumidityUnderHyst},
+			lastResult:     sensor.ResultData{ShouldBeOn: true},
+		},
+		{
+			name:           "OverHysteresis",
+			inside:         sensor.SensorData{AbsHumidity: 11.2},
+			outside:        sensor.SensorData{AbsHumidity: 7.1},
+			fanConfig:      fanConfig,
+			expectedResult: sensor.ResultData{ShouldBeOn: true, AhDiff: 4.1, Reason: sensor.ReasonAbsHumidityOverHyst},
+			lastResult:     sensor.ResultData{ShouldBeOn: false},
+		},
+		{
+			name:           "InBetweenKeepsOn",
+			inside:         sensor.SensorData{AbsHumidity: 9.5},
+			outside:        sensor.SensorData{AbsHumidity: 7.0},
+			fanConfig:      fanConfig,
+			expectedResult: sensor.ResultData{ShouldBeOn: true, AhDiff: 2.5, Reason: sensor.ReasonAbsHumidityInBetween},
+			lastResult:     sensor.ResultData{ShouldBeOn: true},
+		},
+		{
+			name:           "InBetweenKeepsOff",
+			inside:         sensor.SensorData{AbsHumidity: 9.5},
+			outside:        sensor.SensorData{AbsHumidity: 7.0},
+			fanConfig:      fanConfig,
+			expectedResult: sensor.ResultData{ShouldBeOn: false, AhDiff: 2.5, Reason: sensor.ReasonAbsHumidityInBetween},
+			lastResult:     sensor.ResultData{ShouldBeOn: false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &AbsHumidityController{}
+			c.Compute(Input{Inside: tt.inside, Outside: tt.outside, Config: tt.fanConfig}, &tt.lastResult)
+
+			if !reflect.DeepEqual(tt.lastResult, tt.expectedResult) {
+				t.Errorf("expected ResultData = %+v, got %+v", tt.expectedResult, tt.lastResult)
+			}
+		})
+	}
+}
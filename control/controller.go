@@ -0,0 +1,94 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// DefaultName is the name of the controller that is used when no controller is configured.
+const DefaultName = "dewpoint"
+
+// Input holds everything a Controller needs to decide about the fan state: the latest inside and outside
+// readings, their history, the fan configuration and the current time. Trace records the evaluated checks,
+// it may be nil.
+type Input struct {
+	Inside         sensor.SensorData
+	Outside        sensor.SensorData
+	InsideHistory  *sensor.SensorDataList
+	OutsideHistory *sensor.SensorDataList
+	Config         sensor.FanConfig
+	Now            time.Time
+	Trace          *Decision
+}
+
+// Controller defines a fan control strategy. Different strategies can be selected by name in the configuration.
+type Controller interface {
+
+	// Name returns the name of the strategy as used in the configuration file.
+	Name() string
+
+	// Compute evaluates the input and updates the result with the new fan state and the reason for it.
+	// The result contains the previous decision, so strategies with a hysteresis can keep the fan state.
+	Compute(input Input, result *sensor.ResultData)
+}
+
+// Factory creates a new instance of a Controller.
+type Factory func() Controller
+
+// registry maps the controller names to their factories.
+var registry = map[string]Factory{
+	DefaultName:      func() Controller { return &DewPointController{} },
+	AbsHumidityName:  func() Controller { return &AbsHumidityController{} },
+	ProportionalName: func() Controller { return &ProportionalController{} },
+}
+
+// Register adds a controller factory under the given name. An existing factory with the same name is replaced.
+func Register(name string, factory Factory) {
+	registry[name] = factory
+}
+
+// New creates the controller that is registered under the given name. An empty name selects the default
+// controller. Returns an error if no controller with this name exists.
+func New(name string) (Controller, error) {
+	if name == "" {
+		name = DefaultName
+	}
+	factory, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown controller '%s', valid names are %v", name, Names())
+	}
+	return factory(), nil
+}
+
+// Names returns the sorted names of all registered controllers.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// checkLimits verifies the minimal inside and outside temperature and the minimal inside humidity.
+// If one of the limits is violated, the fan is switched off, the reason is set, and false is returned.
+func checkLimits(input Input, result *sensor.ResultData) bool {
+	if !input.Trace.Check("inside temp", input.Inside.Temperature, GreaterOrEqual, input.Config.MinTempInside) {
+		result.ShouldBeOn = false
+		result.Reason = sensor.ReasonInsideTempTooLow
+		return false
+	}
+	if !input.Trace.Check("outside temp", input.Outside.Temperature, GreaterOrEqual, input.Config.MinTempOutside) {
+		result.ShouldBeOn = false
+		result.Reason = sensor.ReasonOutsideTempTooLow
+		return false
+	}
+	if !input.Trace.Check("inside humidity", input.Inside.Humidity, GreaterOrEqual, input.Config.MinHumidityInside) {
+		result.ShouldBeOn = false
+		result.Reason = sensor.ReasonInsideHumidityTooLow
+		return false
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"slices"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name         string
+		controller   string
+		expectedName string
+		expectError  bool
+	}{
+		{name: "empty name selects default", controller: "", expectedName: DefaultName},
+		{name: "dew point controller", controller: "dewpoint", expectedName: "dewpoint"},
+		{name: "absolute humidity controller", controller: "absolute", expectedName: "absolute"},
+		{name: "proportional controller", controller: "proportional", expectedName: "proportional"},
+		{name: "unknown controller", controller: "magic", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(tt.controller)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected error for controller '%s', got none", tt.controller)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.Name() != tt.expectedName {
+				t.Errorf("expected controller %s, got %s", tt.expectedName, c.Name())
+			}
+		})
+	}
+}
+
+type fixedController struct{}
+
+func (c *fixedController) Name() string { return "fixed" }
+
+func (c *fixedController) Compute(_ Input, result *sensor.ResultData) {
+	result.ShouldBeOn = true
+	result.Reason = sensor.ReasonNone
+}
+
+func TestRegister(t *testing.T) {
+	Register("fixed", func() Controller { return &fixedController{} })
+	defer delete(registry, "fixed")
+
+	c, err := New("fixed")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result := sensor.ResultData{}
+	c.Compute(Input{}, &result)
+	if !result.ShouldBeOn {
+		t.Errorf("expected registered controller to switch the fan on")
+	}
+	if !slices.Contains(Names(), "fixed") {
+		t.Errorf("expected 'fixed' in controller names %v", Names())
+	}
+}
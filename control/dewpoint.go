@@ -0,0 +1,63 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"dpf-bt/utility"
+)
+
+// DewPointController switches the fan based on the dew point difference between inside and outside.
+// The fan is switched on when the difference reaches MinDiff + Hysteresis and switched off when it
+// drops below MinDiff. In between, the trend of the dew point difference decides: a rising difference
+// switches the fan on and a falling difference switches it off. Without a clear trend, the fan state is kept.
+type DewPointController struct{}
+
+// Name returns the name of the strategy as used in the configuration file.
+func (c *DewPointController) Name() string {
+	return DefaultName
+}
+
+// Compute evaluates the temperature and humidity limits and the dew point difference.
+func (c *DewPointController) Compute(input Input, result *sensor.ResultData) {
+	if !checkLimits(input, result) {
+		return
+	}
+	cfg := input.Config
+	deltaDp := input.Inside.DewPoint - input.Outside.DewPoint
+	result.DpTrend = dewPointTrend(input)
+	if !input.Trace.Check("dp diff", deltaDp, GreaterOrEqual, cfg.MinDiff) {
+		result.ShouldBeOn = false
+		result.Reason = sensor.ReasonDewPointUnderHyst
+		return
+	}
+	if input.Trace.Check("dp diff", deltaDp, GreaterOrEqual, cfg.MinDiff+cfg.Hysteresis) {
+		result.ShouldBeOn = true
+		result.Reason = sensor.ReasonDewPointOverHyst
+		return
+	}
+	if deltaDp >= cfg.MinDiff && deltaDp < cfg.MinDiff+cfg.Hysteresis {
+		if input.Trace.Check("dp trend", result.DpTrend, Greater, cfg.MinTrend) {
+			result.ShouldBeOn = true
+			result.Reason = sensor.ReasonDewPointRising
+			return
+		}
+		if !input.Trace.Check("dp trend", result.DpTrend, GreaterOrEqual, -cfg.MinTrend) {
+			result.ShouldBeOn = false
+			result.Reason = sensor.ReasonDewPointFalling
+			return
+		}
+		// we don't change the fan state since there is no clear trend of the dew point difference
+		result.Reason = sensor.ReasonDewPointInBetween
+		return
+	}
+	result.ShouldBeOn = false
+	result.Reason = sensor.ReasonUnknown
+}
+
+// dewPointTrend returns the rate of change of the dew point difference between inside and outside in °C per hour.
+// Returns 0 if the history of one of the sensors is missing.
+func dewPointTrend(input Input) float64 {
+	if input.InsideHistory == nil || input.OutsideHistory == nil {
+		return 0
+	}
+	return utility.RoundDouble(input.InsideHistory.DewPointSlope()-input.OutsideHistory.DewPointSlope(), 2)
+}
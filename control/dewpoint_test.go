@@ -0,0 +1,158 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDewPointController(t *testing.T) {
+	tests := []struct {
+		name           string
+		inside         sensor.SensorData
+		outside        sensor.SensorData
+		fanConfig      sensor.FanConfig
+		expectedResult sensor.ResultData
+		lastResult     sensor.ResultData
+	}{
+		{
+			name:           "LowInsideTemperature",
+			inside:         sensor.SensorData{Temperature: 18.0},
+			fanConfig:      sensor.FanConfig{MinTempInside: 20.0},
+			expectedResult: sensor.ResultData{ShouldBeOn: false, Reason: sensor.ReasonInsideTempTooLow},
+			lastResult:     sensor.ResultData{ShouldBeOn: true},
+		},
+		{
+			name:           "LowOutsideTemperature",
+			inside:         sensor.SensorData{Temperature: 21.0},
+			outside:        sensor.SensorData{Temperature: 7.0},
+			fanConfig:      sensor.FanConfig{MinTempOutside: 10.0},
+			expectedResult: sensor.ResultData{ShouldBeOn: false, Reason: sensor.ReasonOutsideTempTooLow},
+			lastResult:     sensor.ResultData{ShouldBeOn: true},
+		},
+		{
+			name:           "LowInsideHumidity",
+			inside:         sensor.SensorData{Humidity: 40.0},
+			fanConfig:      sensor.FanConfig{MinHumidityInside: 50.0},
+			expectedResult: sensor.ResultData{ShouldBeOn: false, Reason: sensor.ReasonInsideHumidityTooLow},
+			lastResult:     sensor.ResultData{ShouldBeOn: true},
+		},
+		{
+			name:           "DewPointUnderHysteresis",
+			inside:         sensor.SensorData{DewPoint: 12.0},
+			outside:        sensor.SensorData{DewPoint: 10.0},
+			fanConfig:      sensor.FanConfig{MinDiff: 3.0, Hysteresis: 1.0},
+			expectedResult: sensor.ResultData{ShouldBeOn: false, Reason: sensor.ReasonDewPointUnderHyst},
+			lastResult:     sensor.ResultData{ShouldBeOn: true},
+		},
+		{
+			name:           "DewPointOverHysteresis",
+			inside:         sensor.SensorData{DewPoint: 15.0},
+			outside:        sensor.SensorData{DewPoint: 10.0},
+			fanConfig:      sensor.FanConfig{MinDiff: 3.0, Hysteresis: 1.0},
+			expectedResult: sensor.ResultData{ShouldBeOn: true, Reason: sensor.ReasonDewPointOverHyst},
+			lastResult:     sensor.ResultData{ShouldBeOn: false},
+		},
+		{
+			name:           "DewPointInBetweenKeepsOff",
+			inside:         sensor.SensorData{DewPoint: 13.5},
+			outside:        sensor.SensorData{DewPoint: 10.0},
+			fanConfig:      sensor.FanConfig{MinDiff: 3.0, Hysteresis: 1.0},
+			expectedResult: sensor.ResultData{ShouldBeOn: false, Reason: sensor.ReasonDewPointInBetween},
+			lastResult:     sensor.ResultData{ShouldBeOn: false},
+		},
+		{
+			name:           "DewPointInBetweenKeepsOn",
+			inside:         sensor.SensorData{DewPoint: 13.5},
+			outside:        sensor.SensorData{DewPoint: 10.0},
+			fanConfig:      sensor.FanConfig{MinDiff: 3.0, Hysteresis: 1.0},
+			expectedResult: sensor.ResultData{ShouldBeOn: true, Reason: sensor.ReasonDewPointInBetween},
+			lastResult:     sensor.ResultData{ShouldBeOn: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &DewPointController{}
+			c.Compute(Input{Inside: tt.inside, Outside: tt.outside, Config: tt.fanConfig}, &tt.lastResult)
+
+			if !reflect.DeepEqual(tt.lastResult, tt.expectedResult) {
+				t.Errorf("expected ResultData = %+v, got %+v", tt.expectedResult, tt.lastResult)
+			}
+		})
+	}
+}
+
+// history creates a SensorDataList with dew points that change by the given rate per hour over one hour.
+func history(start time.Time, dewPoint float64, ratePerHour float64) *sensor.SensorDataList {
+	list := sensor.NewSensorDataStore(20)
+	for i := 0; i <= 6; i++ {
+		offset := time.Duration(i*10) * time.Minute
+		list.AddSensorData(sensor.SensorData{
+			DewPoint: dewPoint + ratePerHour*offset.Hours(),
+			Scanned:  start.Add(offset),
+		})
+	}
+	return list
+}
+
+func TestDewPointControllerTrend(t *testing.T) {
+	start := time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+	fanConfig := sensor.FanConfig{MinDiff: 3.0, Hysteresis: 1.0, MinTrend: 0.5}
+	tests := []struct {
+		name           string
+		insideRate     float64
+		outsideRate    float64
+		lastResult     sensor.ResultData
+		expectedResult sensor.ResultData
+	}{
+		{
+			name:           "RisingDifferenceSwitchesOn",
+			insideRate:     1.0,
+			outsideRate:    0.0,
+			lastResult:     sensor.ResultData{ShouldBeOn: false},
+			expectedResult: sensor.ResultData{ShouldBeOn: true, DpTrend: 1.0, Reason: sensor.ReasonDewPointRising},
+		},
+		{
+			name:           "FallingDifferenceSwitchesOff",
+			insideRate:     0.0,
+			outsideRate:    1.5,
+			lastResult:     sensor.ResultData{ShouldBeOn: true},
+			expectedResult: sensor.ResultData{ShouldBeOn: false, DpTrend: -1.5, Reason: sensor.ReasonDewPointFalling},
+		},
+		{
+			name:           "SmallTrendKeepsState",
+			insideRate:     0.3,
+			outsideRate:    0.0,
+			lastResult:     sensor.ResultData{ShouldBeOn: true},
+			expectedResult: sensor.ResultData{ShouldBeOn: true, DpTrend: 0.3, Reason: sensor.ReasonDewPointInBetween},
+		},
+		{
+			name:           "ParallelTrendKeepsState",
+			insideRate:     2.0,
+			outsideRate:    2.0,
+			lastResult:     sensor.ResultData{ShouldBeOn: false},
+			expectedResult: sensor.ResultData{ShouldBeOn: false, DpTrend: 0, Reason: sensor.ReasonDewPointInBetween},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &DewPointController{}
+			input := Input{
+				Inside:         sensor.SensorData{DewPoint: 13.5},
+				Outside:        sensor.SensorData{DewPoint: 10.0},
+				InsideHistory:  history(start, 13.0, tt.insideRate),
+				OutsideHistory: history(start, 10.0, tt.outsideRate),
+				Config:         fanConfig,
+				Now:            start.Add(time.Hour),
+			}
+			c.Compute(input, &tt.lastResult)
+
+			if !reflect.DeepEqual(tt.lastResult, tt.expectedResult) {
+				t.Errorf("expected ResultData = %+v, got %+v", tt.expectedResult, tt.lastResult)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"time"
+)
+
+// FaultMonitor classifies a disagreement between the commanded and the sensed fan state with the position of
+// the override switch and tracks how long a fault has lasted. Every disagreement, including a hardware override,
+// is only reported after it has lasted for the configured delay, since the sensed state follows the relay with a
+// short lag.
+type FaultMonitor struct {
+	fault      sensor.Fault
+	faultSince time.Time
+}
+
+// Update compares the commanded and the sensed fan state and returns the current fault and whether it has
+// changed since the last update. Without a sensed switch position, every disagreement is reported as a
+// hardware override, because it can't be told apart from a fault.
+func (m *FaultMonitor) Update(commanded, sensed bool, position sensor.SwitchPosition, now time.Time,
+	delay time.Duration) (sensor.Fault, bool) {
+	fault := classifyFault(commanded, sensed, position)
+	if fault == sensor.FaultNone {
+		m.faultSince = time.Time{}
+	} else if m.faultSince.IsZero() {
+		m.faultSince = now
+	}
+	if fault != sensor.FaultNone && now.Sub(m.faultSince) < delay {
+		fault = sensor.FaultNone
+	}
+	changed := fault != m.fault
+	m.fault = fault
+	return fault, changed
+}
+
+// Fault returns the fault of the last update.
+func (m *FaultMonitor) Fault() sensor.Fault {
+	return m.fault
+}
+
+// FaultDuration returns how long the sensed fan state has disagreed with the commanded state, including the delay
+// before the fault is raised. It is 0 if both agree.
+func (m *FaultMonitor) FaultDuration(now time.Time) time.Duration {
+	if m.faultSince.IsZero() {
+		return 0
+	}
+	return now.Sub(m.faultSince)
+}
+
+// classifyFault determines the fault from the commanded and sensed fan state and the switch position. The
+// switch in the on or off position determines the expected state instead of the commanded state. If the fan
+// has power although it shouldn't have, the relay is stuck. If it has no power although it should have, the
+// fan circuit is broken, e.g. by a blown fuse or a relay that doesn't close.
+func classifyFault(commanded, sensed bool, position sensor.SwitchPosition) sensor.Fault {
+	expected := commanded
+	switch position {
+	case sensor.SwitchUnknown:
+		if sensed != commanded {
+			return sensor.FaultHardwareOverride
+		}
+		return sensor.FaultNone
+	case sensor.SwitchOn:
+		expected = true
+	case sensor.SwitchOff:
+		expected = false
+	}
+	switch {
+	case sensed == expected && expected != commanded:
+		return sensor.FaultHardwareOverride
+	case sensed == expected:
+		return sensor.FaultNone
+	case sensed:
+		return sensor.FaultRelay
+	default:
+		return sensor.FaultFan
+	}
+}
@@ -0,0 +1,110 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"testing"
+	"time"
+)
+
+func TestClassifyFault(t *testing.T) {
+	tests := []struct {
+		name      string
+		commanded bool
+		sensed    bool
+		position  sensor.SwitchPosition
+		expected  sensor.Fault
+	}{
+		{name: "AutoOn", commanded: true, sensed: true, position: sensor.SwitchAuto, expected: sensor.FaultNone},
+		{name: "AutoOff", commanded: false, sensed: false, position: sensor.SwitchAuto, expected: sensor.FaultNone},
+		{name: "StuckRelay", commanded: false, sensed: true, position: sensor.SwitchAuto, expected: sensor.FaultRelay},
+		{name: "BlownFuse", commanded: true, sensed: false, position: sensor.SwitchAuto, expected: sensor.FaultFan},
+		{name: "SwitchOn", commanded: false, sensed: true, position: sensor.SwitchOn,
+			expected: sensor.FaultHardwareOverride},
+		{name: "SwitchOff", commanded: true, sensed: false, position: sensor.SwitchOff,
+			expected: sensor.FaultHardwareOverride},
+		{name: "SwitchOnWithoutPower", commanded: false, sensed: false, position: sensor.SwitchOn,
+			expected: sensor.FaultFan},
+		{name: "SwitchOffWithPower", commanded: true, sensed: true, position: sensor.SwitchOff,
+			expected: sensor.FaultRelay},
+		{name: "SwitchOnAsCommanded", commanded: true, sensed: true, position: sensor.SwitchOn,
+			expected: sensor.FaultNone},
+		{name: "UnknownMismatch", commanded: true, sensed: false, position: sensor.SwitchUnknown,
+			expected: sensor.FaultHardwareOverride},
+		{name: "UnknownMatch", commanded: true, sensed: true, position: sensor.SwitchUnknown,
+			expected: sensor.FaultNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyFault(tt.commanded, tt.sensed, tt.position); got != tt.expected {
+				t.Errorf("expected %s, got %s", sensor.FaultName[tt.expected], sensor.FaultName[got])
+			}
+		})
+	}
+}
+
+func TestFaultMonitor(t *testing.T) {
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	delay := time.Minute
+	steps := []struct {
+		name             string
+		offset           time.Duration
+		commanded        bool
+		sensed           bool
+		position         sensor.SwitchPosition
+		expectedFault    sensor.Fault
+		expectedChanged  bool
+		expectedDuration time.Duration
+	}{
+		{name: "fan follows", commanded: true, sensed: true, position: sensor.SwitchAuto,
+			expectedFault: sensor.FaultNone},
+		{name: "fan stops", offset: 10 * time.Second, commanded: true, sensed: false, position: sensor.SwitchAuto,
+			expectedFault: sensor.FaultNone},
+		{name: "fault within delay", offset: 40 * time.Second, commanded: true, sensed: false,
+			position: sensor.SwitchAuto, expectedFault: sensor.FaultNone, expectedDuration: 30 * time.Second},
+		{name: "fault after delay", offset: 70 * time.Second, commanded: true, sensed: false,
+			position: sensor.SwitchAuto, expectedFault: sensor.FaultFan, expectedChanged: true,
+			expectedDuration: time.Minute},
+		{name: "fault persists", offset: 80 * time.Second, commanded: true, sensed: false,
+			position: sensor.SwitchAuto, expectedFault: sensor.FaultFan, expectedDuration: 70 * time.Second},
+		{name: "fan runs again", offset: 90 * time.Second, commanded: true, sensed: true,
+			position: sensor.SwitchAuto, expectedFault: sensor.FaultNone, expectedChanged: true},
+		{name: "hardware override within delay", offset: 100 * time.Second, commanded: true, sensed: false,
+			position: sensor.SwitchOff, expectedFault: sensor.FaultNone},
+		{name: "hardware override after delay", offset: 170 * time.Second, commanded: true, sensed: false,
+			position: sensor.SwitchOff, expectedFault: sensor.FaultHardwareOverride, expectedChanged: true,
+			expectedDuration: 70 * time.Second},
+		{name: "hardware override ends", offset: 180 * time.Second, commanded: true, sensed: true,
+			position: sensor.SwitchAuto, expectedFault: sensor.FaultNone, expectedChanged: true},
+	}
+
+	monitor := &FaultMonitor{}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			now := start.Add(step.offset)
+			fault, changed := monitor.Update(step.commanded, step.sensed, step.position, now, delay)
+			if fault != step.expectedFault || changed != step.expectedChanged {
+				t.Errorf("expected %s (changed %v), got %s (changed %v)", sensor.FaultName[step.expectedFault],
+					step.expectedChanged, sensor.FaultName[fault], changed)
+			}
+			if got := monitor.FaultDuration(now); got != step.expectedDuration {
+				t.Errorf("expected fault duration %s, got %s", step.expectedDuration, got)
+			}
+		})
+	}
+}
+
+func TestFaultMonitorSenseLag(t *testing.T) {
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	monitor := &FaultMonitor{}
+	// without switch pins, the sensed state follows every switch one update later
+	sensed := false
+	for i, commanded := range []bool{false, true, true, false, false, true, false, true} {
+		fault, changed := monitor.Update(commanded, sensed, sensor.SwitchUnknown,
+			start.Add(time.Duration(i)*10*time.Second), time.Minute)
+		if fault != sensor.FaultNone || changed {
+			t.Errorf("update %d: unexpected %s (changed %v)", i, sensor.FaultName[fault], changed)
+		}
+		sensed = commanded
+	}
+}
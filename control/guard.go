@@ -0,0 +1,77 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"time"
+
+	"github.com/d2r2/go-logger"
+)
+
+var lg = logger.NewPackageLogger("control", logger.InfoLevel)
+
+// SwitchGuard sits between the decision of the controller and the fan output. It enforces a minimum run time
+// and a minimum pause time, so that jittering sensor values near a threshold can't make the relay short-cycle.
+// It also counts the switch events.
+type SwitchGuard struct {
+	isOn        bool
+	lastSwitch  time.Time
+	switchCount int
+}
+
+// Apply checks the decision in result against the minimum run and pause time of the configuration. If the fan
+// would switch too early, the previous state is kept and the reason is set to ReasonMinRunTime or
+// ReasonMinPauseTime. A remote override and a safety stop are never delayed. The checks are recorded in trace,
+// which may be nil.
+func (g *SwitchGuard) Apply(result *sensor.ResultData, cfg sensor.FanConfig, now time.Time, trace *Decision) {
+	if result.ShouldBeOn == g.isOn {
+		return
+	}
+	isOverride := result.Reason == sensor.ReasonSoftOverrideOn || result.Reason == sensor.ReasonSoftOverrideOff
+	if !isOverride && !g.lastSwitch.IsZero() {
+		elapsed := now.Sub(g.lastSwitch).Minutes()
+		if g.isOn && !isSafetyStop(result.Reason) &&
+			!trace.Check("run time", elapsed, GreaterOrEqual, float64(cfg.MinOnMinutes)) {
+			result.ShouldBeOn = true
+			result.Reason = sensor.ReasonMinRunTime
+			return
+		}
+		if !g.isOn && !trace.Check("pause time", elapsed, GreaterOrEqual, float64(cfg.MinOffMinutes)) {
+			result.ShouldBeOn = false
+			result.Reason = sensor.ReasonMinPauseTime
+			return
+		}
+	}
+	g.isOn = result.ShouldBeOn
+	g.lastSwitch = now
+	g.switchCount++
+	lg.Infof("Fan switched %s (%s) - switch #%d", onOff(g.isOn), result.ReasonText(), g.switchCount)
+}
+
+// isSafetyStop reports whether the fan is switched off for a reason that must not wait for the minimum run time:
+// missing sensor data, a forbidden window of the schedule or a temperature below its minimum.
+func isSafetyStop(reason sensor.Reason) bool {
+	switch reason {
+	case sensor.ReasonNoData, sensor.ReasonNoEnoughData, sensor.ReasonBlockedBySchedule,
+		sensor.ReasonInsideTempTooLow, sensor.ReasonOutsideTempTooLow:
+		return true
+	}
+	return false
+}
+
+// SwitchCount returns the number of switch events since the start of the application.
+func (g *SwitchGuard) SwitchCount() int {
+	return g.switchCount
+}
+
+// LastSwitch returns the time of the last switch event. The time is zero if the fan has never been switched.
+func (g *SwitchGuard) LastSwitch() time.Time {
+	return g.lastSwitch
+}
+
+// onOff returns the text representation of a fan state.
+func onOff(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
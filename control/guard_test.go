@@ -0,0 +1,95 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"testing"
+	"time"
+)
+
+func TestSwitchGuard(t *testing.T) {
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	cfg := sensor.FanConfig{MinOnMinutes: 10, MinOffMinutes: 5}
+	steps := []struct {
+		name           string
+		offset         time.Duration
+		decision       sensor.ResultData
+		expectedOn     bool
+		expectedReason sensor.Reason
+		expectedCount  int
+	}{
+		{
+			name:           "first switch on is not delayed",
+			offset:         0,
+			decision:       sensor.ResultData{ShouldBeOn: true, Reason: sensor.ReasonDewPointOverHyst},
+			expectedOn:     true,
+			expectedReason: sensor.ReasonDewPointOverHyst,
+			expectedCount:  1,
+		},
+		{
+			name:           "switch off within minimum run time is held",
+			offset:         3 * time.Minute,
+			decision:       sensor.ResultData{ShouldBeOn: false, Reason: sensor.ReasonDewPointUnderHyst},
+			expectedOn:     true,
+			expectedReason: sensor.ReasonMinRunTime,
+			expectedCount:  1,
+		},
+		{
+			name:           "same state is passed through",
+			offset:         5 * time.Minute,
+			decision:       sensor.ResultData{ShouldBeOn: true, Reason: sensor.ReasonDewPointInBetween},
+			expectedOn:     true,
+			expectedReason: sensor.ReasonDewPointInBetween,
+			expectedCount:  1,
+		},
+		{
+			name:           "switch off after minimum run time",
+			offset:         10 * time.Minute,
+			decision:       sensor.ResultData{ShouldBeOn: false, Reason: sensor.ReasonDewPointUnderHyst},
+			expectedOn:     false,
+			expectedReason: sensor.ReasonDewPointUnderHyst,
+			expectedCount:  2,
+		},
+		{
+			name:           "switch on within minimum pause time is held",
+			offset:         12 * time.Minute,
+			decision:       sensor.ResultData{ShouldBeOn: true, Reason: sensor.ReasonDewPointOverHyst},
+			expectedOn:     false,
+			expectedReason: sensor.ReasonMinPauseTime,
+			expectedCount:  2,
+		},
+		{
+			name:           "remote override is not delayed",
+			offset:         13 * time.Minute,
+			decision:       sensor.ResultData{ShouldBeOn: true, Reason: sensor.ReasonSoftOverrideOn},
+			expectedOn:     true,
+			expectedReason: sensor.ReasonSoftOverrideOn,
+			expectedCount:  3,
+		},
+		{
+			name:           "safety stop within minimum run time is not held",
+			offset:         14 * time.Minute,
+			decision:       sensor.ResultData{ShouldBeOn: false, Reason: sensor.ReasonNoEnoughData},
+			expectedOn:     false,
+			expectedReason: sensor.ReasonNoEnoughData,
+			expectedCount:  4,
+		},
+	}
+
+	guard := &SwitchGuard{}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			result := step.decision
+			guard.Apply(&result, cfg, start.Add(step.offset), nil)
+			if result.ShouldBeOn != step.expectedOn {
+				t.Errorf("expected ShouldBeOn %v, got %v", step.expectedOn, result.ShouldBeOn)
+			}
+			if result.Reason != step.expectedReason {
+				t.Errorf("expected reason %s, got %s",
+					sensor.ReasonName[step.expectedReason], sensor.ReasonName[result.Reason])
+			}
+			if guard.SwitchCount() != step.expectedCount {
+				t.Errorf("expected switch count %d, got %d", step.expectedCount, guard.SwitchCount())
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"math"
+	"time"
+)
+
+const (
+	// maxMoldStep is the longest gap between two readings that is integrated. Longer gaps, e.g. after a
+	// restart or a sensor outage, are skipped instead of extrapolating the last reading.
+	maxMoldStep = 30 * time.Minute
+	// maxMoldIndex is the highest value of the mold index (heavy, tight growth).
+	maxMoldIndex = 6.0
+)
+
+// MoldIndex implements the VTT mold growth model by Hukka and Viitanen for pine sapwood. The index ranges
+// from 0 (no growth) to 6 (heavy growth), a value of 3 means the mold is visible to the naked eye. The index
+// grows while the relative humidity is above a critical level that depends on the temperature, and it slowly
+// declines during dry periods.
+type MoldIndex struct {
+	Index   float64   `json:"index"`
+	Updated time.Time `json:"updated"`
+	// DryHours is the time in hours since the conditions became unfavorable for mold growth.
+	DryHours float64 `json:"dry_hours"`
+}
+
+// Update integrates the mold index from the last update to the time of the given reading. Readings that are
+// not newer than the last update are ignored.
+func (m *MoldIndex) Update(temperature, humidity float64, scanned time.Time) {
+	if scanned.IsZero() || !scanned.After(m.Updated) {
+		return
+	}
+	step := scanned.Sub(m.Updated)
+	m.Updated = scanned
+	if step > maxMoldStep {
+		return
+	}
+	hours := step.Hours()
+	if temperature <= 0 || temperature >= 50 || humidity < CriticalHumidity(temperature) {
+		m.DryHours += hours
+		m.Index = math.Max(m.Index+moldDecline(m.DryHours)*hours, 0)
+		return
+	}
+	m.DryHours = 0
+	m.Index = math.Min(m.Index+moldGrowth(m.Index, temperature, humidity)*hours/24, maxMoldIndex)
+}
+
+// Level returns a short description of the mold index for the display.
+func (m *MoldIndex) Level() string {
+	switch {
+	case m.Index < 1:
+		return "none"
+	case m.Index < 3:
+		return "micro"
+	case m.Index < 4:
+		return "visible"
+	default:
+		return "heavy"
+	}
+}
+
+// Adjust returns the fan configuration with the minimal dew point difference lowered to MoldMinDiff, if the
+// mold index has reached the configured limit. This makes the fan run more often while mold is growing.
+// A MoldIndexLimit of 0 disables the adjustment. The absolute humidity controller doesn't use MinDiff, so the
+// configuration rejects a limit together with it.
+func (m *MoldIndex) Adjust(cfg sensor.FanConfig) sensor.FanConfig {
+	if cfg.MoldIndexLimit > 0 && m.Index >= cfg.MoldIndexLimit && cfg.MoldMinDiff < cfg.MinDiff {
+		cfg.MinDiff = cfg.MoldMinDiff
+	}
+	return cfg
+}
+
+// CriticalHumidity returns the relative humidity in % above which mold can grow at the given temperature.
+func CriticalHumidity(temperature float64) float64 {
+	if temperature > 20 {
+		return 80
+	}
+	t := temperature
+	return -0.00267*t*t*t + 0.160*t*t - 3.13*t + 100
+}
+
+// moldGrowth returns the growth rate of the mold index per day under favorable conditions.
+func moldGrowth(index, temperature, humidity float64) float64 {
+	lnT := math.Log(temperature)
+	lnRH := math.Log(humidity)
+	// weeks until growth starts (index 1) and until the mold is visible (index 3)
+	tm := math.Exp(-0.68*lnT - 13.9*lnRH + 66.02)
+	tv := math.Exp(-0.74*lnT - 12.72*lnRH + 61.50)
+	k1 := 1.0
+	if index >= 1 && tv > tm {
+		k1 = 2 / (tv/tm - 1)
+	}
+	// the maximum index that can be reached at this humidity
+	ratio := (CriticalHumidity(temperature) - humidity) / (CriticalHumidity(temperature) - 100)
+	maxIndex := 1 + 7*ratio - 2*ratio*ratio
+	k2 := math.Max(1-math.Exp(2.3*(index-maxIndex)), 0)
+	return k1 * k2 / (7 * tm)
+}
+
+// moldDecline returns the change of the mold index per hour after the given number of hours under
+// unfavorable conditions.
+func moldDecline(dryHours float64) float64 {
+	switch {
+	case dryHours <= 6:
+		return -0.032
+	case dryHours <= 24:
+		return 0
+	default:
+		return -0.016
+	}
+}
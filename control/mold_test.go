@@ -0,0 +1,111 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCriticalHumidity(t *testing.T) {
+	tests := []struct {
+		name        string
+		temperature float64
+		expected    float64
+	}{
+		{name: "Cold", temperature: 5.0, expected: 88.0},
+		{name: "Mild", temperature: 10.0, expected: 82.0},
+		{name: "Limit", temperature: 20.0, expected: 80.0},
+		{name: "Warm", temperature: 25.0, expected: 80.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CriticalHumidity(tt.temperature)
+			if math.Abs(got-tt.expected) > 0.5 {
+				t.Errorf("CriticalHumidity(%.1f) = %.2f, want %.1f", tt.temperature, got, tt.expected)
+			}
+		})
+	}
+}
+
+// simulateMold updates a mold index every 10 minutes for the given number of days with constant conditions.
+func simulateMold(m *MoldIndex, start time.Time, days int, temperature, humidity float64) time.Time {
+	now := start
+	for i := 0; i < days*24*6; i++ {
+		now = now.Add(10 * time.Minute)
+		m.Update(temperature, humidity, now)
+	}
+	return now
+}
+
+func TestMoldIndexUpdate(t *testing.T) {
+	start := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		start       float64
+		days        int
+		temperature float64
+		humidity    float64
+		minIndex    float64
+		maxIndex    float64
+	}{
+		{name: "DryStaysZero", days: 30, temperature: 20.0, humidity: 60.0, minIndex: 0, maxIndex: 0},
+		{name: "WetStartsGrowth", days: 14, temperature: 20.0, humidity: 97.0, minIndex: 1.0, maxIndex: 2.0},
+		{name: "WetReachesVisible", days: 42, temperature: 20.0, humidity: 97.0, minIndex: 3.0, maxIndex: 6.0},
+		{name: "SlightlyHumidIsLimited", days: 365, temperature: 20.0, humidity: 82.0, minIndex: 1.3, maxIndex: 1.7},
+		{name: "ColdIsSlow", days: 14, temperature: 5.0, humidity: 97.0, minIndex: 0.1, maxIndex: 1.0},
+		{name: "DryDeclines", start: 3.0, days: 7, temperature: 20.0, humidity: 60.0, minIndex: 0.5, maxIndex: 1.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &MoldIndex{Index: tt.start, Updated: start}
+			simulateMold(m, start, tt.days, tt.temperature, tt.humidity)
+
+			if m.Index < tt.minIndex || m.Index > tt.maxIndex {
+				t.Errorf("expected index between %.2f and %.2f, got %.2f", tt.minIndex, tt.maxIndex, m.Index)
+			}
+		})
+	}
+}
+
+func TestMoldIndexSkipsGaps(t *testing.T) {
+	start := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	m := &MoldIndex{Index: 2.0, Updated: start}
+
+	m.Update(20.0, 97.0, start.Add(48*time.Hour))
+	if m.Index != 2.0 || !m.Updated.Equal(start.Add(48*time.Hour)) {
+		t.Errorf("expected gap to be skipped, got %+v", m)
+	}
+	m.Update(20.0, 97.0, start)
+	if m.Index != 2.0 || !m.Updated.Equal(start.Add(48*time.Hour)) {
+		t.Errorf("expected older reading to be ignored, got %+v", m)
+	}
+}
+
+func TestMoldIndexAdjust(t *testing.T) {
+	cfg := sensor.FanConfig{MinDiff: 4.0, MoldIndexLimit: 1.0, MoldMinDiff: 2.0}
+	tests := []struct {
+		name     string
+		index    float64
+		cfg      sensor.FanConfig
+		expected float64
+	}{
+		{name: "BelowLimit", index: 0.5, cfg: cfg, expected: 4.0},
+		{name: "AtLimit", index: 1.0, cfg: cfg, expected: 2.0},
+		{name: "Disabled", index: 3.0, cfg: sensor.FanConfig{MinDiff: 4.0, MoldMinDiff: 2.0}, expected: 4.0},
+		{name: "NeverRaises", index: 3.0, cfg: sensor.FanConfig{MinDiff: 1.5, MoldIndexLimit: 1.0, MoldMinDiff: 2.0},
+			expected: 1.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &MoldIndex{Index: tt.index}
+			got := m.Adjust(tt.cfg)
+			if got.MinDiff != tt.expected {
+				t.Errorf("expected MinDiff %.1f, got %.1f", tt.expected, got.MinDiff)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"math"
+)
+
+// ProportionalName is the name of the proportional controller as used in the configuration file.
+const ProportionalName = "proportional"
+
+// ProportionalController switches the fan like the DewPointController and additionally scales the fan speed
+// with the dew point surplus above MinDiff + Hysteresis. The speed starts with MinSpeed and reaches 100% when
+// the surplus is FullSpeedDiff or more.
+type ProportionalController struct {
+	DewPointController
+}
+
+// Name returns the name of the strategy as used in the configuration file.
+func (c *ProportionalController) Name() string {
+	return ProportionalName
+}
+
+// Compute evaluates the dew point difference and calculates the fan speed.
+func (c *ProportionalController) Compute(input Input, result *sensor.ResultData) {
+	c.DewPointController.Compute(input, result)
+	cfg := input.Config
+	surplus := input.Inside.DewPoint - input.Outside.DewPoint - (cfg.MinDiff + cfg.Hysteresis)
+	result.Speed = proportionalSpeed(surplus, cfg.MinSpeed, cfg.FullSpeedDiff)
+}
+
+// proportionalSpeed scales the surplus linearly between minSpeed (surplus <= 0) and 100% (surplus >= fullSpeedDiff).
+func proportionalSpeed(surplus float64, minSpeed int, fullSpeedDiff float64) int {
+	minSpeed = min(max(minSpeed, 1), 100)
+	if surplus <= 0 {
+		return minSpeed
+	}
+	if fullSpeedDiff <= 0 || surplus >= fullSpeedDiff {
+		return 100
+	}
+	return minSpeed + int(math.Round(float64(100-minSpeed)*surplus/fullSpeedDiff))
+}
@@ -0,0 +1,95 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"reflect"
+	"testing"
+)
+
+func TestProportionalSpeed(t *testing.T) {
+	tests := []struct {
+		name          string
+		surplus       float64
+		minSpeed      int
+		fullSpeedDiff float64
+		expected      int
+	}{
+		{name: "negative surplus", surplus: -0.5, minSpeed: 30, fullSpeedDiff: 4, expected: 30},
+		{name: "zero surplus", surplus: 0, minSpeed: 30, fullSpeedDiff: 4, expected: 30},
+		{name: "half surplus", surplus: 2, minSpeed: 30, fullSpeedDiff: 4, expected: 65},
+		{name: "quarter surplus", surplus: 1, minSpeed: 20, fullSpeedDiff: 4, expected: 40},
+		{name: "full surplus", surplus: 4, minSpeed: 30, fullSpeedDiff: 4, expected: 100},
+		{name: "more than full surplus", surplus: 8, minSpeed: 30, fullSpeedDiff: 4, expected: 100},
+		{name: "no full speed diff", surplus: 0.1, minSpeed: 30, fullSpeedDiff: 0, expected: 100},
+		{name: "min speed zero is raised to 1", surplus: 0, minSpeed: 0, fullSpeedDiff: 4, expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := proportionalSpeed(tt.surplus, tt.minSpeed, tt.fullSpeedDiff)
+			if result != tt.expected {
+				t.Errorf("proportionalSpeed(%v, %v, %v) = %v, expected %v",
+					tt.surplus, tt.minSpeed, tt.fullSpeedDiff, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProportionalController(t *testing.T) {
+	fanConfig := sensor.FanConfig{MinDiff: 3.0, Hysteresis: 1.0, MinSpeed: 40, FullSpeedDiff: 3.0}
+	tests := []struct {
+		name           string
+		inside         sensor.SensorData
+		outside        sensor.SensorData
+		lastResult     sensor.ResultData
+		expectedResult sensor.ResultData
+		expectedSpeed  int
+	}{
+		{
+			name:           "OffBelowMinDiff",
+			inside:         sensor.SensorData{DewPoint: 11.0},
+			outside:        sensor.SensorData{DewPoint: 10.0},
+			lastResult:     sensor.ResultData{ShouldBeOn: true, Speed: 80},
+			expectedResult: sensor.ResultData{ShouldBeOn: false, Speed: 40, Reason: sensor.ReasonDewPointUnderHyst},
+			expectedSpeed:  0,
+		},
+		{
+			name:           "MinSpeedInBetween",
+			inside:         sensor.SensorData{DewPoint: 13.5},
+			outside:        sensor.SensorData{DewPoint: 10.0},
+			lastResult:     sensor.ResultData{ShouldBeOn: true},
+			expectedResult: sensor.ResultData{ShouldBeOn: true, Speed: 40, Reason: sensor.ReasonDewPointInBetween},
+			expectedSpeed:  40,
+		},
+		{
+			name:           "ScaledSpeedAboveHysteresis",
+			inside:         sensor.SensorData{DewPoint: 15.5},
+			outside:        sensor.SensorData{DewPoint: 10.0},
+			lastResult:     sensor.ResultData{ShouldBeOn: false},
+			expectedResult: sensor.ResultData{ShouldBeOn: true, Speed: 70, Reason: sensor.ReasonDewPointOverHyst},
+			expectedSpeed:  70,
+		},
+		{
+			name:           "FullSpeed",
+			inside:         sensor.SensorData{DewPoint: 18.0},
+			outside:        sensor.SensorData{DewPoint: 10.0},
+			lastResult:     sensor.ResultData{ShouldBeOn: false},
+			expectedResult: sensor.ResultData{ShouldBeOn: true, Speed: 100, Reason: sensor.ReasonDewPointOverHyst},
+			expectedSpeed:  100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &ProportionalController{}
+			c.Compute(Input{Inside: tt.inside, Outside: tt.outside, Config: fanConfig}, &tt.lastResult)
+
+			if !reflect.DeepEqual(tt.lastResult, tt.expectedResult) {
+				t.Errorf("expected ResultData = %+v, got %+v", tt.expectedResult, tt.lastResult)
+			}
+			if tt.lastResult.FanSpeed() != tt.expectedSpeed {
+				t.Errorf("expected fan speed %d, got %d", tt.expectedSpeed, tt.lastResult.FanSpeed())
+			}
+		})
+	}
+}
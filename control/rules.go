@@ -0,0 +1,383 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxRuleReasonLength is the maximal length of a rule reason, so that it fits on the LCD.
+const maxRuleReasonLength = 18
+
+// ruleVariables returns the values of the variables that can be used in rule expressions.
+func ruleVariables(input Input) map[string]float64 {
+	return map[string]float64{
+		"inside.temperature":  input.Inside.Temperature,
+		"inside.humidity":     input.Inside.Humidity,
+		"inside.dewpoint":     input.Inside.DewPoint,
+		"inside.absHumidity":  input.Inside.AbsHumidity,
+		"inside.mixingRatio":  input.Inside.MixingRatio,
+		"outside.temperature": input.Outside.Temperature,
+		"outside.humidity":    input.Outside.Humidity,
+		"outside.dewpoint":    input.Outside.DewPoint,
+		"outside.absHumidity": input.Outside.AbsHumidity,
+		"outside.mixingRatio": input.Outside.MixingRatio,
+	}
+}
+
+// Rule is a condition over the sensor values that switches the fan on or off with a custom reason. It is
+// defined as "<condition> => on|off "reason"", e.g. `outside.humidity > 95 => off "fog"`. The condition
+// supports the variables inside.* and outside.* (temperature, humidity, dewpoint, absHumidity and
+// mixingRatio), numbers, the arithmetic operators + - * /, the comparisons < <= > >= == != and the logical
+// operators && || ! with parentheses.
+type Rule struct {
+	Source    string
+	On        bool
+	Reason    string
+	condition func(vars map[string]float64) bool
+}
+
+// Rules is an ordered list of rules. The first matching rule decides.
+type Rules []Rule
+
+// ParseRules parses and validates the rule definitions.
+func ParseRules(sources []string) (Rules, error) {
+	rules := make(Rules, 0, len(sources))
+	for i, source := range sources {
+		rule, err := ParseRule(source)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// ParseRule parses and validates a single rule definition.
+func ParseRule(source string) (Rule, error) {
+	rule := Rule{Source: source}
+	condition, action, found := strings.Cut(source, "=>")
+	if !found {
+		return rule, fmt.Errorf("missing '=>' in '%s'", source)
+	}
+	action = strings.TrimSpace(action)
+	state, reason, _ := strings.Cut(action, " ")
+	switch strings.ToLower(state) {
+	case "on":
+		rule.On = true
+	case "off":
+		rule.On = false
+	default:
+		return rule, fmt.Errorf("invalid action '%s', must be on or off", state)
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		rule.Reason = "rule " + strings.ToLower(state)
+	} else {
+		unquoted, err := unquoteReason(reason)
+		if err != nil {
+			return rule, err
+		}
+		rule.Reason = unquoted
+	}
+	if len(rule.Reason) > maxRuleReasonLength {
+		return rule, fmt.Errorf("reason '%s' is too long, must be at most %d characters", rule.Reason,
+			maxRuleReasonLength)
+	}
+
+	p := &ruleParser{}
+	if err := p.tokenize(condition); err != nil {
+		return rule, err
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return rule, err
+	}
+	if p.pos < len(p.tokens) {
+		return rule, fmt.Errorf("unexpected '%s'", p.tokens[p.pos])
+	}
+	if e.cond == nil {
+		return rule, fmt.Errorf("'%s' is not a condition", strings.TrimSpace(condition))
+	}
+	rule.condition = e.cond
+	return rule, nil
+}
+
+// unquoteReason removes the double or single quotes around a reason.
+func unquoteReason(reason string) (string, error) {
+	if len(reason) >= 2 && (reason[0] == '"' || reason[0] == '\'') && reason[len(reason)-1] == reason[0] {
+		return reason[1 : len(reason)-1], nil
+	}
+	return "", fmt.Errorf("reason %s must be quoted", reason)
+}
+
+// Evaluate checks the rules in order. The first matching rule sets the fan state and the reason of the result
+// and true is returned. If no rule matches, the result is unchanged and false is returned.
+func (rules Rules) Evaluate(input Input, result *sensor.ResultData) bool {
+	if len(rules) == 0 {
+		return false
+	}
+	vars := ruleVariables(input)
+	for _, rule := range rules {
+		if input.Trace.CheckTrue("rule '"+rule.Reason+"'", rule.condition(vars)) {
+			result.ShouldBeOn = rule.On
+			result.Reason = sensor.ReasonRule
+			result.RuleReason = rule.Reason
+			return true
+		}
+	}
+	return false
+}
+
+// ruleExpr is a parsed expression. Either num (for arithmetic expressions) or cond (for conditions) is set.
+type ruleExpr struct {
+	num  func(vars map[string]float64) float64
+	cond func(vars map[string]float64) bool
+}
+
+// ruleParser is a recursive descent parser for rule conditions.
+type ruleParser struct {
+	tokens []string
+	pos    int
+}
+
+// tokenize splits the condition into numbers, variables, operators and parentheses.
+func (p *ruleParser) tokenize(condition string) error {
+	runes := []rune(condition)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			p.tokens = append(p.tokens, string(runes[start:i]))
+		case unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '.' ||
+				runes[i] == '_') {
+				i++
+			}
+			p.tokens = append(p.tokens, string(runes[start:i]))
+		default:
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "&&", "||", "<=", ">=", "==", "!=":
+					p.tokens = append(p.tokens, two)
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("<>!+-*/()", r) {
+				return fmt.Errorf("unexpected character '%c'", r)
+			}
+			p.tokens = append(p.tokens, string(r))
+			i++
+		}
+	}
+	if len(p.tokens) == 0 {
+		return fmt.Errorf("missing condition")
+	}
+	return nil
+}
+
+// peek returns the current token or an empty string at the end.
+func (p *ruleParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// parseOr parses a || b || ...
+func (p *ruleParser) parseOr() (ruleExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return left, err
+	}
+	for p.peek() == "||" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return right, err
+		}
+		if left.cond == nil || right.cond == nil {
+			return left, fmt.Errorf("'||' needs conditions on both sides")
+		}
+		l, r := left.cond, right.cond
+		left = ruleExpr{cond: func(vars map[string]float64) bool { return l(vars) || r(vars) }}
+	}
+	return left, nil
+}
+
+// parseAnd parses a && b && ...
+func (p *ruleParser) parseAnd() (ruleExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return left, err
+	}
+	for p.peek() == "&&" {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return right, err
+		}
+		if left.cond == nil || right.cond == nil {
+			return left, fmt.Errorf("'&&' needs conditions on both sides")
+		}
+		l, r := left.cond, right.cond
+		left = ruleExpr{cond: func(vars map[string]float64) bool { return l(vars) && r(vars) }}
+	}
+	return left, nil
+}
+
+// parseNot parses !a
+func (p *ruleParser) parseNot() (ruleExpr, error) {
+	if p.peek() != "!" {
+		return p.parseComparison()
+	}
+	p.pos++
+	e, err := p.parseNot()
+	if err != nil {
+		return e, err
+	}
+	if e.cond == nil {
+		return e, fmt.Errorf("'!' needs a condition")
+	}
+	c := e.cond
+	return ruleExpr{cond: func(vars map[string]float64) bool { return !c(vars) }}, nil
+}
+
+// parseComparison parses a < b, a <= b, a > b, a >= b, a == b and a != b
+func (p *ruleParser) parseComparison() (ruleExpr, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return left, err
+	}
+	operator := p.peek()
+	switch operator {
+	case "<", "<=", ">", ">=", "==", "!=":
+	default:
+		return left, nil
+	}
+	p.pos++
+	right, err := p.parseSum()
+	if err != nil {
+		return right, err
+	}
+	if left.num == nil || right.num == nil {
+		return left, fmt.Errorf("'%s' needs values on both sides", operator)
+	}
+	l, r := left.num, right.num
+	var compare func(a, b float64) bool
+	switch operator {
+	case "<":
+		compare = func(a, b float64) bool { return a < b }
+	case "<=":
+		compare = func(a, b float64) bool { return a <= b }
+	case ">":
+		compare = func(a, b float64) bool { return a > b }
+	case ">=":
+		compare = func(a, b float64) bool { return a >= b }
+	case "==":
+		compare = func(a, b float64) bool { return a == b }
+	default:
+		compare = func(a, b float64) bool { return a != b }
+	}
+	return ruleExpr{cond: func(vars map[string]float64) bool { return compare(l(vars), r(vars)) }}, nil
+}
+
+// parseSum parses a + b and a - b
+func (p *ruleParser) parseSum() (ruleExpr, error) {
+	return p.parseBinary(p.parseProduct, "+", "-")
+}
+
+// parseProduct parses a * b and a / b
+func (p *ruleParser) parseProduct() (ruleExpr, error) {
+	return p.parseBinary(p.parseUnary, "*", "/")
+}
+
+// parseBinary parses a left-associative chain of arithmetic operators.
+func (p *ruleParser) parseBinary(next func() (ruleExpr, error), operators ...string) (ruleExpr, error) {
+	left, err := next()
+	if err != nil {
+		return left, err
+	}
+	for {
+		operator := p.peek()
+		if operator == "" || (operator != operators[0] && operator != operators[1]) {
+			return left, nil
+		}
+		p.pos++
+		right, err := next()
+		if err != nil {
+			return right, err
+		}
+		if left.num == nil || right.num == nil {
+			return left, fmt.Errorf("'%s' needs values on both sides", operator)
+		}
+		l, r := left.num, right.num
+		switch operator {
+		case "+":
+			left = ruleExpr{num: func(vars map[string]float64) float64 { return l(vars) + r(vars) }}
+		case "-":
+			left = ruleExpr{num: func(vars map[string]float64) float64 { return l(vars) - r(vars) }}
+		case "*":
+			left = ruleExpr{num: func(vars map[string]float64) float64 { return l(vars) * r(vars) }}
+		default:
+			left = ruleExpr{num: func(vars map[string]float64) float64 { return l(vars) / r(vars) }}
+		}
+	}
+}
+
+// parseUnary parses -a
+func (p *ruleParser) parseUnary() (ruleExpr, error) {
+	if p.peek() != "-" {
+		return p.parsePrimary()
+	}
+	p.pos++
+	e, err := p.parseUnary()
+	if err != nil {
+		return e, err
+	}
+	if e.num == nil {
+		return e, fmt.Errorf("'-' needs a value")
+	}
+	n := e.num
+	return ruleExpr{num: func(vars map[string]float64) float64 { return -n(vars) }}, nil
+}
+
+// parsePrimary parses numbers, variables and expressions in parentheses.
+func (p *ruleParser) parsePrimary() (ruleExpr, error) {
+	token := p.peek()
+	if token == "" {
+		return ruleExpr{}, fmt.Errorf("unexpected end of condition")
+	}
+	p.pos++
+	if token == "(" {
+		e, err := p.parseOr()
+		if err != nil {
+			return e, err
+		}
+		if p.peek() != ")" {
+			return e, fmt.Errorf("missing ')'")
+		}
+		p.pos++
+		return e, nil
+	}
+	if value, err := strconv.ParseFloat(token, 64); err == nil {
+		return ruleExpr{num: func(map[string]float64) float64 { return value }}, nil
+	}
+	if _, ok := ruleVariables(Input{})[token]; ok {
+		return ruleExpr{num: func(vars map[string]float64) float64 { return vars[token] }}, nil
+	}
+	if unicode.IsLetter([]rune(token)[0]) {
+		return ruleExpr{}, fmt.Errorf("unknown variable '%s'", token)
+	}
+	return ruleExpr{}, fmt.Errorf("unexpected '%s'", token)
+}
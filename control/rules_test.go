@@ -0,0 +1,125 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"reflect"
+	"testing"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		on       bool
+		reason   string
+		errorMsg string
+	}{
+		{name: "Fog", source: `outside.humidity > 95 => off "fog"`, on: false, reason: "fog"},
+		{name: "Cooling", source: `inside.temperature > 28 && outside.temperature < inside.temperature - 3 => on "cooling"`,
+			on: true, reason: "cooling"},
+		{name: "SingleQuotes", source: `!(inside.humidity <= 60) => ON 'humid'`, on: true, reason: "humid"},
+		{name: "DefaultReason", source: `outside.dewpoint >= 18 => off`, on: false, reason: "rule off"},
+		{name: "MissingArrow", source: `outside.humidity > 95 off "fog"`,
+			errorMsg: `missing '=>' in 'outside.humidity > 95 off "fog"'`},
+		{name: "InvalidAction", source: `outside.humidity > 95 => stop "fog"`,
+			errorMsg: "invalid action 'stop', must be on or off"},
+		{name: "UnquotedReason", source: `outside.humidity > 95 => off fog`, errorMsg: "reason fog must be quoted"},
+		{name: "ReasonTooLong", source: `outside.humidity > 95 => off "fog in the morning hours"`,
+			errorMsg: "reason 'fog in the morning hours' is too long, must be at most 18 characters"},
+		{name: "UnknownVariable", source: `outside.humidty > 95 => off "fog"`,
+			errorMsg: "unknown variable 'outside.humidty'"},
+		{name: "NoCondition", source: `outside.humidity + 5 => off "fog"`,
+			errorMsg: "'outside.humidity + 5' is not a condition"},
+		{name: "MissingValue", source: `outside.humidity > => off "fog"`, errorMsg: "unexpected end of condition"},
+		{name: "MissingParenthesis", source: `(outside.humidity > 95 => off "fog"`, errorMsg: "missing ')'"},
+		{name: "InvalidCharacter", source: `outside.humidity > 95 % 2 => off "fog"`,
+			errorMsg: "unexpected character '%'"},
+		{name: "ComparedCondition", source: `(inside.humidity > 60) > 1 => on "humid"`,
+			errorMsg: "'>' needs values on both sides"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRule(tt.source)
+			if tt.errorMsg != "" {
+				if err == nil || err.Error() != tt.errorMsg {
+					t.Errorf("expected error '%s', got %v", tt.errorMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rule.On != tt.on || rule.Reason != tt.reason || rule.Source != tt.source {
+				t.Errorf("unexpected rule %+v", rule)
+			}
+		})
+	}
+}
+
+func TestRulesEvaluate(t *testing.T) {
+	rules, err := ParseRules([]string{
+		`outside.humidity > 95 => off "fog"`,
+		`inside.temperature > 28 && outside.temperature < inside.temperature - 3 => on "cooling"`,
+		`-outside.temperature > 5 * 2 || (outside.absHumidity / 2 >= inside.absHumidity) => off "frost or wet"`,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		name           string
+		inside         sensor.SensorData
+		outside        sensor.SensorData
+		matched        bool
+		expectedResult sensor.ResultData
+	}{
+		{
+			name:           "Fog",
+			inside:         sensor.SensorData{Temperature: 30.0},
+			outside:        sensor.SensorData{Temperature: 20.0, Humidity: 97.0},
+			matched:        true,
+			expectedResult: sensor.ResultData{ShouldBeOn: false, Reason: sensor.ReasonRule, RuleReason: "fog"},
+		},
+		{
+			name:           "Cooling",
+			inside:         sensor.SensorData{Temperature: 30.0, AbsHumidity: 10.0},
+			outside:        sensor.SensorData{Temperature: 26.5, Humidity: 60.0, AbsHumidity: 12.0},
+			matched:        true,
+			expectedResult: sensor.ResultData{ShouldBeOn: true, Reason: sensor.ReasonRule, RuleReason: "cooling"},
+		},
+		{
+			name:           "NotCoolEnough",
+			inside:         sensor.SensorData{Temperature: 30.0, AbsHumidity: 10.0},
+			outside:        sensor.SensorData{Temperature: 27.5, Humidity: 60.0, AbsHumidity: 12.0},
+			matched:        false,
+			expectedResult: sensor.ResultData{ShouldBeOn: true, Reason: sensor.ReasonDewPointInBetween},
+		},
+		{
+			name:           "Frost",
+			inside:         sensor.SensorData{Temperature: 12.0, AbsHumidity: 8.0},
+			outside:        sensor.SensorData{Temperature: -12.0, Humidity: 80.0, AbsHumidity: 1.5},
+			matched:        true,
+			expectedResult: sensor.ResultData{ShouldBeOn: false, Reason: sensor.ReasonRule, RuleReason: "frost or wet"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := sensor.ResultData{ShouldBeOn: true, Reason: sensor.ReasonDewPointInBetween}
+			matched := rules.Evaluate(Input{Inside: tt.inside, Outside: tt.outside}, &result)
+			if matched != tt.matched {
+				t.Errorf("expected matched = %v, got %v", tt.matched, matched)
+			}
+			if !reflect.DeepEqual(result, tt.expectedResult) {
+				t.Errorf("expected ResultData = %+v, got %+v", tt.expectedResult, result)
+			}
+		})
+	}
+}
+
+func TestParseRulesError(t *testing.T) {
+	_, err := ParseRules([]string{`outside.humidity > 95 => off "fog"`, `inside.humidity > => on`})
+	if err == nil || err.Error() != "rule 2: unexpected end of condition" {
+		t.Errorf("expected error of rule 2, got %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeOfDayPattern matches a time of the day with one or two digits for the hour and two for the minute.
+var timeOfDayPattern = regexp.MustCompile(`^(\d{1,2}):(\d{2})$`)
+
+// ScheduleMode defines how a schedule window affects the fan.
+type ScheduleMode string
+
+const (
+	// ScheduleAllowed allows the fan to run. If at least one allowed window is configured, the fan is
+	// blocked outside the allowed and preferred windows.
+	ScheduleAllowed ScheduleMode = "allowed"
+	// ScheduleForbidden blocks the fan, e.g. during quiet hours.
+	ScheduleForbidden ScheduleMode = "forbidden"
+	// SchedulePreferred allows the fan to run with a different minimal difference, e.g. to favor cool nights.
+	SchedulePreferred ScheduleMode = "preferred"
+)
+
+// weekdays maps the day names used in the configuration file to time.Weekday.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ScheduleWindowConfig represents a schedule window as it is defined in the configuration file.
+// Days holds the day names ("mon" ... "sun"), an empty list means every day. From and To are given as "HH:MM".
+// MinDiff and MinAbsDiff are only used by preferred windows and replace the values of the fan configuration
+// if they are greater than 0. They have the same ranges as the values of the fan configuration.
+type ScheduleWindowConfig struct {
+	Days       []string
+	From       string
+	To         string
+	Mode       string
+	MinDiff    float64
+	MinAbsDiff float64
+}
+
+// ScheduleWindow is a validated time window on certain weekdays. From and To are minutes since midnight.
+// If To is less than From, the window spans midnight and belongs to the day it starts on.
+type ScheduleWindow struct {
+	Days       []time.Weekday
+	From       int
+	To         int
+	Mode       ScheduleMode
+	MinDiff    float64
+	MinAbsDiff float64
+}
+
+// Schedule holds the ventilation windows and the timezone they are defined in.
+type Schedule struct {
+	Location *time.Location
+	Windows  []ScheduleWindow
+}
+
+// NewSchedule validates the window configuration and creates a Schedule in the given timezone.
+// An empty timezone uses the local time of the system.
+func NewSchedule(timezone string, windows []ScheduleWindowConfig) (*Schedule, error) {
+	if timezone == "" {
+		timezone = "Local"
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone '%s': %w", timezone, err)
+	}
+	schedule := &Schedule{Location: location}
+	for i, w := range windows {
+		window := ScheduleWindow{
+			Mode:       ScheduleMode(strings.ToLower(w.Mode)),
+			MinDiff:    w.MinDiff,
+			MinAbsDiff: w.MinAbsDiff,
+		}
+		switch window.Mode {
+		case ScheduleAllowed, ScheduleForbidden, SchedulePreferred:
+		default:
+			return nil, fmt.Errorf("window %d: invalid mode '%s', must be allowed, forbidden or preferred", i+1, w.Mode)
+		}
+		for _, day := range w.Days {
+			weekday, ok := weekdays[strings.ToLower(day)]
+			if !ok {
+				return nil, fmt.Errorf("window %d: invalid day '%s', must be one of mon, tue, wed, thu, fri, sat, sun",
+					i+1, day)
+			}
+			window.Days = append(window.Days, weekday)
+		}
+		if window.From, err = parseTimeOfDay(w.From); err != nil {
+			return nil, fmt.Errorf("window %d: %w", i+1, err)
+		}
+		if window.To, err = parseTimeOfDay(w.To); err != nil {
+			return nil, fmt.Errorf("window %d: %w", i+1, err)
+		}
+		if window.From == window.To {
+			return nil, fmt.Errorf("window %d: from and to must be different", i+1)
+		}
+		if window.MinDiff != 0 && (window.MinDiff < 1 || window.MinDiff > 10) {
+			return nil, fmt.Errorf("window %d: minDiff must be 0 (unset) or between 1 and 10°C", i+1)
+		}
+		if window.MinAbsDiff != 0 && (window.MinAbsDiff < 0.1 || window.MinAbsDiff > 10) {
+			return nil, fmt.Errorf("window %d: minAbsDiff must be 0 (unset) or between 0.1 and 10 g/m³", i+1)
+		}
+		schedule.Windows = append(schedule.Windows, window)
+	}
+	return schedule, nil
+}
+
+// Evaluate returns the schedule mode at the given time and the fan configuration to be used. The first
+// matching window wins. Without a matching window, the fan is allowed unless allowed windows are configured.
+// A nil Schedule always allows the fan.
+func (s *Schedule) Evaluate(now time.Time, cfg sensor.FanConfig) (ScheduleMode, sensor.FanConfig) {
+	if s == nil {
+		return ScheduleAllowed, cfg
+	}
+	now = now.In(s.Location)
+	defaultMode := ScheduleAllowed
+	for _, window := range s.Windows {
+		if window.contains(now) {
+			if window.Mode == SchedulePreferred {
+				if window.MinDiff > 0 {
+					cfg.MinDiff = window.MinDiff
+				}
+				if window.MinAbsDiff > 0 {
+					cfg.MinAbsDiff = window.MinAbsDiff
+				}
+			}
+			return window.Mode, cfg
+		}
+		if window.Mode == ScheduleAllowed {
+			defaultMode = ScheduleForbidden
+		}
+	}
+	return defaultMode, cfg
+}
+
+// contains checks if the given time lies within the window.
+func (w ScheduleWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.From < w.To {
+		return minute >= w.From && minute < w.To && w.hasDay(t.Weekday())
+	}
+	// the window spans midnight, the part after midnight belongs to the previous day
+	if minute >= w.From {
+		return w.hasDay(t.Weekday())
+	}
+	if minute < w.To {
+		return w.hasDay((t.Weekday() + 6) % 7)
+	}
+	return false
+}
+
+// hasDay checks if the window is active on the given weekday. A window without days is active every day.
+func (w ScheduleWindow) hasDay(day time.Weekday) bool {
+	return len(w.Days) == 0 || slices.Contains(w.Days, day)
+}
+
+// parseTimeOfDay converts a time in the format "HH:MM" to minutes since midnight. "24:00" is allowed as the
+// end of the day.
+func parseTimeOfDay(value string) (int, error) {
+	match := timeOfDayPattern.FindStringSubmatch(value)
+	if match == nil {
+		return 0, fmt.Errorf("invalid time '%s', must be HH:MM", value)
+	}
+	hour, _ := strconv.Atoi(match[1])
+	minute, _ := strconv.Atoi(match[2])
+	if hour > 24 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time '%s', must be between 00:00 and 24:00", value)
+	}
+	return hour*60 + minute, nil
+}
@@ -0,0 +1,186 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"testing"
+	"time"
+)
+
+func TestNewSchedule(t *testing.T) {
+	tests := []struct {
+		name        string
+		timezone    string
+		windows     []ScheduleWindowConfig
+		expectError bool
+	}{
+		{name: "empty schedule", timezone: "", windows: nil},
+		{
+			name:     "valid windows",
+			timezone: "Europe/Berlin",
+			windows: []ScheduleWindowConfig{
+				{Days: []string{"Mon", "tue"}, From: "22:00", To: "06:00", Mode: "forbidden"},
+				{From: "00:00", To: "24:00", Mode: "preferred", MinDiff: 2.0},
+			},
+		},
+		{name: "invalid timezone", timezone: "Mars/Olympus", expectError: true},
+		{
+			name:        "invalid mode",
+			windows:     []ScheduleWindowConfig{{From: "08:00", To: "10:00", Mode: "sometimes"}},
+			expectError: true,
+		},
+		{
+			name:        "invalid day",
+			windows:     []ScheduleWindowConfig{{Days: []string{"monday"}, From: "08:00", To: "10:00", Mode: "allowed"}},
+			expectError: true,
+		},
+		{
+			name:        "invalid time",
+			windows:     []ScheduleWindowConfig{{From: "8 o'clock", To: "10:00", Mode: "allowed"}},
+			expectError: true,
+		},
+		{
+			name:        "trailing text after the time",
+			windows:     []ScheduleWindowConfig{{From: "07:30pm", To: "10:00", Mode: "allowed"}},
+			expectError: true,
+		},
+		{
+			name:        "time with seconds",
+			windows:     []ScheduleWindowConfig{{From: "08:00", To: "7:30:99", Mode: "allowed"}},
+			expectError: true,
+		},
+		{
+			name:        "time out of range",
+			windows:     []ScheduleWindowConfig{{From: "08:00", To: "25:00", Mode: "allowed"}},
+			expectError: true,
+		},
+		{
+			name:        "empty window",
+			windows:     []ScheduleWindowConfig{{From: "08:00", To: "08:00", Mode: "allowed"}},
+			expectError: true,
+		},
+		{
+			name:        "invalid minDiff",
+			windows:     []ScheduleWindowConfig{{From: "08:00", To: "10:00", Mode: "preferred", MinDiff: 12}},
+			expectError: true,
+		},
+		{
+			name:        "minDiff below the fan minimum",
+			windows:     []ScheduleWindowConfig{{From: "08:00", To: "10:00", Mode: "preferred", MinDiff: 0.5}},
+			expectError: true,
+		},
+		{
+			name:        "minAbsDiff below the fan minimum",
+			windows:     []ScheduleWindowConfig{{From: "08:00", To: "10:00", Mode: "preferred", MinAbsDiff: 0.05}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSchedule(tt.timezone, tt.windows)
+			if tt.expectError && err == nil {
+				t.Errorf("expected an error, got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestScheduleEvaluate(t *testing.T) {
+	quietNights, err := NewSchedule("UTC", []ScheduleWindowConfig{
+		{Days: []string{"mon", "tue", "wed", "thu", "fri"}, From: "22:00", To: "06:00", Mode: "forbidden"},
+		{From: "02:00", To: "06:00", Mode: "preferred", MinDiff: 2.0},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	daytimeOnly, err := NewSchedule("Europe/Berlin", []ScheduleWindowConfig{
+		{From: "08:00", To: "20:00", Mode: "allowed"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg := sensor.FanConfig{MinDiff: 4.0, Hysteresis: 1.0}
+
+	tests := []struct {
+		name            string
+		schedule        *Schedule
+		now             time.Time
+		expectedMode    ScheduleMode
+		expectedMinDiff float64
+	}{
+		{
+			name:            "nil schedule allows",
+			schedule:        nil,
+			now:             time.Date(2025, 7, 7, 3, 0, 0, 0, time.UTC),
+			expectedMode:    ScheduleAllowed,
+			expectedMinDiff: 4.0,
+		},
+		{
+			name:            "monday evening is quiet",
+			schedule:        quietNights,
+			now:             time.Date(2025, 7, 7, 23, 30, 0, 0, time.UTC), // Monday
+			expectedMode:    ScheduleForbidden,
+			expectedMinDiff: 4.0,
+		},
+		{
+			name:            "tuesday early morning belongs to monday night",
+			schedule:        quietNights,
+			now:             time.Date(2025, 7, 8, 3, 0, 0, 0, time.UTC), // Tuesday
+			expectedMode:    ScheduleForbidden,
+			expectedMinDiff: 4.0,
+		},
+		{
+			name:            "sunday early morning belongs to saturday night and is preferred",
+			schedule:        quietNights,
+			now:             time.Date(2025, 7, 6, 3, 0, 0, 0, time.UTC), // Sunday
+			expectedMode:    SchedulePreferred,
+			expectedMinDiff: 2.0,
+		},
+		{
+			name:            "monday early morning belongs to sunday night and is preferred",
+			schedule:        quietNights,
+			now:             time.Date(2025, 7, 7, 3, 0, 0, 0, time.UTC), // Monday
+			expectedMode:    SchedulePreferred,
+			expectedMinDiff: 2.0,
+		},
+		{
+			name:            "daytime without window is allowed",
+			schedule:        quietNights,
+			now:             time.Date(2025, 7, 7, 12, 0, 0, 0, time.UTC),
+			expectedMode:    ScheduleAllowed,
+			expectedMinDiff: 4.0,
+		},
+		{
+			name:            "inside allowed window in local time",
+			schedule:        daytimeOnly,
+			now:             time.Date(2025, 7, 7, 6, 30, 0, 0, time.UTC), // 08:30 in Berlin
+			expectedMode:    ScheduleAllowed,
+			expectedMinDiff: 4.0,
+		},
+		{
+			name:            "outside allowed window is forbidden",
+			schedule:        daytimeOnly,
+			now:             time.Date(2025, 7, 7, 5, 30, 0, 0, time.UTC), // 07:30 in Berlin
+			expectedMode:    ScheduleForbidden,
+			expectedMinDiff: 4.0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode, result := tt.schedule.Evaluate(tt.now, cfg)
+			if mode != tt.expectedMode {
+				t.Errorf("expected mode %s, got %s", tt.expectedMode, mode)
+			}
+			if result.MinDiff != tt.expectedMinDiff {
+				t.Errorf("expected MinDiff %.1f, got %.1f", tt.expectedMinDiff, result.MinDiff)
+			}
+			if result.Hysteresis != cfg.Hysteresis {
+				t.Errorf("expected Hysteresis to stay %.1f, got %.1f", cfg.Hysteresis, result.Hysteresis)
+			}
+		})
+	}
+}
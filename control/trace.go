@@ -0,0 +1,159 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"dpf-bt/utility"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Operator is the comparison operator of a Check.
+type Operator string
+
+// The operators of a Check.
+const (
+	GreaterOrEqual Operator = ">="
+	Greater        Operator = ">"
+	LessOrEqual    Operator = "<="
+	Less           Operator = "<"
+	Equal          Operator = "=="
+	NotEqual       Operator = "!="
+)
+
+// Check is a single rule that was evaluated for a fan decision: the compared value, the operator, the threshold
+// and whether the comparison was true.
+type Check struct {
+	Name      string   `json:"name"`
+	Value     float64  `json:"value"`
+	Operator  Operator `json:"operator"`
+	Threshold float64  `json:"threshold"`
+	Passed    bool     `json:"passed"`
+}
+
+// String returns the check in the form "name value operator threshold: ok|FAIL".
+func (c Check) String() string {
+	result := "FAIL"
+	if c.Passed {
+		result = "ok"
+	}
+	return fmt.Sprintf("%s %g %s %g: %s", c.Name, c.Value, c.Operator, c.Threshold, result)
+}
+
+// Decision is the trace of a single fan decision. It records every check in the order of evaluation and the
+// resulting fan state. All methods can be called on a nil Decision, so tracing is optional.
+type Decision struct {
+	Time       time.Time     `json:"time"`
+	Zone       string        `json:"zone"`
+	Checks     []Check       `json:"checks"`
+	ShouldBeOn bool          `json:"should_be_on"`
+	Reason     sensor.Reason `json:"reason"`
+	ReasonText string        `json:"reason_text"`
+}
+
+// NewDecision creates an empty decision trace for the zone at the given time.
+func NewDecision(zone string, now time.Time) *Decision {
+	return &Decision{Time: now, Zone: zone}
+}
+
+// Check compares value with threshold using the operator, records the comparison and returns its result. An
+// unknown operator is logged as an error and the check fails.
+func (d *Decision) Check(name string, value float64, operator Operator, threshold float64) bool {
+	var passed bool
+	switch operator {
+	case GreaterOrEqual:
+		passed = value >= threshold
+	case Greater:
+		passed = value > threshold
+	case LessOrEqual:
+		passed = value <= threshold
+	case Less:
+		passed = value < threshold
+	case Equal:
+		passed = value == threshold
+	case NotEqual:
+		passed = value != threshold
+	default:
+		lg.Errorf("Unknown operator '%s' of check '%s'", operator, name)
+	}
+	if d != nil {
+		d.Checks = append(d.Checks, Check{
+			Name:      name,
+			Value:     utility.RoundDouble(value, 2),
+			Operator:  operator,
+			Threshold: utility.RoundDouble(threshold, 2),
+			Passed:    passed,
+		})
+	}
+	return passed
+}
+
+// CheckTrue records a condition as a comparison of 1 (true) or 0 (false) with 1 and returns the condition.
+func (d *Decision) CheckTrue(name string, condition bool) bool {
+	value := 0.0
+	if condition {
+		value = 1
+	}
+	return d.Check(name, value, Equal, 1)
+}
+
+// Finish records the resulting fan state and reason.
+func (d *Decision) Finish(result sensor.ResultData) {
+	if d == nil {
+		return
+	}
+	d.ShouldBeOn = result.ShouldBeOn
+	d.Reason = result.Reason
+	d.ReasonText = result.ReasonText()
+}
+
+// String returns the decision in a single line for the log.
+func (d *Decision) String() string {
+	checks := make([]string, len(d.Checks))
+	for i, c := range d.Checks {
+		checks[i] = c.String()
+	}
+	return fmt.Sprintf("%s (%s) - %s", onOff(d.ShouldBeOn), d.ReasonText, strings.Join(checks, "; "))
+}
+
+// DecisionLog keeps the last decisions in memory. When it is full, the oldest decision is dropped.
+type DecisionLog struct {
+	decisions []Decision
+	maxSize   int
+}
+
+// NewDecisionLog creates a DecisionLog for up to maxSize decisions.
+func NewDecisionLog(maxSize int) *DecisionLog {
+	return &DecisionLog{
+		decisions: make([]Decision, 0, maxSize),
+		maxSize:   maxSize,
+	}
+}
+
+// Add appends a decision and drops the oldest one if the log is full. A decision that changes the fan state
+// or the reason of the previous decision is logged.
+func (l *DecisionLog) Add(decision Decision) {
+	if last := l.Last(); last == nil || last.ShouldBeOn != decision.ShouldBeOn || last.Reason != decision.Reason {
+		lg.Infof("Decision %s: %s", decision.Zone, decision.String())
+	}
+	if len(l.decisions) >= l.maxSize {
+		l.decisions = l.decisions[1:]
+	}
+	l.decisions = append(l.decisions, decision)
+}
+
+// Decisions returns a copy of the logged decisions, the oldest first.
+func (l *DecisionLog) Decisions() []Decision {
+	decisions := make([]Decision, len(l.decisions))
+	copy(decisions, l.decisions)
+	return decisions
+}
+
+// Last returns the latest decision or nil if there is none.
+func (l *DecisionLog) Last() *Decision {
+	if len(l.decisions) == 0 {
+		return nil
+	}
+	last := l.decisions[len(l.decisions)-1]
+	return &last
+}
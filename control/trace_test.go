@@ -0,0 +1,99 @@
+package control
+
+import (
+	"dpf-bt/sensor"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecisionCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     float64
+		operator  Operator
+		threshold float64
+		expected  bool
+	}{
+		{name: "GreaterOrEqualPasses", value: 3.0, operator: GreaterOrEqual, threshold: 3.0, expected: true},
+		{name: "GreaterOrEqualFails", value: 2.9, operator: GreaterOrEqual, threshold: 3.0, expected: false},
+		{name: "GreaterFails", value: 3.0, operator: Greater, threshold: 3.0, expected: false},
+		{name: "LessOrEqualPasses", value: 300, operator: LessOrEqual, threshold: 300, expected: true},
+		{name: "LessPasses", value: 0.5, operator: Less, threshold: 1.0, expected: true},
+		{name: "EqualPasses", value: 1, operator: Equal, threshold: 1, expected: true},
+		{name: "NotEqualFails", value: 1, operator: NotEqual, threshold: 1, expected: false},
+		{name: "UnknownOperatorFails", value: 1, operator: "=>", threshold: 1, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecision("Cellar", time.Now())
+			got := d.Check("value", tt.value, tt.operator, tt.threshold)
+			if got != tt.expected {
+				t.Errorf("Check(%g %s %g) = %v, want %v", tt.value, tt.operator, tt.threshold, got, tt.expected)
+			}
+			expectedCheck := Check{Name: "value", Value: tt.value, Operator: tt.operator, Threshold: tt.threshold,
+				Passed: tt.expected}
+			if len(d.Checks) != 1 || d.Checks[0] != expectedCheck {
+				t.Errorf("expected checks = %+v, got %+v", []Check{expectedCheck}, d.Checks)
+			}
+
+			var nilDecision *Decision
+			if nilDecision.Check("value", tt.value, tt.operator, tt.threshold) != tt.expected {
+				t.Errorf("expected nil decision to return %v", tt.expected)
+			}
+		})
+	}
+}
+
+func TestDecisionTrace(t *testing.T) {
+	d := NewDecision("Cellar", time.Now())
+	result := sensor.ResultData{}
+	c := &DewPointController{}
+	c.Compute(Input{
+		Inside:  sensor.SensorData{Temperature: 15.0, Humidity: 70.0, DewPoint: 9.6},
+		Outside: sensor.SensorData{Temperature: 12.0, DewPoint: 5.2},
+		Config: sensor.FanConfig{MinDiff: 3.0, Hysteresis: 1.0, MinTempInside: 10.0, MinTempOutside: -10.0,
+			MinHumidityInside: 50.0},
+		Trace: d,
+	}, &result)
+	d.Finish(result)
+
+	expected := []Check{
+		{Name: "inside temp", Value: 15.0, Operator: ">=", Threshold: 10.0, Passed: true},
+		{Name: "outside temp", Value: 12.0, Operator: ">=", Threshold: -10.0, Passed: true},
+		{Name: "inside humidity", Value: 70.0, Operator: ">=", Threshold: 50.0, Passed: true},
+		{Name: "dp diff", Value: 4.4, Operator: ">=", Threshold: 3.0, Passed: true},
+		{Name: "dp diff", Value: 4.4, Operator: ">=", Threshold: 4.0, Passed: true},
+	}
+	if !reflect.DeepEqual(d.Checks, expected) {
+		t.Errorf("expected checks = %+v, got %+v", expected, d.Checks)
+	}
+	if !d.ShouldBeOn || d.Reason != sensor.ReasonDewPointOverHyst || d.ReasonText != "dp > hysteresis" {
+		t.Errorf("unexpected result %v (%s)", d.ShouldBeOn, d.ReasonText)
+	}
+}
+
+func TestDecisionLog(t *testing.T) {
+	start := time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+	log := NewDecisionLog(3)
+	if log.Last() != nil {
+		t.Errorf("expected no last decision")
+	}
+	for i := range 5 {
+		log.Add(Decision{Time: start.Add(time.Duration(i) * time.Minute)})
+	}
+
+	decisions := log.Decisions()
+	if len(decisions) != 3 {
+		t.Fatalf("expected 3 decisions, got %d", len(decisions))
+	}
+	for i, d := range decisions {
+		if !d.Time.Equal(start.Add(time.Duration(i+2) * time.Minute)) {
+			t.Errorf("unexpected time of decision %d: %v", i, d.Time)
+		}
+	}
+	if !log.Last().Time.Equal(start.Add(4 * time.Minute)) {
+		t.Errorf("unexpected last decision %v", log.Last().Time)
+	}
+}
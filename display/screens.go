@@ -8,6 +8,9 @@ import (
 	"time"
 )
 
+// lineLength is the number of characters of a line of the LCD.
+const lineLength = 20
+
 // printLine formats and prints text to a specific line on the display, with optional scrolling.
 func printLine(disp Display, line int, text string, scroll bool) {
 	if scroll {
@@ -23,6 +26,49 @@ func formatUpDays(seconds uint32) string {
 	return fmt.Sprintf("%dd", days)
 }
 
+// formatCountdown converts a remaining duration to a string in the form "H:MM:SS".
+func formatCountdown(d time.Duration) string {
+	seconds := int(d.Round(time.Second).Seconds())
+	return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds%3600/60, seconds%60)
+}
+
+// formatBattery returns the battery level of a sensor in percent or, if the percentage is unknown, in volts.
+func formatBattery(sensorData sensor.SensorData) string {
+	if sensorData.BatPercent > 0 {
+		return fmt.Sprintf("%d%%", sensorData.BatPercent)
+	}
+	return fmt.Sprintf("%.2fV", float64(sensorData.BatLevel)/1000)
+}
+
+// formatDaysLeft returns the estimated days until a battery is empty, "-" without an estimate.
+func formatDaysLeft(daysLeft float64, ok bool) string {
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprintf("%.0fd", min(daysLeft, 999))
+}
+
+// onOff returns the text representation of a fan state for the display.
+func onOff(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
+
+// fallbackText returns the fallback of a sensor shortened to 6 characters, "-" if no fallback is used.
+func fallbackText(fallback sensor.Fallback) string {
+	if fallback == sensor.FallbackNone {
+		return "-"
+	}
+	return fmt.Sprintf("%.6s", sensor.FallbackName[fallback])
+}
+
+// header returns the first line of the sensor screens with the title shortened to 5 characters.
+func header(title string) string {
+	return fmt.Sprintf("%-5.5s Inside Outside", title)
+}
+
 // StartScreen initializes the display with a startup message and the provided IP address.
 func StartScreen(display Display, buildTime string, ip string) {
 	printLine(display, 0, "DewPointFan BT v1", false)
@@ -32,32 +78,46 @@ func StartScreen(display Display, buildTime string, ip string) {
 }
 
 // MainScreen displays sensor data for inside and outside environments, including temperature,
-// humidity, and dew point.
-func MainScreen(display Display, sensorInside sensor.SensorData, sensorOutside sensor.SensorData) {
-	printLine(display, 0, "DPF   Inside Outside", false)
-	printLine(display, 1, fmt.Sprintf("Temp: %5.1fC  %5.1fC", sensorInside.Temperature,
-		sensorOutside.Temperature), false)
-	printLine(display, 2, fmt.Sprintf("Hum:  %5.1f%%  %5.1f%%", sensorInside.Humidity,
-		sensorOutside.Humidity), false)
+// humidity, and dew point. Page 1 shows the absolute humidity (g/m³) and the mixing ratio (g/kg)
+// instead of the temperature and the relative humidity.
+func MainScreen(display Display, title string, sensorInside sensor.SensorData, sensorOutside sensor.SensorData,
+	page int) {
+	printLine(display, 0, header(title), false)
+	if page == 1 {
+		printLine(display, 1, fmt.Sprintf("AbsH: %5.1fg  %5.1fg", sensorInside.AbsHumidity,
+			sensorOutside.AbsHumidity), false)
+		printLine(display, 2, fmt.Sprintf("MixR: %5.1fg  %5.1fg", sensorInside.MixingRatio,
+			sensorOutside.MixingRatio), false)
+	} else {
+		printLine(display, 1, fmt.Sprintf("Temp: %5.1fC  %5.1fC", sensorInside.Temperature,
+			sensorOutside.Temperature), false)
+		printLine(display, 2, fmt.Sprintf("Hum:  %5.1f%%  %5.1f%%", sensorInside.Humidity,
+			sensorOutside.Humidity), false)
+	}
 	printLine(display, 3, fmt.Sprintf("DP:   %5.1fC  %5.1fC", sensorInside.DewPoint,
 		sensorOutside.DewPoint), false)
 }
 
 // InfoScreen displays information about sensor data on a display, including RSSI, battery levels,
-// and uptime details.
-func InfoScreen(display Display, sensorInside sensor.SensorData, sensorOutside sensor.SensorData) {
-	printLine(display, 0, "DPF   Inside Outside", false)
+// and uptime details. The battery level is shown in percent if the sensor family has a battery model.
+func InfoScreen(display Display, title string, sensorInside sensor.SensorData, sensorOutside sensor.SensorData) {
+	printLine(display, 0, header(title), false)
 	printLine(display, 1, fmt.Sprintf("RSSI:%7d %7d", sensorInside.RSSI,
 		sensorOutside.RSSI), false)
-	printLine(display, 2, fmt.Sprintf("Bat: %7d %7d", sensorInside.BatLevel,
-		sensorOutside.BatLevel), false)
+	printLine(display, 2, fmt.Sprintf("Bat: %7s %7s", formatBattery(sensorInside),
+		formatBattery(sensorOutside)), false)
 	printLine(display, 3, fmt.Sprintf("Up:  %7s %7s", formatUpDays(sensorInside.Uptime),
 		formatUpDays(sensorOutside.Uptime)), false)
 }
 
 // ResultScreen displays fan status, its operation reason, dew point differences, and sensors last-seen durations.
-func ResultScreen(display Display, result sensor.ResultData, sensorInside sensor.SensorData,
-	sensorOutside sensor.SensorData, fanConfig sensor.FanConfig) {
+// During a timed remote override, the remaining time is shown instead of the last-seen durations, and while a
+// stale sensor is replaced by a fallback, the fallbacks of both sensors are shown. If a zone
+// name is given, it replaces the "Fan is" text. diffLabel and diffUnit name the difference the fan controller
+// decides on, diffMin is the difference needed to switch the fan on. The last-seen durations are measured at now.
+func ResultScreen(display Display, zoneName string, result sensor.ResultData, sensorInside sensor.SensorData,
+	sensorOutside sensor.SensorData, diffLabel string, diffUnit string, diff float64, diffMin float64,
+	now time.Time) {
 	isOn := "OFF"
 	shouldBeOn := "OFF"
 	if result.IsOn {
@@ -66,12 +126,66 @@ func ResultScreen(display Display, result sensor.ResultData, sensorInside sensor
 	if result.ShouldBeOn {
 		shouldBeOn = "ON"
 	}
-	now := time.Now()
 	insideLastSeen := int32(math.Min(float64(now.Sub(sensorInside.Scanned).Seconds()), 9999))
 	outsideLastSeen := int32(math.Min(float64(now.Sub(sensorOutside.Scanned).Seconds()), 9999))
-	printLine(display, 0, fmt.Sprintf("Fan is %s (%s)", isOn, shouldBeOn), false)
-	printLine(display, 1, fmt.Sprintf(" %18s ", sensor.ReasonName[result.Reason]), false)
-	printLine(display, 2, fmt.Sprintf("Dp diff:%5.1fC (%3.1f)",
-		sensorInside.DewPoint-sensorOutside.DewPoint, fanConfig.MinDiff+fanConfig.Hysteresis), false)
-	printLine(display, 3, fmt.Sprintf("In/Out:  %4ds %4ds", insideLastSeen, outsideLastSeen), false)
+	state := fmt.Sprintf("%s (%s)", isOn, shouldBeOn)
+	if result.Speed > 0 {
+		state += fmt.Sprintf(" %d%%", result.FanSpeed())
+	}
+	printLine(display, 0, fanLine(zoneName, state), false)
+	printLine(display, 1, fmt.Sprintf(" %18s ", result.ReasonText()), false)
+	printLine(display, 2, fmt.Sprintf("%s:%5.1f%s (%3.1f)", diffLabel, diff, diffUnit, diffMin), false)
+	if result.OverrideRemaining > 0 {
+		printLine(display, 3, fmt.Sprintf("Override: %10s", formatCountdown(result.OverrideRemaining)), false)
+	} else if result.InsideFallback != sensor.FallbackNone || result.OutsideFallback != sensor.FallbackNone {
+		printLine(display, 3, fmt.Sprintf("Fallb: %-6s %6s", fallbackText(result.InsideFallback),
+			fallbackText(result.OutsideFallback)), false)
+	} else {
+		printLine(display, 3, fmt.Sprintf("In/Out:  %4ds %4ds", insideLastSeen, outsideLastSeen), false)
+	}
+}
+
+// fanLine returns the first line of the result screen with the zone name, or "Fan is" for an unnamed zone, and
+// the fan state. The name is cut to at most 6 characters and to the space the state leaves on the line.
+func fanLine(zoneName string, state string) string {
+	fan := "Fan is"
+	if zoneName != "" {
+		fan = zoneName
+	}
+	width := max(min(6, lineLength-1-len(state)), 0)
+	return fmt.Sprintf("%-*.*s %s", width, width, fan, state)
+}
+
+// MoldScreen displays the mold index of a zone with its level, the critical humidity at the current inside
+// temperature and the current inside humidity.
+func MoldScreen(display Display, title string, moldIndex float64, moldLevel string, criticalHumidity float64,
+	sensorInside sensor.SensorData) {
+	printLine(display, 0, fmt.Sprintf("%-5.5s Mold risk", title), false)
+	printLine(display, 1, fmt.Sprintf("Index: %4.2f %7s", moldIndex, moldLevel), false)
+	printLine(display, 2, fmt.Sprintf("Crit. hum:  %5.1f%%", criticalHumidity), false)
+	printLine(display, 3, fmt.Sprintf("Inside hum: %5.1f%%", sensorInside.Humidity), false)
+}
+
+// FaultScreen displays a fan fault of a zone with the commanded and the sensed fan state, the switch position
+// and the time since the fan state disagrees.
+func FaultScreen(display Display, title string, result sensor.ResultData, duration time.Duration) {
+	printLine(display, 0, fmt.Sprintf("%-5.5s %s!", title, strings.ToUpper(sensor.FaultName[result.Fault])), false)
+	printLine(display, 1, fmt.Sprintf("Fan set: %-3s is: %s", onOff(result.ShouldBeOn), onOff(result.IsOn)), false)
+	printLine(display, 2, fmt.Sprintf("Switch: %12s", sensor.SwitchPositionName[result.Switch]), false)
+	printLine(display, 3, fmt.Sprintf("Since: %13s", formatCountdown(duration)), false)
+}
+
+// BatteryScreen displays the sensors of a zone with a low battery, their battery level and the estimated days
+// until the battery is empty. Up to three sensors are shown.
+func BatteryScreen(display Display, title string, devices []*sensor.Device) {
+	printLine(display, 0, fmt.Sprintf("%-5.5s Low battery!", title), false)
+	for line := 1; line < 4; line++ {
+		if line > len(devices) {
+			printLine(display, line, "", false)
+			continue
+		}
+		device := devices[line-1]
+		printLine(display, line, fmt.Sprintf("%-9.9s %3d%% %5s", device.Name, device.Battery.Percent(),
+			formatDaysLeft(device.Battery.DaysLeft())), false)
+	}
 }
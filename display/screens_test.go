@@ -1,7 +1,9 @@
 package display
 
 import (
+	"dpf-bt/sensor"
 	"testing"
+	"time"
 )
 
 func TestFormatUpDays(t *testing.T) {
@@ -46,3 +48,93 @@ func TestFormatUpDays(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatCountdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration time.Duration
+		expected string
+	}{
+		{name: "zero", duration: 0, expected: "0:00:00"},
+		{name: "seconds_only", duration: 42 * time.Second, expected: "0:00:42"},
+		{name: "minutes_and_seconds", duration: 5*time.Minute + 7*time.Second, expected: "0:05:07"},
+		{name: "hours", duration: 2*time.Hour + 30*time.Minute, expected: "2:30:00"},
+		{name: "rounded_to_seconds", duration: 59*time.Second + 600*time.Millisecond, expected: "0:01:00"},
+		{name: "more_than_a_day", duration: 26 * time.Hour, expected: "26:00:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatCountdown(tt.duration)
+			if got != tt.expected {
+				t.Errorf("formatCountdown(%v) = %v, want %v", tt.duration, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		title    string
+		expected string
+	}{
+		{name: "default_title", title: "DPF", expected: "DPF   Inside Outside"},
+		{name: "five_characters", title: "Cella", expected: "Cella Inside Outside"},
+		{name: "long_title_is_cut", title: "Cellar North", expected: "Cella Inside Outside"},
+		{name: "empty_title", title: "", expected: "      Inside Outside"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := header(tt.title)
+			if got != tt.expected {
+				t.Errorf("header(%q) = %q, want %q", tt.title, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatBattery(t *testing.T) {
+	tests := []struct {
+		name       string
+		sensorData sensor.SensorData
+		expected   string
+	}{
+		{name: "percent", sensorData: sensor.SensorData{BatLevel: 2950, BatPercent: 90}, expected: "90%"},
+		{name: "volts", sensorData: sensor.SensorData{BatLevel: 2950}, expected: "2.95V"},
+		{name: "unknown", sensorData: sensor.SensorData{}, expected: "0.00V"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatBattery(tt.sensorData); got != tt.expected {
+				t.Errorf("formatBattery(%+v) = %v, want %v", tt.sensorData, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFanLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		zoneName string
+		state    string
+		expected string
+	}{
+		{name: "unnamed", state: "ON (ON)", expected: "Fan is ON (ON)"},
+		{name: "short_name", zoneName: "Attic", state: "ON (ON)", expected: "Attic  ON (ON)"},
+		{name: "long_name", zoneName: "Basement", state: "ON (ON) 80%", expected: "Baseme ON (ON) 80%"},
+		{name: "name_cut_to_fit", zoneName: "Basement", state: "OFF (OFF) 100%", expected: "Basem OFF (OFF) 100%"},
+		{name: "unnamed_cut_to_fit", state: "OFF (OFF) 100%", expected: "Fan i OFF (OFF) 100%"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fanLine(tt.zoneName, tt.state)
+			if got != tt.expected || len(got) > lineLength {
+				t.Errorf("fanLine(%q, %q) = %q, want %q", tt.zoneName, tt.state, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"dpf-bt/bluetooth"
+	"dpf-bt/sensor"
+	"dpf-bt/utility"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/viper"
+	bt "tinygo.org/x/bluetooth"
+)
+
+const (
+	// calibrationBucket is the time span whose readings of every sensor are averaged and compared.
+	calibrationBucket = time.Minute
+	// minTempSpread and minHumSpread are the ranges the raw readings must span for fitting a gain. With a smaller
+	// range, only the offset is fitted.
+	minTempSpread = 2.0
+	minHumSpread  = 5.0
+)
+
+// calibrationSensor is a configured sensor that takes part in the calibration.
+type calibrationSensor struct {
+	Name        string
+	MacAddress  string
+	Calibration sensor.SensorCalibration
+}
+
+// calibrationResult holds the fitted calibration of a sensor or the error why it couldn't be fitted.
+type calibrationResult struct {
+	Sensor      calibrationSensor
+	Temperature sensor.CalibrationFit
+	Humidity    sensor.CalibrationFit
+	Err         error
+}
+
+// calibrationSum sums the raw readings of a sensor within a bucket.
+type calibrationSum struct {
+	temperature float64
+	humidity    float64
+	count       int
+}
+
+// calibrationRecorder collects the raw readings of the sensors in buckets of calibrationBucket since start.
+type calibrationRecorder struct {
+	start time.Time
+	sums  map[string]map[int]*calibrationSum
+}
+
+// runCalibration implements the "calibrate" command. All configured sensors are placed side by side with the
+// reference sensor. The command records their raw readings for the given time, fits the gain and offset of every
+// sensor to the calibrated readings of the reference sensor and offers to write them into the configuration file.
+func runCalibration(args []string) {
+	flags := flag.NewFlagSet("calibrate", flag.ExitOnError)
+	minutes := flags.Int("minutes", 60, "recording time in minutes")
+	reference := flags.String("reference", "", "name or MAC address of the reference sensor "+
+		"(default the sensor with the role reference)")
+	configFile := flags.String("config", "", "configuration file (default config.json next to the app)")
+	write := flags.Bool("write", false, "write the calibration into the configuration file without asking")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(flags.Output(),
+			"Usage: %s calibrate [-minutes <n>] [-reference <name|MAC>] [-config <file>] [-write]\n",
+			filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if *minutes < 2 || flags.NArg() > 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	path := *configFile
+	if path == "" {
+		path = defaultConfigPath()
+	}
+	viper.SetConfigFile(path)
+	if err := readConfig(); err != nil {
+		lg.Fatal(err)
+	}
+	sensors := calibrationSensors(zones)
+	ref, err := findReference(sensors, *reference, zones)
+	if err != nil {
+		lg.Fatalf("Invalid reference sensor! %s", err)
+	}
+
+	adapter := bt.DefaultAdapter
+	if err = adapter.Enable(); err != nil {
+		lg.Error("Check: 1) rfkill unblock bluetooth, 2) sudo systemctl start bluetooth")
+		lg.Fatalf("failed to enable BLE adapter: %v", err)
+	}
+	lg.Infof("Recording %d sensors against '%s' for %d minutes...", len(sensors), ref.Name, *minutes)
+	recorder := newCalibrationRecorder(time.Now())
+	time.AfterFunc(time.Duration(*minutes)*time.Minute, func() {
+		_ = adapter.StopScan()
+	})
+	err = adapter.Scan(func(_ *bt.Adapter, scanResult bt.ScanResult) {
+		if reading, _, err := bluetooth.DecodeScanResult(scanResult); err == nil {
+			recorder.add(reading.MacAddress, reading.Temperature, reading.Humidity, time.Now())
+		}
+	})
+	if err != nil {
+		lg.Fatalf("failed to scan - %s", err)
+	}
+
+	var results []calibrationResult
+	for _, s := range sensors {
+		if s.MacAddress != ref.MacAddress {
+			results = append(results, recorder.fit(s, ref))
+		}
+	}
+	printCalibrationResults(os.Stdout, ref, results)
+	if !*write && !confirm(os.Stdin, os.Stdout, fmt.Sprintf("Write the calibration into %s?", path)) {
+		return
+	}
+	if err = writeCalibration(path, results); err != nil {
+		lg.Fatalf("Couldn't update %s: %s", path, err)
+	}
+	lg.Infof("Updated %s", path)
+}
+
+// calibrationSensors returns the configured sensors of all zones. A sensor that is used by several zones is
+// listed once; the name of a named zone is prepended.
+func calibrationSensors(zones []*zone) []calibrationSensor {
+	var sensors []calibrationSensor
+	for _, z := range zones {
+		for _, device := range z.Devices {
+			if slices.ContainsFunc(sensors, func(s calibrationSensor) bool {
+				return s.MacAddress == device.MacAddress
+			}) {
+				continue
+			}
+			name := device.Name
+			if z.Name != "" {
+				name = z.Name + "/" + name
+			}
+			sensors = append(sensors, calibrationSensor{Name: name, MacAddress: device.MacAddress,
+				Calibration: device.Calibration})
+		}
+	}
+	return sensors
+}
+
+// findReference returns the reference sensor, selected by its name or MAC address. Without a choice, the only
+// sensor with the role reference is used.
+func findReference(sensors []calibrationSensor, choice string, zones []*zone) (calibrationSensor, error) {
+	if choice == "" {
+		var macs []string
+		for _, z := range zones {
+			for _, device := range z.DevicesWithRole(sensor.RoleReference) {
+				if !slices.Contains(macs, device.MacAddress) {
+					macs = append(macs, device.MacAddress)
+				}
+			}
+		}
+		if len(macs) != 1 {
+			return calibrationSensor{}, fmt.Errorf("%d sensors with the role reference, use -reference to choose one",
+				len(macs))
+		}
+		choice = macs[0]
+	}
+	for _, s := range sensors {
+		if strings.EqualFold(s.MacAddress, choice) || s.Name == choice ||
+			strings.HasSuffix(s.Name, "/"+choice) {
+			return s, nil
+		}
+	}
+	return calibrationSensor{}, fmt.Errorf("sensor '%s' not found", choice)
+}
+
+// newCalibrationRecorder creates a recorder whose first bucket begins at start.
+func newCalibrationRecorder(start time.Time) *calibrationRecorder {
+	return &calibrationRecorder{start: start, sums: make(map[string]map[int]*calibrationSum)}
+}
+
+// add adds the raw reading of a sensor to the bucket of the given time.
+func (r *calibrationRecorder) add(mac string, temperature, humidity float64, now time.Time) {
+	buckets, ok := r.sums[mac]
+	if !ok {
+		buckets = make(map[int]*calibrationSum)
+		r.sums[mac] = buckets
+	}
+	bucket := int(now.Sub(r.start) / calibrationBucket)
+	sum, ok := buckets[bucket]
+	if !ok {
+		sum = &calibrationSum{}
+		buckets[bucket] = sum
+	}
+	sum.temperature += temperature
+	sum.humidity += humidity
+	sum.count++
+}
+
+// fit compares the averaged raw readings of the sensor with the calibrated readings of the reference in every
+// bucket with readings of both and fits the gain and offset of the temperature and the humidity.
+func (r *calibrationRecorder) fit(s, ref calibrationSensor) calibrationResult {
+	result := calibrationResult{Sensor: s}
+	var rawTemp, refTemp, rawHum, refHum []float64
+	buckets := make([]int, 0, len(r.sums[s.MacAddress]))
+	for bucket := range r.sums[s.MacAddress] {
+		buckets = append(buckets, bucket)
+	}
+	slices.Sort(buckets)
+	for _, bucket := range buckets {
+		refSum, ok := r.sums[ref.MacAddress][bucket]
+		if !ok {
+			continue
+		}
+		sum := r.sums[s.MacAddress][bucket]
+		rawTemp = append(rawTemp, sum.temperature/float64(sum.count))
+		rawHum = append(rawHum, sum.humidity/float64(sum.count))
+		temperature, humidity := ref.Calibration.Apply(refSum.temperature/float64(refSum.count),
+			refSum.humidity/float64(refSum.count))
+		refTemp = append(refTemp, temperature)
+		refHum = append(refHum, humidity)
+	}
+	if result.Temperature, result.Err = sensor.FitCalibration(rawTemp, refTemp, minTempSpread); result.Err != nil {
+		return result
+	}
+	result.Humidity, result.Err = sensor.FitCalibration(rawHum, refHum, minHumSpread)
+	return result
+}
+
+// printCalibrationResults prints the fitted gains and offsets and the remaining error of every sensor.
+func printCalibrationResults(w io.Writer, ref calibrationSensor, results []calibrationResult) {
+	_, _ = fmt.Fprintf(w, "Reference: %s (%s)\n", ref.Name, ref.MacAddress)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "Sensor\tMAC\tMinutes\tTemp gain\tTemp offset\tTemp error\tHum gain\tHum offset\tHum error\t")
+	for _, r := range results {
+		if r.Err != nil {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t\n", r.Sensor.Name, r.Sensor.MacAddress, r.Err)
+			continue
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%.3f\t%+.2f°C\t%.2f°C\t%.3f\t%+.2f%%\t%.2f%%\t\n", r.Sensor.Name,
+			r.Sensor.MacAddress, r.Temperature.Samples, r.Temperature.Gain, r.Temperature.Offset,
+			r.Temperature.RMSError, r.Humidity.Gain, r.Humidity.Offset, r.Humidity.RMSError)
+	}
+	_ = tw.Flush()
+}
+
+// confirm asks the question and returns whether it was answered with yes.
+func confirm(r io.Reader, w io.Writer, question string) bool {
+	_, _ = fmt.Fprintf(w, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(r).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// writeCalibration sets the offsets and gains of every successfully calibrated sensor in all sensor sections of
+// the configuration file with its MAC address: the "inside" and "outside" sections and the entries of the
+// "sensors" list, at the top level and in the zones. A gain of 1 is removed.
+func writeCalibration(path string, results []calibrationResult) error {
+	return updateConfigFile(path, func(config map[string]any) error {
+		sections := sensorSections(config)
+		if zoneList, ok := config["zones"].([]any); ok {
+			for _, entry := range zoneList {
+				if zoneMap, ok := entry.(map[string]any); ok {
+					sections = append(sections, sensorSections(zoneMap)...)
+				}
+			}
+		}
+		for _, r := range results {
+			if r.Err != nil {
+				continue
+			}
+			for _, section := range sections {
+				if mac, _ := section["mac"].(string); !strings.EqualFold(mac, r.Sensor.MacAddress) {
+					continue
+				}
+				setCalibration(section, "temperature", r.Temperature)
+				setCalibration(section, "humidity", r.Humidity)
+			}
+		}
+		return nil
+	})
+}
+
+// sensorSections returns the "inside" and "outside" sections and the entries of the "sensors" list of the
+// configuration or zone.
+func sensorSections(config map[string]any) []map[string]any {
+	var sections []map[string]any
+	for _, key := range []string{"inside", "outside"} {
+		if section, ok := config[key].(map[string]any); ok {
+			sections = append(sections, section)
+		}
+	}
+	if entries, ok := config["sensors"].([]any); ok {
+		for _, entry := range entries {
+			if section, ok := entry.(map[string]any); ok {
+				sections = append(sections, section)
+			}
+		}
+	}
+	return sections
+}
+
+// setCalibration sets the rounded offset and gain of the temperature or humidity of a sensor section.
+func setCalibration(section map[string]any, quantity string, fit sensor.CalibrationFit) {
+	section[quantity+"-calibration"] = utility.RoundDouble(fit.Offset, 2)
+	gain := utility.RoundDouble(fit.Gain, 3)
+	if gain == 1 {
+		delete(section, quantity+"-gain")
+	} else {
+		section[quantity+"-gain"] = gain
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"dpf-bt/sensor"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalibrationRecorderFit(t *testing.T) {
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	ref := calibrationSensor{Name: "Reference", MacAddress: "9D:F2:00:00:33:02",
+		Calibration: sensor.SensorCalibration{Temperature: 0.5}}
+	cellar := calibrationSensor{Name: "Cellar", MacAddress: "9D:8B:00:00:18:BD"}
+	recorder := newCalibrationRecorder(start)
+	for minute := range 5 {
+		now := start.Add(time.Duration(minute) * time.Minute)
+		temperature := 15 + 2*float64(minute)
+		// the reference shows 0.5°C too little and the cellar sensor has a gain of 1/0.9 and an offset
+		recorder.add(ref.MacAddress, temperature-0.5, 60, now)
+		recorder.add(ref.MacAddress, temperature-0.5, 60, now.Add(30*time.Second))
+		recorder.add(cellar.MacAddress, (temperature-1.5)/0.9, 58, now.Add(10*time.Second))
+	}
+	// a reading of the sensor without reference reading is ignored
+	recorder.add(cellar.MacAddress, 40, 40, start.Add(10*time.Minute))
+
+	result := recorder.fit(cellar, ref)
+	if result.Err != nil {
+		t.Fatalf("unexpected error: %s", result.Err)
+	}
+	if math.Abs(result.Temperature.Gain-0.9) > 1e-9 || math.Abs(result.Temperature.Offset-1.5) > 1e-9 ||
+		result.Temperature.Samples != 5 {
+		t.Errorf("expected temperature gain 0.9 and offset 1.5, got %+v", result.Temperature)
+	}
+	if result.Humidity.Gain != 1 || math.Abs(result.Humidity.Offset-2) > 1e-9 {
+		t.Errorf("expected humidity offset 2 without gain, got %+v", result.Humidity)
+	}
+
+	missing := calibrationSensor{Name: "Missing", MacAddress: "9D:8B:00:00:21:0A"}
+	if result = recorder.fit(missing, ref); result.Err == nil {
+		t.Errorf("expected an error for a sensor without readings")
+	}
+}
+
+func TestFindReference(t *testing.T) {
+	loadConfig(t, `{
+		"zones": [{
+			"name": "cellar",
+			"inside": {"mac": "9D:8B:00:00:18:BD"},
+			"outside": {"mac": "9D:F2:00:00:14:B5"},
+			"sensors": [{"mac": "9D:F2:00:00:33:02", "name": "Reference", "role": "reference"}]
+		}, {
+			"name": "garage",
+			"fanPin": "GPIO24",
+			"sensePin": "GPIO23",
+			"inside": {"mac": "9D:8B:00:00:21:0A"},
+			"outside": {"mac": "9D:F2:00:00:14:B5"}
+		}],
+		`+testFanSection+`
+	}`)
+	configured, err := readZones()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sensors := calibrationSensors(configured)
+	if len(sensors) != 4 || sensors[0].Name != "cellar/Inside" || sensors[3].Name != "garage/Inside" {
+		t.Fatalf("unexpected sensors %+v", sensors)
+	}
+
+	tests := []struct {
+		name     string
+		choice   string
+		expected string
+		wantErr  bool
+	}{
+		{name: "RoleReference", choice: "", expected: "9D:F2:00:00:33:02"},
+		{name: "Name", choice: "garage/Inside", expected: "9D:8B:00:00:21:0A"},
+		{name: "NameWithoutZone", choice: "Outside", expected: "9D:F2:00:00:14:B5"},
+		{name: "Mac", choice: "9d:8b:00:00:18:bd", expected: "9D:8B:00:00:18:BD"},
+		{name: "Unknown", choice: "Attic", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findReference(sensors, tt.choice, configured)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.MacAddress != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got.MacAddress)
+			}
+		})
+	}
+}
+
+func TestWriteCalibration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{
+		"inside": {"mac": "9D:8B:00:00:18:BD", "temperature-gain": 1.2},
+		"outside": {"mac": "9D:F2:00:00:14:B5"},
+		"zones": [{"sensors": [{"mac": "9d:8b:00:00:18:bd", "name": "Cellar"}, {"mac": "9D:F2:00:00:33:02"}]}]
+	}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	results := []calibrationResult{
+		{
+			Sensor:      calibrationSensor{MacAddress: "9D:8B:00:00:18:BD"},
+			Temperature: sensor.CalibrationFit{Gain: 1, Offset: -0.4567},
+			Humidity:    sensor.CalibrationFit{Gain: 1.04321, Offset: -2.5},
+		},
+		{
+			Sensor: calibrationSensor{MacAddress: "9D:F2:00:00:14:B5"},
+			Err:    os.ErrInvalid,
+		},
+	}
+
+	if err := writeCalibration(path, results); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, expected any
+	if err = json.Unmarshal(written, &got); err != nil {
+		t.Fatalf("invalid JSON written: %s", err)
+	}
+	calibrated := `"temperature-calibration": -0.46, "humidity-calibration": -2.5, "humidity-gain": 1.043`
+	expectedContent := `{
+		"inside": {"mac": "9D:8B:00:00:18:BD", ` + calibrated + `},
+		"outside": {"mac": "9D:F2:00:00:14:B5"},
+		"zones": [{"sensors": [{"mac": "9d:8b:00:00:18:bd", "name": "Cellar", ` + calibrated + `},
+			{"mac": "9D:F2:00:00:33:02"}]}]
+	}`
+	if err = json.Unmarshal([]byte(expectedContent), &expected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		answer   string
+		expected bool
+	}{
+		{answer: "y\n", expected: true},
+		{answer: " Yes \n", expected: true},
+		{answer: "n\n", expected: false},
+		{answer: "\n", expected: false},
+		{answer: "", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.TrimSpace(tt.answer), func(t *testing.T) {
+			var out strings.Builder
+			if got := confirm(strings.NewReader(tt.answer), &out, "Write?"); got != tt.expected {
+				t.Errorf("expected %v for '%s', got %v", tt.expected, tt.answer, got)
+			}
+			if out.String() != "Write? [y/N] " {
+				t.Errorf("unexpected prompt '%s'", out.String())
+			}
+		})
+	}
+}
@@ -1,66 +1,594 @@
 package main
 
-import "github.com/spf13/viper"
+import (
+	"dpf-bt/bluetooth"
+	"dpf-bt/control"
+	"dpf-bt/gpio"
+	"dpf-bt/sensor"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
 
 // readConfig initializes application configuration values from the configuration file using the Viper library.
-// It reads and validates the sensor, LCD, fan, and InfluxDB configurations, ensuring all values are correctly set.
-func readConfig() {
+// It reads and validates the zone, sensor, LCD, fan, InfluxDB, history and scanner configurations, ensuring all
+// values are correctly set. The values are only taken over if the whole configuration is valid, so that a reload
+// with an invalid value keeps the previous configuration.
+func readConfig() error {
+	setConfigDefaults(viper.GetViper())
 	err := viper.ReadInConfig()
 	if err != nil {
-		lg.Fatalf("Fatal error reading config file: %s \n", err)
+		return fmt.Errorf("Error reading config file: %s", err)
+	}
+
+	delay := viper.GetInt("lcd.delay")
+	if delay < 1 || delay > 60 {
+		return errors.New("Invalid LCD delay! Must be between 1 and 60 seconds.")
+	}
+	scrollSpeed := viper.GetInt("lcd.scrollSpeed")
+	if scrollSpeed < 100 || scrollSpeed > 10000 {
+		return errors.New("Invalid LCD scroll speed! Must be between 100 and 10.000 ms.")
+	}
+	screenChange := viper.GetInt("lcd.screenChange")
+	if screenChange < 3 || screenChange > 10 {
+		return errors.New("Invalid LCD screen change interval! Must be between 3 and 10 seconds.")
+	}
+
+	configured, err := readZones()
+	if err != nil {
+		return err
+	}
+
+	var windows []control.ScheduleWindowConfig
+	if err = viper.UnmarshalKey("schedule.windows", &windows); err != nil {
+		return fmt.Errorf("Invalid schedule windows! %s", err)
+	}
+	schedule, err := control.NewSchedule(viper.GetString("schedule.timezone"), windows)
+	if err != nil {
+		return fmt.Errorf("Invalid schedule! %s", err)
+	}
+	history, err := readHistoryConfig()
+	if err != nil {
+		return err
+	}
+	scanner, err := readScannerConfig()
+	if err != nil {
+		return err
+	}
+
+	lcdDelay, lcdScrollSpeed, lcdScreenChange = delay, scrollSpeed, screenChange
+	applyZones(configured)
+	fanSchedule = schedule
+	lg.Infof("Schedule: %d window(s) in timezone %s", len(fanSchedule.Windows), fanSchedule.Location)
+
+	influxConfig.Enabled = viper.GetBool("influx.enabled")
+	influxConfig.Org = viper.GetString("influx.org")
+	influxConfig.Bucket = viper.GetString("influx.bucket")
+	influxConfig.Token = viper.GetString("influx.token")
+	influxConfig.Url = viper.GetString("influx.url")
+
+	historyConfig = history
+	if historySync != nil {
+		historySync.SetConfig(historyConfig)
+	}
+	scannerConfig = scanner
+	if supervisor != nil {
+		supervisor.SetConfig(scannerConfig)
 	}
+	return nil
+}
 
-	sensors.InsideData.MacAddress = viper.GetString("inside.mac")
-	sensors.InsideCalibration.Temperature = viper.GetFloat64("inside.temperature-calibration")
-	sensors.InsideCalibration.Humidity = viper.GetFloat64("inside.humidity-calibration")
-	sensors.OutsideData.MacAddress = viper.GetString("outside.mac")
-	sensors.OutsideCalibration.Temperature = viper.GetFloat64("outside.temperature-calibration")
-	sensors.OutsideCalibration.Humidity = viper.GetFloat64("outside.humidity-calibration")
-	if len(sensors.InsideData.MacAddress) != 17 || len(sensors.OutsideData.MacAddress) != 17 {
-		lg.Fatal("Invalid MAC address! Must be 17 characters long.")
+// readScannerConfig reads the configuration of the watchdog of the Bluetooth scan.
+func readScannerConfig() (bluetooth.SupervisorConfig, error) {
+	config := bluetooth.SupervisorConfig{
+		Timeout:    time.Duration(viper.GetInt("scanner.timeoutSeconds")) * time.Second,
+		MaxBackoff: time.Duration(viper.GetInt("scanner.maxBackoffSeconds")) * time.Second,
+	}
+	if config.Timeout < 30*time.Second || config.Timeout > time.Hour {
+		return bluetooth.SupervisorConfig{}, errors.New("Invalid scanner timeout! Must be between 30 and 3600 seconds.")
 	}
-	lg.Infof("Inside sensor:  MAC %s - Temp cal = %.2f - Humidity cal = %.2f",
-		sensors.InsideData.MacAddress, sensors.InsideCalibration.Temperature, sensors.InsideCalibration.Humidity)
-	lg.Infof("Outside sensor: MAC %s - Temp cal = %.2f - Humidity cal = %.2f",
-		sensors.OutsideData.MacAddress, sensors.OutsideCalibration.Temperature, sensors.OutsideCalibration.Humidity)
+	if config.MaxBackoff < 10*time.Second || config.MaxBackoff > time.Hour {
+		return bluetooth.SupervisorConfig{}, errors.New("Invalid scanner backoff! Must be between 10 and 3600 seconds.")
+	}
+	return config, nil
+}
 
-	lcdDelay = viper.GetInt("lcd.delay")
-	if lcdDelay < 1 || lcdDelay > 60 {
-		lg.Fatal("Invalid LCD delay! Must be between 1 and 60 seconds.")
+// readHistoryConfig reads the configuration of the download of the logged readings of the sensors after a gap.
+func readHistoryConfig() (bluetooth.HistoryConfig, error) {
+	config := bluetooth.HistoryConfig{
+		Enabled: viper.GetBool("history.enabled"),
+		MinGap:  time.Duration(viper.GetInt("history.minGapMinutes")) * time.Minute,
+		MaxAge:  time.Duration(viper.GetInt("history.maxHours")) * time.Hour,
 	}
-	lcdScrollSpeed = viper.GetInt("lcd.scrollSpeed")
-	if lcdScrollSpeed < 100 || lcdScrollSpeed > 10000 {
-		lg.Fatal("Invalid LCD scroll speed! Must be between 100 and 10.000 ms.")
+	if config.MinGap < 10*time.Minute || config.MinGap > 24*time.Hour {
+		return bluetooth.HistoryConfig{}, errors.New("Invalid history gap! Must be between 10 and 1440 minutes.")
+	}
+	if config.MaxAge < time.Hour || config.MaxAge > 30*24*time.Hour {
+		return bluetooth.HistoryConfig{}, errors.New("Invalid history age! Must be between 1 and 720 hours.")
+	}
+	return config, nil
+}
+
+// readZones reads the list of ventilation zones. Each zone has its own inside and outside sensor, GPIO pins and
+// an optional "fan" section whose values override the ones of the global "fan" section. Without a "zones"
+// section, a single unnamed zone is built from the top level "inside", "outside" and "fan" sections.
+func readZones() ([]*zone, error) {
+	var zoneMaps []map[string]any
+	if err := viper.UnmarshalKey("zones", &zoneMaps); err != nil {
+		return nil, fmt.Errorf("Invalid zones! %s", err)
 	}
-	lcdScreenChange = viper.GetInt("lcd.screenChange")
-	if lcdScreenChange < 3 || lcdScreenChange > 10 {
-		lg.Fatal("Invalid LCD screen change interval! Must be between 3 and 10 seconds.")
+	if len(zoneMaps) == 0 {
+		zoneMaps = []map[string]any{{
+			"inside":       viper.GetStringMap("inside"),
+			"outside":      viper.GetStringMap("outside"),
+			"pwmPin":       viper.GetString("fan.pwmPin"),
+			"switchOnPin":  viper.GetString("fan.switchOnPin"),
+			"switchOffPin": viper.GetString("fan.switchOffPin"),
+		}}
+		for _, key := range []string{"sensors", "combine"} {
+			if viper.IsSet(key) {
+				zoneMaps[0][key] = viper.Get(key)
+			}
+		}
 	}
 
-	fanConfig.MinDiff = viper.GetFloat64("fan.minDiff")
+	names := make(map[string]bool)
+	// usedPins maps every pin to the zone and the key it is used for
+	usedPins := make(map[string]string)
+	configured := make([]*zone, 0, len(zoneMaps))
+	for i, zoneMap := range zoneMaps {
+		zv := viper.New()
+		setConfigDefaults(zv)
+		if err := zv.MergeConfigMap(map[string]any{
+			"fan":     viper.GetStringMap("fan"),
+			"filter":  viper.GetStringMap("filter"),
+			"average": viper.GetStringMap("average"),
+			"battery": viper.GetStringMap("battery"),
+		}); err != nil {
+			return nil, fmt.Errorf("Invalid fan configuration! %s", err)
+		}
+		if err := zv.MergeConfigMap(zoneMap); err != nil {
+			return nil, fmt.Errorf("Invalid configuration of zone %d! %s", i+1, err)
+		}
+
+		name := zv.GetString("name")
+		if len(zoneMaps) > 1 && name == "" {
+			name = fmt.Sprintf("Zone%d", i+1)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("Invalid zone name '%s'! Zone names must be unique.", name)
+		}
+		names[name] = true
+
+		z, err := readZone(zv, name)
+		if err != nil {
+			return nil, err
+		}
+		pins := z.gpioConfig.Pins()
+		for _, key := range slices.Sorted(maps.Keys(pins)) {
+			pin := pins[key]
+			if user, ok := usedPins[pin]; ok {
+				return nil, fmt.Errorf("Invalid %s %s of zone '%s'! It is already used as %s. "+
+					"Each pin can only be used once.", key, pin, z.title(), user)
+			}
+			usedPins[pin] = fmt.Sprintf("%s of zone '%s'", key, z.title())
+		}
+		configured = append(configured, z)
+	}
+	return configured, nil
+}
+
+// readZone reads the sensors, filters, averaging window, battery, fan and GPIO configuration of a zone from its
+// merged configuration.
+func readZone(zv *viper.Viper, name string) (*zone, error) {
+	var err error
+	z := newZone(name)
+	lg.Infof("Zone '%s':", z.title())
+	if z.Sensors, err = readSensors(zv); err != nil {
+		return nil, err
+	}
+	if z.Devices, err = readDevices(zv); err != nil {
+		return nil, err
+	}
+	filter, err := readFilterConfig(zv)
+	if err != nil {
+		return nil, err
+	}
+	window, err := readAverageWindow(zv)
+	if err != nil {
+		return nil, err
+	}
+	z.Store.Inside.SetWindow(window)
+	z.Store.Outside.SetWindow(window)
+	for _, device := range z.Devices {
+		device.Store.SetFilter(filter)
+		device.Store.SetWindow(window)
+	}
+	if z.lowBatteryPercent, z.batteryWarnDays, err = readBatteryConfig(zv); err != nil {
+		return nil, err
+	}
+	if z.fanConfig, z.controller, err = readFanConfig(zv); err != nil {
+		return nil, err
+	}
+	if z.rules, err = readRules(zv); err != nil {
+		return nil, err
+	}
+	z.gpioConfig = gpio.Config{
+		FanPin:       zv.GetString("fanPin"),
+		SensePin:     zv.GetString("sensePin"),
+		PwmPin:       zv.GetString("pwmPin"),
+		SwitchOnPin:  zv.GetString("switchOnPin"),
+		SwitchOffPin: zv.GetString("switchOffPin"),
+	}
+	if (z.gpioConfig.SwitchOnPin == "") != (z.gpioConfig.SwitchOffPin == "") {
+		return nil, fmt.Errorf("Invalid switch pins of zone '%s'! Both or none must be set.", z.title())
+	}
+	return z, nil
+}
+
+// readSensors reads how the inside and outside sensors are combined, the backup sensors and the fallback
+// configuration.
+func readSensors(v *viper.Viper) (sensor.Sensors, error) {
+	var err error
+	sensors := sensor.Sensors{}
+	if sensors.InsideCombine, err = readCombine(v, "inside"); err != nil {
+		return sensor.Sensors{}, err
+	}
+	if sensors.OutsideCombine, err = readCombine(v, "outside"); err != nil {
+		return sensor.Sensors{}, err
+	}
+
+	sensors.InsideBackup.MacAddress = v.GetString("inside.backup.mac")
+	if sensors.InsideBackupCalibration, err = readCalibration(v, "inside.backup."); err != nil {
+		return sensor.Sensors{}, err
+	}
+	sensors.OutsideBackup.MacAddress = v.GetString("outside.backup.mac")
+	if sensors.OutsideBackupCalibration, err = readCalibration(v, "outside.backup."); err != nil {
+		return sensor.Sensors{}, err
+	}
+	for _, mac := range []string{sensors.InsideBackup.MacAddress, sensors.OutsideBackup.MacAddress} {
+		if mac != "" && len(mac) != 17 {
+			return sensor.Sensors{}, errors.New("Invalid backup MAC address! Must be 17 characters long.")
+		}
+	}
+	if sensors.InsideFallback, err = readFallbackConfig(v, "inside"); err != nil {
+		return sensor.Sensors{}, err
+	}
+	if sensors.OutsideFallback, err = readFallbackConfig(v, "outside"); err != nil {
+		return sensor.Sensors{}, err
+	}
+	lg.Infof("Inside fallback:  backup %s - external = %t - hold time = %s", sensors.InsideBackup.MacAddress,
+		sensors.InsideFallback.UseExternal, sensors.InsideFallback.HoldTime)
+	lg.Infof("Outside fallback: backup %s - external = %t - hold time = %s", sensors.OutsideBackup.MacAddress,
+		sensors.OutsideFallback.UseExternal, sensors.OutsideFallback.HoldTime)
+	return sensors, nil
+}
+
+// sensorEntry is an entry of the "sensors" list.
+type sensorEntry struct {
+	Mac                    string  `mapstructure:"mac"`
+	Name                   string  `mapstructure:"name"`
+	Role                   string  `mapstructure:"role"`
+	TemperatureCalibration float64 `mapstructure:"temperature-calibration"`
+	HumidityCalibration    float64 `mapstructure:"humidity-calibration"`
+	TemperatureGain        float64 `mapstructure:"temperature-gain"`
+	HumidityGain           float64 `mapstructure:"humidity-gain"`
+}
+
+// readDevices reads the sensors of a zone: the sensors of the "inside" and "outside" sections, which are named
+// "Inside" and "Outside", and the entries of the "sensors" list with a MAC address, a name, a role and the
+// calibration. A zone needs at least one inside and one outside sensor.
+func readDevices(v *viper.Viper) ([]*sensor.Device, error) {
+	var devices []*sensor.Device
+	for _, role := range []sensor.Role{sensor.RoleInside, sensor.RoleOutside} {
+		key := string(role)
+		if mac := v.GetString(key + ".mac"); mac != "" {
+			calibration, err := readCalibration(v, key+".")
+			if err != nil {
+				return nil, err
+			}
+			devices = append(devices, sensor.NewDevice(strings.ToUpper(key[:1])+key[1:], mac, role, calibration,
+				maxSensorData))
+		}
+	}
+	var entries []sensorEntry
+	if err := v.UnmarshalKey("sensors", &entries); err != nil {
+		return nil, fmt.Errorf("Invalid sensors! %s", err)
+	}
+	for i, entry := range entries {
+		name := entry.Name
+		if name == "" {
+			name = fmt.Sprintf("Sensor%d", i+1)
+		}
+		role := sensor.Role(strings.ToLower(entry.Role))
+		if !slices.Contains(sensor.Roles, role) {
+			return nil, fmt.Errorf("Invalid role '%s' of sensor '%s'! "+
+				"Must be inside, outside, reference or monitor.", entry.Role, name)
+		}
+		devices = append(devices, sensor.NewDevice(name, entry.Mac, role, sensor.SensorCalibration{
+			Temperature:     entry.TemperatureCalibration,
+			Humidity:        entry.HumidityCalibration,
+			TemperatureGain: entry.TemperatureGain,
+			HumidityGain:    entry.HumidityGain,
+		}, maxSensorData))
+		if err := checkCalibration(name, devices[len(devices)-1].Calibration); err != nil {
+			return nil, err
+		}
+	}
+
+	names := make(map[string]bool)
+	macs := make(map[string]bool)
+	roles := make(map[sensor.Role]int)
+	for _, device := range devices {
+		device.MacAddress = strings.ToUpper(device.MacAddress)
+		if len(device.MacAddress) != 17 {
+			return nil, fmt.Errorf("Invalid MAC address of sensor '%s'! Must be 17 characters long.", device.Name)
+		}
+		if names[device.Name] || macs[device.MacAddress] {
+			return nil, fmt.Errorf("Invalid sensor '%s'! Names and MAC addresses must be unique.", device.Name)
+		}
+		names[device.Name] = true
+		macs[device.MacAddress] = true
+		roles[device.Role]++
+		lg.Infof("Sensor %-10s %-9s MAC %s - Temp cal = %.2f - Humidity cal = %.2f", device.Name, device.Role,
+			device.MacAddress, device.Calibration.Temperature, device.Calibration.Humidity)
+	}
+	if roles[sensor.RoleInside] == 0 || roles[sensor.RoleOutside] == 0 {
+		return nil, errors.New("Invalid sensors! At least one inside and one outside sensor is needed.")
+	}
+	return devices, nil
+}
+
+// readCalibration reads the offsets and gains of the sensor section with the given key prefix.
+func readCalibration(v *viper.Viper, prefix string) (sensor.SensorCalibration, error) {
+	calibration := sensor.SensorCalibration{
+		Temperature:     v.GetFloat64(prefix + "temperature-calibration"),
+		Humidity:        v.GetFloat64(prefix + "humidity-calibration"),
+		TemperatureGain: v.GetFloat64(prefix + "temperature-gain"),
+		HumidityGain:    v.GetFloat64(prefix + "humidity-gain"),
+	}
+	if err := checkCalibration(strings.TrimSuffix(prefix, "."), calibration); err != nil {
+		return sensor.SensorCalibration{}, err
+	}
+	return calibration, nil
+}
+
+// checkCalibration validates the gains of a sensor calibration. An unset gain of 0 is valid.
+func checkCalibration(name string, calibration sensor.SensorCalibration) error {
+	for _, gain := range []float64{calibration.TemperatureGain, calibration.HumidityGain} {
+		if gain != 0 && (gain < 0.5 || gain > 2) {
+			return fmt.Errorf("Invalid calibration gain of sensor '%s'! Must be between 0.5 and 2.", name)
+		}
+	}
+	return nil
+}
+
+// readCombine reads how the readings of several inside or outside sensors are combined.
+func readCombine(v *viper.Viper, role string) (sensor.Combine, error) {
+	combine := sensor.Combine(strings.ToLower(v.GetString("combine." + role)))
+	switch combine {
+	case sensor.CombineAverage, sensor.CombineMin, sensor.CombineMax:
+		return combine, nil
+	default:
+		return "", fmt.Errorf("Invalid combination of the %s sensors! Must be average, min or max.", role)
+	}
+}
+
+// readFilterConfig reads and validates the "filter" section with the filters of the sensor readings.
+func readFilterConfig(v *viper.Viper) (sensor.FilterConfig, error) {
+	filter := sensor.FilterConfig{
+		HampelWindow:    v.GetInt("filter.hampelWindow"),
+		HampelThreshold: v.GetFloat64("filter.hampelThreshold"),
+		MaxTempRate:     v.GetFloat64("filter.maxTempRate"),
+		MaxHumRate:      v.GetFloat64("filter.maxHumRate"),
+		EmaAlpha:        v.GetFloat64("filter.emaAlpha"),
+	}
+	if err := checkFilterConfig(filter); err != nil {
+		return sensor.FilterConfig{}, err
+	}
+	lg.Infof("Filter: Hampel window = %d - threshold = %.1f - max rate = %.1f°C/min, %.1f%%/min - EMA = %.2f",
+		filter.HampelWindow, filter.HampelThreshold, filter.MaxTempRate, filter.MaxHumRate, filter.EmaAlpha)
+	return filter, nil
+}
+
+// checkFilterConfig validates the ranges of the filters of the sensor readings.
+func checkFilterConfig(filter sensor.FilterConfig) error {
+	if filter.HampelWindow != 0 && (filter.HampelWindow < 3 || filter.HampelWindow > 50) {
+		return errors.New("Invalid Hampel window! Must be 0 (disabled) or between 3 and 50 readings.")
+	}
+	if filter.HampelThreshold < 1 || filter.HampelThreshold > 10 {
+		return errors.New("Invalid Hampel threshold! Must be between 1 and 10.")
+	}
+	if filter.MaxTempRate < 0 || filter.MaxTempRate > 20 {
+		return errors.New("Invalid maximum temperature rate! Must be between 0 (disabled) and 20°C per minute.")
+	}
+	if filter.MaxHumRate < 0 || filter.MaxHumRate > 100 {
+		return errors.New("Invalid maximum humidity rate! Must be between 0 (disabled) and 100% per minute.")
+	}
+	if filter.EmaAlpha < 0 || filter.EmaAlpha > 1 {
+		return errors.New("Invalid EMA smoothing factor! Must be between 0 and 1.")
+	}
+	return nil
+}
+
+// readAverageWindow reads the time window of the averages. A window of 0 minutes averages the last readings of
+// every sensor instead.
+func readAverageWindow(v *viper.Viper) (time.Duration, error) {
+	minutes := v.GetInt("average.windowMinutes")
+	if minutes < 0 || minutes > 120 {
+		return 0, errors.New("Invalid averaging window! Must be between 0 (last readings) and 120 minutes.")
+	}
+	if minutes == 0 {
+		lg.Infof("Averaging: last %d readings", maxSensorData)
+	} else {
+		lg.Infof("Averaging: last %d minutes", minutes)
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
+
+// readBatteryConfig reads the battery level that raises a low battery warning and the days before the estimated
+// end of the battery that raise it early.
+func readBatteryConfig(v *viper.Viper) (int, float64, error) {
+	lowPercent := v.GetInt("battery.lowPercent")
+	if lowPercent < 5 || lowPercent > 50 {
+		return 0, 0, errors.New("Invalid low battery level! Must be between 5 and 50%.")
+	}
+	warnDays := v.GetFloat64("battery.warnDays")
+	if warnDays < 0 || warnDays > 90 {
+		return 0, 0, errors.New("Invalid battery warning days! Must be between 0 (disabled) and 90 days.")
+	}
+	lg.Infof("Battery: low level = %d%% - warning %.0f days before empty", lowPercent, warnDays)
+	return lowPercent, warnDays, nil
+}
+
+// readFallbackConfig reads the fallback configuration of the inside or outside sensor.
+func readFallbackConfig(v *viper.Viper, name string) (sensor.FallbackConfig, error) {
+	holdHours := v.GetFloat64(name + ".holdHours")
+	if holdHours < 0 || holdHours > 72 {
+		return sensor.FallbackConfig{}, fmt.Errorf("Invalid hold time of the %s sensor! "+
+			"Must be between 0 and 72 hours.", name)
+	}
+	return sensor.FallbackConfig{
+		UseExternal: v.GetBool(name + ".external"),
+		HoldTime:    time.Duration(holdHours * float64(time.Hour)),
+	}, nil
+}
+
+// readFanConfig reads and validates the "fan" section and creates the configured fan controller.
+func readFanConfig(v *viper.Viper) (sensor.FanConfig, control.Controller, error) {
+	fanConfig := sensor.FanConfig{
+		Controller:        v.GetString("fan.controller"),
+		MinDiff:           v.GetFloat64("fan.minDiff"),
+		Hysteresis:        v.GetFloat64("fan.hysteresis"),
+		MinHumidityInside: v.GetFloat64("fan.minHumidityInside"),
+		MinTempInside:     v.GetFloat64("fan.minTempInside"),
+		MinTempOutside:    v.GetFloat64("fan.minTempOutside"),
+		MinTrend:          v.GetFloat64("fan.minTrend"),
+		MinAbsDiff:        v.GetFloat64("fan.minAbsDiff"),
+		AbsHysteresis:     v.GetFloat64("fan.absHysteresis"),
+		MinOnMinutes:      v.GetInt("fan.minOnMinutes"),
+		MinOffMinutes:     v.GetInt("fan.minOffMinutes"),
+		MinSpeed:          v.GetInt("fan.minSpeed"),
+		FullSpeedDiff:     v.GetFloat64("fan.fullSpeedDiff"),
+		MoldIndexLimit:    v.GetFloat64("fan.moldIndexLimit"),
+		MoldMinDiff:       v.GetFloat64("fan.moldMinDiff"),
+		FaultSeconds:      v.GetInt("fan.faultSeconds"),
+	}
+	if err := checkFanConfig(fanConfig); err != nil {
+		return sensor.FanConfig{}, nil, err
+	}
+	controller, err := control.New(fanConfig.Controller)
+	if err != nil {
+		return sensor.FanConfig{}, nil, fmt.Errorf("Invalid fan controller! %s", err)
+	}
+	if fanConfig.MoldIndexLimit > 0 && controller.Name() == control.AbsHumidityName {
+		return sensor.FanConfig{}, nil, errors.New("Invalid mold index limit! " +
+			"The absolute humidity controller doesn't support it, must be 0.")
+	}
+	lg.Infof("Fan controller: %s - min diff = %.1f - hysteresis = %.1f",
+		controller.Name(), fanConfig.MinDiff, fanConfig.Hysteresis)
+	return fanConfig, controller, nil
+}
+
+// checkFanConfig validates the ranges of the values of the "fan" section.
+func checkFanConfig(fanConfig sensor.FanConfig) error {
 	if fanConfig.MinDiff < 1 || fanConfig.MinDiff > 10 {
-		lg.Fatal("Invalid minimal difference! Must be between 1 and 10°C.")
+		return errors.New("Invalid minimal difference! Must be between 1 and 10°C.")
 	}
-	fanConfig.Hysteresis = viper.GetFloat64("fan.hysteresis")
 	if fanConfig.Hysteresis < 0.1 || fanConfig.Hysteresis > 5 {
-		lg.Fatal("Invalid hysteresis! Must be between 0.1 and 5°C.")
+		return errors.New("Invalid hysteresis! Must be between 0.1 and 5°C.")
 	}
-	fanConfig.MinHumidityInside = viper.GetFloat64("fan.minHumidityInside")
 	if fanConfig.MinHumidityInside < 30 || fanConfig.MinHumidityInside > 70 {
-		lg.Fatal("Invalid minimal inside humidity! Must be between 30 and 70%.")
+		return errors.New("Invalid minimal inside humidity! Must be between 30 and 70%.")
 	}
-	fanConfig.MinTempInside = viper.GetFloat64("fan.minTempInside")
 	if fanConfig.MinTempInside < 10 || fanConfig.MinTempInside > 40 {
-		lg.Fatal("Invalid minimal inside temperature! Must be between 10 and 40°C.")
+		return errors.New("Invalid minimal inside temperature! Must be between 10 and 40°C.")
 	}
-	fanConfig.MinTempOutside = viper.GetFloat64("fan.minTempOutside")
 	if fanConfig.MinTempOutside < -20 || fanConfig.MinTempOutside > 20 {
-		lg.Fatal("Invalid minimal outside temperature! Must be between -20 and 20°C.")
+		return errors.New("Invalid minimal outside temperature! Must be between -20 and 20°C.")
+	}
+	if fanConfig.MinTrend < 0 || fanConfig.MinTrend > 5 {
+		return errors.New("Invalid minimal trend! Must be between 0 and 5°C/h.")
+	}
+	if fanConfig.MinAbsDiff < 0.1 || fanConfig.MinAbsDiff > 10 {
+		return errors.New("Invalid minimal absolute humidity difference! Must be between 0.1 and 10 g/m³.")
+	}
+	if fanConfig.AbsHysteresis < 0.1 || fanConfig.AbsHysteresis > 5 {
+		return errors.New("Invalid absolute humidity hysteresis! Must be between 0.1 and 5 g/m³.")
+	}
+	if fanConfig.MinOnMinutes < 0 || fanConfig.MinOnMinutes > 120 {
+		return errors.New("Invalid minimal run time! Must be between 0 and 120 minutes.")
+	}
+	if fanConfig.MinOffMinutes < 0 || fanConfig.MinOffMinutes > 120 {
+		return errors.New("Invalid minimal pause time! Must be between 0 and 120 minutes.")
 	}
+	if fanConfig.MinSpeed < 1 || fanConfig.MinSpeed > 100 {
+		return errors.New("Invalid minimal fan speed! Must be between 1 and 100%.")
+	}
+	if fanConfig.FullSpeedDiff < 0.5 || fanConfig.FullSpeedDiff > 10 {
+		return errors.New("Invalid full speed difference! Must be between 0.5 and 10°C.")
+	}
+	if fanConfig.MoldIndexLimit < 0 || fanConfig.MoldIndexLimit > 6 {
+		return errors.New("Invalid mold index limit! Must be between 0 (disabled) and 6.")
+	}
+	if fanConfig.MoldMinDiff < 0.5 || fanConfig.MoldMinDiff > 10 {
+		return errors.New("Invalid minimal difference at mold risk! Must be between 0.5 and 10°C.")
+	}
+	if fanConfig.FaultSeconds < 5 || fanConfig.FaultSeconds > 3600 {
+		return errors.New("Invalid fault delay! Must be between 5 and 3600 seconds.")
+	}
+	return nil
+}
 
-	influxConfig.Enabled = viper.GetBool("influx.enabled")
-	influxConfig.Org = viper.GetString("influx.org")
-	influxConfig.Bucket = viper.GetString("influx.bucket")
-	influxConfig.Token = viper.GetString("influx.token")
-	influxConfig.Url = viper.GetString("influx.url")
+// readRules reads and validates the rules of the "fan" section.
+func readRules(v *viper.Viper) (control.Rules, error) {
+	rules, err := control.ParseRules(v.GetStringSlice("fan.rules"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid fan rules! %s", err)
+	}
+	for i, rule := range rules {
+		lg.Infof("Rule %d: %s", i+1, rule.Source)
+	}
+	return rules, nil
+}
+
+// setConfigDefaults sets the default values for optional configuration keys, so that older configuration
+// files keep working.
+func setConfigDefaults(v *viper.Viper) {
+	v.SetDefault("fan.controller", "dewpoint")
+	v.SetDefault("fan.minTrend", 0.5)
+	v.SetDefault("fan.minAbsDiff", 1.0)
+	v.SetDefault("fan.absHysteresis", 0.5)
+	v.SetDefault("fan.minOnMinutes", 0)
+	v.SetDefault("fan.minOffMinutes", 0)
+	v.SetDefault("fan.minSpeed", 30)
+	v.SetDefault("fan.fullSpeedDiff", 3.0)
+	v.SetDefault("fan.pwmPin", "")
+	v.SetDefault("fan.moldIndexLimit", 0.0)
+	v.SetDefault("fan.moldMinDiff", 2.0)
+	v.SetDefault("fan.rules", []string{})
+	v.SetDefault("fan.faultSeconds", 60)
+	v.SetDefault("combine.inside", string(sensor.CombineAverage))
+	v.SetDefault("combine.outside", string(sensor.CombineAverage))
+	v.SetDefault("filter.hampelWindow", 0)
+	v.SetDefault("filter.hampelThreshold", 3.0)
+	v.SetDefault("filter.maxTempRate", 0.0)
+	v.SetDefault("filter.maxHumRate", 0.0)
+	v.SetDefault("filter.emaAlpha", 0.0)
+	v.SetDefault("average.windowMinutes", 0)
+	v.SetDefault("battery.lowPercent", 20)
+	v.SetDefault("battery.warnDays", 14.0)
+	v.SetDefault("history.enabled", false)
+	v.SetDefault("history.minGapMinutes", 15)
+	v.SetDefault("history.maxHours", 24)
+	v.SetDefault("scanner.timeoutSeconds", 300)
+	v.SetDefault("scanner.maxBackoffSeconds", 300)
+	v.SetDefault("fan.switchOnPin", "")
+	v.SetDefault("fan.switchOffPin", "")
+	v.SetDefault("schedule.timezone", "Local")
 }
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"dpf-bt/gpio"
+	"dpf-bt/sensor"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// loadConfig replaces the global configuration with the given JSON content.
+func loadConfig(t *testing.T, content string) {
+	t.Helper()
+	viper.Reset()
+	viper.SetConfigType("json")
+	setConfigDefaults(viper.GetViper())
+	if err := viper.ReadConfig(strings.NewReader(content)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(viper.Reset)
+}
+
+const testFanSection = `"fan": {"minDiff": 3.0, "hysteresis": 1.0, "minHumidityInside": 30.0,
+	"minTempInside": 10.0, "minTempOutside": -10.0}`
+
+func TestReadZonesLegacy(t *testing.T) {
+	loadConfig(t, `{
+		"inside": {"mac": "9D:8B:00:00:18:BD", "temperature-calibration": -1.5},
+		"outside": {"mac": "9D:F2:00:00:14:B5"},
+		`+testFanSection+`
+	}`)
+
+	got, err := readZones()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("expected 1 zone, got %d", len(got))
+	}
+	z := got[0]
+	if z.Name != "" {
+		t.Errorf("expected unnamed zone, got '%s'", z.Name)
+	}
+	if len(z.Devices) != 2 || z.Devices[0].Name != "Inside" || z.Devices[0].Role != sensor.RoleInside ||
+		z.Devices[0].MacAddress != "9D:8B:00:00:18:BD" || z.Devices[0].Calibration.Temperature != -1.5 ||
+		z.Devices[1].Name != "Outside" || z.Devices[1].Role != sensor.RoleOutside {
+		t.Errorf("unexpected sensors %+v", z.Devices)
+	}
+	if z.Sensors.InsideCombine != sensor.CombineAverage || z.Sensors.OutsideCombine != sensor.CombineAverage {
+		t.Errorf("expected average as default combination, got %s and %s", z.Sensors.InsideCombine,
+			z.Sensors.OutsideCombine)
+	}
+	if z.fanConfig.MinDiff != 3.0 || z.fanConfig.FaultSeconds != 60 || z.controller.Name() != "dewpoint" {
+		t.Errorf("unexpected fan config %+v with controller %s", z.fanConfig, z.controller.Name())
+	}
+	if z.gpioConfig != (gpio.Config{}) {
+		t.Errorf("expected default GPIO config, got %+v", z.gpioConfig)
+	}
+	if z.Store.Inside.Window() != 0 || z.Devices[1].Store.Window() != 0 {
+		t.Errorf("expected the last readings to be averaged by default, got a window of %s", z.Store.Inside.Window())
+	}
+}
+
+func TestReadZones(t *testing.T) {
+	loadConfig(t, `{
+		`+testFanSection+`,
+		"battery": {"lowPercent": 15},
+		"zones": [
+			{
+				"name": "North",
+				"inside": {"mac": "9D:8B:00:00:18:BD"},
+				"outside": {"mac": "9D:F2:00:00:14:B5"}
+			},
+			{
+				"inside": {"mac": "9D:8B:00:00:21:0A"},
+				"outside": {"mac": "9D:F2:00:00:14:B5"},
+				"fanPin": "GPIO24",
+				"sensePin": "GPIO23",
+				"switchOnPin": "GPIO5",
+				"switchOffPin": "GPIO6",
+				"battery": {"warnDays": 7},
+				"fan": {"minDiff": 4.0, "controller": "absolute", "rules": ["outside.humidity > 95 => off 'fog'"]}
+			}
+		]
+	}`)
+
+	got, err := readZones()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 zones, got %d", len(got))
+	}
+	if got[0].Name != "North" || got[1].Name != "Zone2" {
+		t.Errorf("unexpected zone names '%s' and '%s'", got[0].Name, got[1].Name)
+	}
+	if got[0].Devices[1].MacAddress != got[1].Devices[1].MacAddress {
+		t.Errorf("expected shared outside sensor")
+	}
+	if got[0].fanConfig.MinDiff != 3.0 || got[0].controller.Name() != "dewpoint" {
+		t.Errorf("unexpected fan config of zone 1: %+v", got[0].fanConfig)
+	}
+	if got[1].fanConfig.MinDiff != 4.0 || got[1].fanConfig.Hysteresis != 1.0 || got[1].controller.Name() != "absolute" {
+		t.Errorf("unexpected fan config of zone 2: %+v", got[1].fanConfig)
+	}
+	if len(got[0].rules) != 0 || len(got[1].rules) != 1 || got[1].rules[0].Reason != "fog" {
+		t.Errorf("unexpected rules %+v and %+v", got[0].rules, got[1].rules)
+	}
+	expectedPins := gpio.Config{FanPin: "GPIO24", SensePin: "GPIO23", SwitchOnPin: "GPIO5", SwitchOffPin: "GPIO6"}
+	if got[1].gpioConfig != expectedPins {
+		t.Errorf("expected GPIO config %+v, got %+v", expectedPins, got[1].gpioConfig)
+	}
+	if got[0].lowBatteryPercent != 15 || got[0].batteryWarnDays != 14 ||
+		got[1].lowBatteryPercent != 15 || got[1].batteryWarnDays != 7 {
+		t.Errorf("unexpected battery config %d/%.0f and %d/%.0f", got[0].lowBatteryPercent, got[0].batteryWarnDays,
+			got[1].lowBatteryPercent, got[1].batteryWarnDays)
+	}
+}
+
+func TestReadZonesSensorList(t *testing.T) {
+	loadConfig(t, `{
+		"sensors": [
+			{"mac": "9d:8b:00:00:18:bd", "name": "North", "role": "inside", "temperature-calibration": -0.5,
+				"temperature-gain": 1.05},
+			{"mac": "9D:8B:00:00:21:0A", "name": "South", "role": "inside"},
+			{"mac": "9D:F2:00:00:14:B5", "name": "Garden", "role": "Outside", "humidity-calibration": 1.2},
+			{"mac": "9D:F2:00:00:33:01", "name": "Attic", "role": "monitor"},
+			{"mac": "9D:F2:00:00:33:02", "role": "reference"}
+		],
+		"combine": {"inside": "max"},
+		"filter": {"hampelWindow": 9, "emaAlpha": 0.3},
+		`+testFanSection+`
+	}`)
+
+	got, err := readZones()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("expected 1 zone, got %d", len(got))
+	}
+	z := got[0]
+	expected := []struct {
+		name string
+		mac  string
+		role sensor.Role
+	}{
+		{"North", "9D:8B:00:00:18:BD", sensor.RoleInside},
+		{"South", "9D:8B:00:00:21:0A", sensor.RoleInside},
+		{"Garden", "9D:F2:00:00:14:B5", sensor.RoleOutside},
+		{"Attic", "9D:F2:00:00:33:01", sensor.RoleMonitor},
+		{"Sensor5", "9D:F2:00:00:33:02", sensor.RoleReference},
+	}
+	if len(z.Devices) != len(expected) {
+		t.Fatalf("expected %d sensors, got %d", len(expected), len(z.Devices))
+	}
+	for i, e := range expected {
+		if d := z.Devices[i]; d.Name != e.name || d.MacAddress != e.mac || d.Role != e.role {
+			t.Errorf("expected sensor %+v, got %s %s %s", e, d.Name, d.MacAddress, d.Role)
+		}
+	}
+	if z.Devices[0].Calibration.Temperature != -0.5 || z.Devices[0].Calibration.TemperatureGain != 1.05 ||
+		z.Devices[2].Calibration.Humidity != 1.2 {
+		t.Errorf("unexpected calibration %+v and %+v", z.Devices[0].Calibration, z.Devices[2].Calibration)
+	}
+	if z.Sensors.InsideCombine != sensor.CombineMax || z.Sensors.OutsideCombine != sensor.CombineAverage {
+		t.Errorf("expected max and average, got %s and %s", z.Sensors.InsideCombine, z.Sensors.OutsideCombine)
+	}
+	expectedFilter := sensor.FilterConfig{HampelWindow: 9, HampelThreshold: 3, EmaAlpha: 0.3}
+	for _, d := range z.Devices {
+		if d.Store.Filter() != expectedFilter {
+			t.Errorf("expected filter %+v of sensor %s, got %+v", expectedFilter, d.Name, d.Store.Filter())
+		}
+	}
+}
+
+func TestReadZonesInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		section  string
+		expected string
+	}{
+		{
+			name: "RuleTypo",
+			section: `"fan": {"minDiff": 3.0, "hysteresis": 1.0, "minHumidityInside": 30.0, "minTempInside": 10.0,
+				"minTempOutside": -10.0, "rules": ["inside.humidity > => on"]}`,
+			expected: "Invalid fan rules!",
+		},
+		{
+			name: "MinDiffOutOfRange",
+			section: `"fan": {"minDiff": 30.0, "hysteresis": 1.0, "minHumidityInside": 30.0, "minTempInside": 10.0,
+				"minTempOutside": -10.0}`,
+			expected: "Invalid minimal difference!",
+		},
+		{
+			name:     "UnknownCombination",
+			section:  `"combine": {"inside": "median"}, ` + testFanSection,
+			expected: "Invalid combination of the inside sensors!",
+		},
+		{
+			name:     "HampelWindowOutOfRange",
+			section:  `"filter": {"hampelWindow": 2}, ` + testFanSection,
+			expected: "Invalid Hampel window!",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loadConfig(t, `{
+				"inside": {"mac": "9D:8B:00:00:18:BD"},
+				"outside": {"mac": "9D:F2:00:00:14:B5"},
+				`+tt.section+`
+			}`)
+			got, err := readZones()
+			if err == nil || !strings.HasPrefix(err.Error(), tt.expected) {
+				t.Errorf("expected error '%s', got %v and %d zones", tt.expected, err, len(got))
+			}
+		})
+	}
+}
+
+func TestReadConfigKeepsPreviousOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	write := func(minDiff string, rules string) {
+		content := `{
+			"lcd": {"delay": 3, "scrollSpeed": 500, "screenChange": 5},
+			"inside": {"mac": "9D:8B:00:00:18:BD"},
+			"outside": {"mac": "9D:F2:00:00:14:B5"},
+			"fan": {"minDiff": ` + minDiff + `, "hysteresis": 1.0, "minHumidityInside": 30.0, "minTempInside": 10.0,
+				"minTempOutside": -10.0, "rules": [` + rules + `]}
+		}`
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	viper.Reset()
+	viper.SetConfigFile(path)
+	t.Cleanup(func() {
+		viper.Reset()
+		zones = nil
+		scanZones = nil
+	})
+
+	write("3.0", `"outside.humidity > 95 => off 'fog'"`)
+	if err := readConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	z := zones[0]
+	// a typo in a rule must not stop the running fan control or change the values that were valid
+	write("4.0", `"outside.humidity > => off"`)
+	if err := readConfig(); err == nil || !strings.HasPrefix(err.Error(), "Invalid fan rules!") {
+		t.Fatalf("expected an error of the rules, got %v", err)
+	}
+	if len(zones) != 1 || zones[0] != z || z.fanConfig.MinDiff != 3.0 || len(z.rules) != 1 {
+		t.Errorf("expected the previous configuration to be kept, got %+v with %d rules", z.fanConfig, len(z.rules))
+	}
+
+	write("4.0", `"outside.humidity > 95 => off 'fog'"`)
+	if err := readConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if z.fanConfig.MinDiff != 4.0 {
+		t.Errorf("expected the valid reload to be applied, got %+v", z.fanConfig)
+	}
+}
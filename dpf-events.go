@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// maxEvents is the number of events that are kept for the /events endpoint.
+const maxEvents = 100
+
+// event is a noteworthy incident of a zone, e.g. a fan fault, that is logged and shown by the /events endpoint.
+type event struct {
+	Time    time.Time `json:"time"`
+	Zone    string    `json:"zone"`
+	Type    string    `json:"type"`
+	Message string    `json:"message"`
+}
+
+// eventLog keeps the last events in memory. Events are added by several goroutines, so access is synchronized.
+type eventLog struct {
+	mu     sync.Mutex
+	events []event
+}
+
+var events eventLog
+
+// add logs the event as a warning and appends it. When the log is full, the oldest event is dropped.
+func (l *eventLog) add(e event) {
+	lg.Warnf("Event %s '%s': %s", e.Type, e.Zone, e.Message)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.events) >= maxEvents {
+		l.events = l.events[1:]
+	}
+	l.events = append(l.events, e)
+}
+
+// list returns a copy of the events, the oldest first.
+func (l *eventLog) list() []event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	list := make([]event, len(l.events))
+	copy(list, l.events)
+	return list
+}
+
+// addEvent records an event of the given type for the zone at the current time.
+func addEvent(z *zone, eventType string, message string) {
+	events.add(event{Time: clock(), Zone: z.title(), Type: eventType, Message: message})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"dpf-bt/sensor"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// historyStateFile is the name of the file beside the binary that keeps the times of the last readings of the
+	// sensors across restarts, so that the gap of a restart can be downloaded from the sensors.
+	historyStateFile = "history-state.json"
+	// historySaveInterval is the interval for saving the history state.
+	historySaveInterval = time.Minute
+)
+
+// loadHistoryState restores the times of the last readings of the sensors from the given file. A missing file
+// is not an error, since it doesn't exist before the first save.
+func loadHistoryState(path string) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		lg.Errorf("Couldn't read history state: %s", err)
+		return
+	}
+	var state map[string]time.Time
+	if err = json.Unmarshal(content, &state); err != nil {
+		lg.Errorf("Couldn't parse history state: %s", err)
+		return
+	}
+	historySync.Restore(state)
+}
+
+// saveHistoryState writes the times of the last readings of the sensors to the given file.
+func saveHistoryState(path string) {
+	content, err := json.MarshalIndent(historySync.State(), "", "  ")
+	if err != nil {
+		lg.Errorf("Couldn't encode history state: %s", err)
+		return
+	}
+	if err = writeFileAtomic(path, content); err != nil {
+		lg.Errorf("Couldn't write history state: %s", err)
+	}
+}
+
+// persistHistoryState saves the history state periodically.
+func persistHistoryState(path string) {
+	ticker := time.NewTicker(historySaveInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		saveHistoryState(path)
+	}
+}
+
+// addBackfill records the downloaded readings of a sensor as event of its zone and queues them for InfluxDB.
+func addBackfill(scanZone *sensor.Zone, device *sensor.Device, readings []sensor.SensorData) {
+	for _, z := range zones {
+		if z.Zone != scanZone {
+			continue
+		}
+		addEvent(z, "history", fmt.Sprintf("added %d logged readings of sensor %s since %s", len(readings),
+			device.Name, formatTime(readings[0].Scanned)))
+		if !influxConfig.Enabled {
+			continue
+		}
+		select {
+		case backfills <- backfill{zone: z, device: device, readings: readings}:
+		default:
+			lg.Warnf("Couldn't queue the logged readings of sensor %s for InfluxDB", device.Name)
+		}
+	}
+}
@@ -2,91 +2,220 @@ package main
 
 import (
 	"context"
+	"dpf-bt/sensor"
 	influxdb "github.com/influxdata/influxdb-client-go/v2"
 	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"slices"
 	"time"
 )
 
 const (
 	tickInterval       = time.Minute
 	minRequiredSamples = 10
-	measurementName    = "dp"
+	// minCoverage is the share of the averaging window that must be covered by readings.
+	minCoverage     = 0.8
+	measurementName = "dp"
+	// deviceMeasurementName is the measurement of the readings of single sensors.
+	deviceMeasurementName = "sensor"
 )
 
-// sendToInfluxDb sends aggregated sensor data to InfluxDB at scheduled intervals
+// backfill holds the logged readings of a sensor that were downloaded after a gap.
+type backfill struct {
+	zone     *zone
+	device   *sensor.Device
+	readings []sensor.SensorData
+}
+
+// backfills queues the downloaded readings for InfluxDB.
+var backfills = make(chan backfill, 16)
+
+// sendToInfluxDb sends aggregated sensor data of every zone to InfluxDB at scheduled intervals and the downloaded
+// readings of the sensors as soon as they are added.
 func sendToInfluxDb() {
 	client := influxdb.NewClient(influxConfig.Url, influxConfig.Token)
 	writeAPI := client.WriteAPIBlocking(influxConfig.Org, influxConfig.Bucket)
-	tags := make(map[string]string)
 
 	ticker := time.NewTicker(tickInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case b := <-backfills:
+			if err := writeAPI.WritePoint(context.Background(), createBackfillPoints(b)...); err != nil {
+				lg.Error(err)
+			}
 		case <-ticker.C:
-			if !hasEnoughData() {
-				logInsufficientData()
-				continue
+			now := clock()
+			for _, z := range zones {
+				for _, device := range separateDevices(z) {
+					if !hasEnoughSamples(&device.Store) {
+						continue
+					}
+					if err := writeAPI.WritePoint(context.Background(), createDevicePoint(z, device, now)); err != nil {
+						lg.Error(err)
+					}
+				}
+				if !hasEnoughData(z) {
+					logInsufficientData(z)
+					continue
+				}
+				logDataTransmissionStart(z)
+				point := createDataPoint(z, zoneTags(z), now)
+				if err := writeAPI.WritePoint(context.Background(), point); err != nil {
+					lg.Error(err)
+					continue
+				}
+				logAverageValues(z)
 			}
-			logDataTransmissionStart()
-			point := createDataPoint(tags)
-			if err := writeAPI.WritePoint(context.Background(), point); err != nil {
-				lg.Error(err)
-				continue
+		}
+	}
+}
+
+// zoneTags returns the tags of the data points of the zone. Named zones are tagged with their name, an unnamed
+// zone has no tags, so that existing dashboards keep working.
+func zoneTags(z *zone) map[string]string {
+	tags := make(map[string]string)
+	if z.Name != "" {
+		tags["zone"] = z.Name
+	}
+	return tags
+}
+
+// separateDevices returns the sensors of the zone whose readings are sent separately: the reference and monitor
+// sensors and the inside and outside sensors, if several of them are combined.
+func separateDevices(z *zone) []*sensor.Device {
+	var devices []*sensor.Device
+	for _, device := range z.Devices {
+		switch device.Role {
+		case sensor.RoleInside, sensor.RoleOutside:
+			if len(z.DevicesWithRole(device.Role)) > 1 {
+				devices = append(devices, device)
 			}
-			logAverageValues()
+		default:
+			devices = append(devices, device)
 		}
 	}
+	return devices
 }
 
-// hasEnoughData checks if both inside and outside sensor data lists have at least the minimum required
-// number of samples.
-func hasEnoughData() bool {
-	return sensorStore.Inside.Size() >= minRequiredSamples &&
-		sensorStore.Outside.Size() >= minRequiredSamples
+// createDevicePoint generates a data point at now with the average readings and the statistics of the
+// advertisements of a single sensor, tagged with the zone, the name and the role of the sensor.
+func createDevicePoint(z *zone, device *sensor.Device, now time.Time) *write.Point {
+	tags := zoneTags(z)
+	tags["sensor"] = device.Name
+	tags["role"] = string(device.Role)
+	fields := map[string]interface{}{
+		"temp":        device.Store.AverageTemperature(),
+		"hum":         device.Store.AverageHumidity(),
+		"dewpoint":    device.Store.AverageDewPoint(),
+		"bat":         device.Data.BatLevel,
+		"bat_percent": device.Data.BatPercent,
+		"bat_low":     device.LowBattery,
+		"rejected":    device.Store.FilterStats().Rejected(),
+		"packets_min": device.Stats.PacketsPerMinute(now),
+		"loss":        packetLossPercent(&device.Stats, now),
+		"rssi":        device.Stats.RSSIAverage(),
+		"reboots":     device.Stats.Reboots,
+	}
+	return write.NewPoint(deviceMeasurementName, tags, fields, now)
+}
+
+// createBackfillPoints generates a data point with the original time for every downloaded reading. The readings of
+// a sensor that is written separately are written like its averages, the readings of the only inside or outside
+// sensor of a zone fill the inside or outside fields of the zone's measurement.
+func createBackfillPoints(b backfill) []*write.Point {
+	separate := slices.Contains(separateDevices(b.zone), b.device)
+	suffix := "_i"
+	if b.device.Role == sensor.RoleOutside {
+		suffix = "_o"
+	}
+	points := make([]*write.Point, 0, len(b.readings))
+	for _, reading := range b.readings {
+		tags := zoneTags(b.zone)
+		if separate {
+			tags["sensor"] = b.device.Name
+			tags["role"] = string(b.device.Role)
+			points = append(points, write.NewPoint(deviceMeasurementName, tags, map[string]interface{}{
+				"temp":     reading.Temperature,
+				"hum":      reading.Humidity,
+				"dewpoint": reading.DewPoint,
+			}, reading.Scanned))
+			continue
+		}
+		points = append(points, write.NewPoint(measurementName, tags, map[string]interface{}{
+			"temp" + suffix:     reading.Temperature,
+			"hum" + suffix:      reading.Humidity,
+			"dewpoint" + suffix: reading.DewPoint,
+		}, reading.Scanned))
+	}
+	return points
+}
+
+// hasEnoughData checks if both inside and outside sensor data lists of the zone have enough samples.
+func hasEnoughData(z *zone) bool {
+	return hasEnoughSamples(&z.Store.Inside) && hasEnoughSamples(&z.Store.Outside)
+}
+
+// hasEnoughSamples checks if the readings of the store cover the minimum share of its time window. A store
+// without a time window needs at least the minimum required number of samples.
+func hasEnoughSamples(store *sensor.SensorDataList) bool {
+	if store.Window() > 0 {
+		return store.Coverage(clock()) >= minCoverage
+	}
+	return store.Size() >= minRequiredSamples
 }
 
 // logInsufficientData logs a warning when sensor data is not enough for sending to InfluxDB.
-func logInsufficientData() {
-	lg.Warnf("NOT sending to InfluxDB due to insufficient data (Inside/Outside): %d, %d",
-		sensorStore.Inside.Size(), sensorStore.Outside.Size())
+func logInsufficientData(z *zone) {
+	now := clock()
+	lg.Warnf("NOT sending %s to InfluxDB due to insufficient data (Inside/Outside): %d, %d (coverage %.0f%%, %.0f%%)",
+		z.title(), z.Store.Inside.Size(), z.Store.Outside.Size(), z.Store.Inside.Coverage(now)*100,
+		z.Store.Outside.Coverage(now)*100)
 }
 
 // logDataTransmissionStart logs the start of data transmission to InfluxDB, including the size of inside
 // and outside data lists.
-func logDataTransmissionStart() {
-	lg.Infof("Sending average values to InfluxDB (Inside/Outside): %d, %d",
-		sensorStore.Inside.Size(), sensorStore.Outside.Size())
+func logDataTransmissionStart(z *zone) {
+	lg.Infof("Sending average values of %s to InfluxDB (Inside/Outside): %d, %d",
+		z.title(), z.Store.Inside.Size(), z.Store.Outside.Size())
 }
 
-// createDataPoint generates a data point with sensor readings and additional metadata for InfluxDB storage.
-func createDataPoint(tags map[string]string) *write.Point {
+// createDataPoint generates a data point at now with sensor readings and additional metadata for InfluxDB storage. The
+// packet loss of the inside and outside sensors is the highest and the reboots the sum of the sensors of the role.
+func createDataPoint(z *zone, tags map[string]string, now time.Time) *write.Point {
 	ventingValue := 0
-	if resultData.IsOn {
+	if z.result.IsOn {
 		ventingValue = 1
 	}
 
+	lossInside, rebootsInside := z.roleLinkStats(sensor.RoleInside)
+	lossOutside, rebootsOutside := z.roleLinkStats(sensor.RoleOutside)
 	fields := map[string]interface{}{
-		"temp_i":     sensorStore.Inside.AverageTemperature(),
-		"temp_o":     sensorStore.Outside.AverageTemperature(),
-		"dewpoint_i": sensorStore.Inside.AverageDewPoint(),
-		"dewpoint_o": sensorStore.Outside.AverageDewPoint(),
-		"hum_i":      sensorStore.Inside.AverageHumidity(),
-		"hum_o":      sensorStore.Outside.AverageHumidity(),
-		"retry_i":    0,
-		"retry_o":    0,
+		"temp_i":     z.Store.Inside.AverageTemperature(),
+		"temp_o":     z.Store.Outside.AverageTemperature(),
+		"dewpoint_i": z.Store.Inside.AverageDewPoint(),
+		"dewpoint_o": z.Store.Outside.AverageDewPoint(),
+		"hum_i":      z.Store.Inside.AverageHumidity(),
+		"hum_o":      z.Store.Outside.AverageHumidity(),
+		"loss_i":     lossInside,
+		"loss_o":     lossOutside,
+		"reboots_i":  rebootsInside,
+		"reboots_o":  rebootsOutside,
+		"rejected_i": z.roleFilterStats(sensor.RoleInside).Rejected(),
+		"rejected_o": z.roleFilterStats(sensor.RoleOutside).Rejected(),
 		"vent_val":   ventingValue,
+		"speed":      z.result.FanSpeed(),
+		"mold_index": z.mold.Index,
 	}
-	return write.NewPoint(measurementName, tags, fields, time.Now())
+	return write.NewPoint(measurementName, tags, fields, now)
 }
 
 // logAverageValues logs the average temperature and humidity for both inside and outside sensor data stores.
-func logAverageValues() {
-	lg.Infof("Inside  (T/H): %5.1fC - %5.1f%%",
-		sensorStore.Inside.AverageTemperature(),
-		sensorStore.Inside.AverageHumidity())
-	lg.Infof("Outside (T/H): %5.1fC - %5.1f%%",
-		sensorStore.Outside.AverageTemperature(),
-		sensorStore.Outside.AverageHumidity())
+func logAverageValues(z *zone) {
+	lg.Infof("%s Inside  (T/H): %5.1fC - %5.1f%%", z.title(),
+		z.Store.Inside.AverageTemperature(),
+		z.Store.Inside.AverageHumidity())
+	lg.Infof("%s Outside (T/H): %5.1fC - %5.1f%%", z.title(),
+		z.Store.Outside.AverageTemperature(),
+		z.Store.Outside.AverageHumidity())
 }
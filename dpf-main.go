@@ -2,10 +2,13 @@ package main
 
 import (
 	"dpf-bt/bluetooth"
+	"dpf-bt/control"
 	"dpf-bt/display"
 	"dpf-bt/gpio"
 	"dpf-bt/sensor"
 	"dpf-bt/utility"
+	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -18,26 +21,34 @@ import (
 	bt "tinygo.org/x/bluetooth"
 )
 
-const maxSensorData = 20
+const (
+	maxSensorData = 20
+	// maxDecisions is the number of decisions per zone that are kept for the /decisions endpoint.
+	maxDecisions = 100
+)
 
 var (
 	buildTime    = "---"
 	lg           = logger.NewPackageLogger("main", logger.InfoLevel)
-	fanConfig    = sensor.FanConfig{}
 	influxConfig = sensor.InfluxDbConfig{}
-	resultData   = sensor.ResultData{}
-	sensors      = sensor.Sensors{}
-	sensorStore  = sensor.SensorStore{
-		Inside:  *sensor.NewSensorDataStore(maxSensorData),
-		Outside: *sensor.NewSensorDataStore(maxSensorData),
-	}
+	zones        []*zone
+	// scanZones holds the sensor part of the zones for the Bluetooth scanner.
+	scanZones       []*sensor.Zone
+	fanSchedule     *control.Schedule
+	clock           = time.Now
 	disp            display.Display
-	ioPins          gpio.Gpio
 	lcdDelay        int
 	lcdScrollSpeed  int
 	lcdScreenChange int
 	ipAddress       string
-	remoteOverride  = 0
+	// recorder records the received advertisements if a capture file is given.
+	recorder *bluetooth.Recorder
+	// historySync downloads the logged readings of the sensors after a gap, nil during a replay.
+	historySync   *bluetooth.HistorySync
+	historyConfig bluetooth.HistoryConfig
+	// supervisor runs the Bluetooth scan and recovers the adapter, nil during a replay.
+	supervisor    *bluetooth.Supervisor
+	scannerConfig bluetooth.SupervisorConfig
 )
 
 // The main function is the entry point of the application. It initializes configurations, hardware, and
@@ -46,6 +57,26 @@ func main() {
 	defer func() {
 		_ = logger.FinalizeLogger()
 	}()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "simulate":
+			runSimulation(os.Args[2:])
+			return
+		case "scan":
+			runScan(os.Args[2:])
+			return
+		case "calibrate":
+			runCalibration(os.Args[2:])
+			return
+		}
+	}
+	captureFile := flag.String("capture", "", "JSONL file to record the received Bluetooth advertisements to")
+	replayFile := flag.String("replay", "", "JSONL file with recorded advertisements to replay instead of scanning")
+	replaySpeed := flag.Float64("speed", 1, "speed factor of the replay, 0 replays without delay")
+	flag.Parse()
+	if *replaySpeed < 0 {
+		lg.Fatal("Invalid replay speed! Must not be negative.")
+	}
 	pathOfBinary, err := os.Executable()
 	if err != nil {
 		lg.Errorf("Couldn't get path of executable: %s", err)
@@ -56,17 +87,42 @@ func main() {
 	viper.AddConfigPath(filepath.Dir(pathOfBinary))
 	viper.OnConfigChange(func(e fsnotify.Event) {
 		lg.Info("Config file changed:", e.Name)
-		readConfig()
+		if err := readConfig(); err != nil {
+			lg.Errorf("%s The previous configuration is kept.", err)
+		}
 	})
 	viper.WatchConfig()
-	readConfig()
+	if err := readConfig(); err != nil {
+		lg.Fatal(err)
+	}
 	lg.Infof("Build timestamp: %s", buildTime)
+	moldStatePath := filepath.Join(filepath.Dir(pathOfBinary), moldStateFile)
+	loadMoldIndex(moldStatePath)
 
 	adapter := bt.DefaultAdapter
-	err = adapter.Enable()
-	if err != nil {
-		lg.Error("Check: 1) rfkill unblock bluetooth, 2) sudo systemctl start bluetooth")
-		lg.Panicf("failed to enable BLE adapter: %v", err)
+	historyStatePath := filepath.Join(filepath.Dir(pathOfBinary), historyStateFile)
+	if *replayFile == "" {
+		supervisor = bluetooth.NewSupervisor(adapter, scannerConfig, onScan, onScanRecovery)
+		historySync = bluetooth.NewHistorySync(historyConfig, addBackfill)
+		loadHistoryState(historyStatePath)
+		bluetooth.EnableHistorySync(historySync)
+	}
+	var replayClock *bluetooth.ReplayClock
+	if *replayFile != "" {
+		// the zones run on the captured time, so that the replay reproduces the captured behavior
+		replayClock = bluetooth.NewReplayClock(*replaySpeed)
+		clock = replayClock.Now
+	}
+	if *captureFile != "" {
+		file, err := os.OpenFile(*captureFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			lg.Fatalf("Couldn't open capture file %s: %s", *captureFile, err)
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		recorder = bluetooth.NewRecorder(file)
+		lg.Infof("Recording advertisements to %s", *captureFile)
 	}
 
 	disp, err = display.New(false, lcdScrollSpeed, lcdDelay)
@@ -77,9 +133,11 @@ func main() {
 		ipAddress = utility.LogNetworkInterfacesAndGetIpAdr()
 		display.StartScreen(disp, buildTime, ipAddress)
 	}
-	ioPins, err = gpio.New()
-	if err != nil {
-		lg.Errorf("Couldn't initialize GPIO: %s", err)
+	for _, z := range zones {
+		z.pins, err = gpio.New(z.gpioConfig)
+		if err != nil {
+			lg.Errorf("Couldn't initialize GPIO of zone '%s': %s", z.title(), err)
+		}
 	}
 
 	var ctrlChan = make(chan os.Signal, 1)
@@ -87,33 +145,96 @@ func main() {
 	// this goroutine is waiting for being stopped
 	go func() {
 		<-ctrlChan
+		saveMoldIndex(moldStatePath)
+		if historySync != nil {
+			saveHistoryState(historyStatePath)
+		}
 		disp.Backlight(false)
 		lg.Info("Ctrl+C received... Exiting")
 		os.Exit(1)
 	}()
 
 	go showScreens()
+	go persistMoldIndex(moldStatePath)
+	if historySync != nil {
+		go persistHistoryState(historyStatePath)
+	}
 	go startWebserver()
 	if influxConfig.Enabled {
 		go sendToInfluxDb()
 	}
 
-	err = adapter.Scan(onScan)
-	if err != nil {
-		lg.Panicf("failed to register scan callback - %s", err)
+	if *replayFile != "" {
+		replay(*replayFile, *replaySpeed, replayClock)
+		return
 	}
+	// the scan runs until the app is stopped, the web server and the display keep running while Bluetooth is down
+	supervisor.Run(nil)
 }
 
+// onScanRecovery records an event when the Bluetooth scan has failed or stalled and is restarted.
+func onScanRecovery(reason error, recoveries int) {
+	if recoveries == 1 {
+		lg.Error("Check: 1) rfkill unblock bluetooth, 2) sudo systemctl start bluetooth")
+	}
+	events.add(event{Time: clock(), Type: "bluetooth",
+		Message: fmt.Sprintf("restarting the scan (recovery %d): %s", recoveries, reason)})
+}
+
+// onScan passes every advertisement to the decoders, which ignore devices of unknown sensor families. If a
+// capture file is given, the advertisement is recorded first.
 func onScan(_ *bt.Adapter, scanResult bt.ScanResult) {
-	if scanResult.LocalName() == "ThermoBeacon" {
-		bluetooth.ProcessAdvertisement(scanResult, &sensors, &sensorStore)
+	if recorder != nil {
+		if err := recorder.RecordScanResult(scanResult); err != nil {
+			lg.Errorf("Couldn't record advertisement: %s", err)
+		}
 	}
+	bluetooth.ProcessAdvertisement(scanResult, scanZones)
 }
 
-func computeResults(inside sensor.SensorData, outside sensor.SensorData, resultData *sensor.ResultData) {
-	if remoteOverride > 0 {
+// replay feeds the recorded advertisements of the file to the zones instead of the Bluetooth scanner and sets
+// the clock to their captured time. The app keeps running after the end of the file until it is stopped.
+func replay(path string, speed float64, replayClock *bluetooth.ReplayClock) {
+	file, err := os.Open(path)
+	if err != nil {
+		lg.Fatalf("Couldn't open replay file %s: %s", path, err)
+	}
+	lg.Infof("Replaying advertisements from %s with speed %.1f", path, speed)
+	err = bluetooth.Replay(file, speed, scanZones, replayClock)
+	_ = file.Close()
+	if err != nil {
+		lg.Fatalf("Couldn't replay %s: %s", path, err)
+	}
+	lg.Info("Replay finished")
+	select {}
+}
+
+// computeResults determines whether the fan of the zone should be on. A remote override takes precedence until
+// it expires, then missing or outdated sensor data and forbidden schedule windows switch the fan off. A stale
+// sensor is replaced by its fallback if one is configured and available. Then the first matching rule of the
+// configuration decides. Otherwise, the fan controller of the zone decides, using the minimal difference of a
+// preferred schedule window if one is active or the lower minimal difference if the mold index has reached its
+// limit. The checks are recorded in trace, which may be nil.
+func (z *zone) computeResults(trace *control.Decision) {
+	resultData := &z.result
+	now := clock()
+	resultData.OverrideRemaining = 0
+	resultData.RuleReason = ""
+	resultData.Speed = 0
+	resultData.InsideFallback = sensor.FallbackNone
+	resultData.OutsideFallback = sensor.FallbackNone
+	if z.remoteOverride > 0 && !z.overrideUntil.IsZero() {
+		if now.Before(z.overrideUntil) {
+			resultData.OverrideRemaining = z.overrideUntil.Sub(now)
+		} else {
+			lg.Infof("Remote override of zone '%s' expired, switching back to automatic mode", z.title())
+			z.remoteOverride = 0
+			z.overrideUntil = time.Time{}
+		}
+	}
+	if !trace.Check("remote override", float64(z.remoteOverride), control.Equal, 0) {
 		// manual override via REST api
-		if remoteOverride == 1 {
+		if z.remoteOverride == 1 {
 			resultData.ShouldBeOn = true
 			resultData.Reason = sensor.ReasonSoftOverrideOn
 		} else {
@@ -122,48 +243,48 @@ func computeResults(inside sensor.SensorData, outside sensor.SensorData, resultD
 		}
 		return
 	}
-	if inside.Scanned.IsZero() || outside.Scanned.IsZero() {
-		resultData.ShouldBeOn = false
-		resultData.Reason = sensor.ReasonNoData
-		return
-	}
-	last5Minute := time.Now().Add(-5 * time.Minute)
-	if inside.Scanned.Before(last5Minute) || outside.Scanned.Before(last5Minute) {
+	inside, insideFallback, insideOk := selectReading("inside", z.Sensors.InsideData, z.Sensors.InsideBackup,
+		z.Sensors.InsideExternal, z.Sensors.InsideFallback, now, trace)
+	outside, outsideFallback, outsideOk := selectReading("outside", z.Sensors.OutsideData, z.Sensors.OutsideBackup,
+		z.Sensors.OutsideExternal, z.Sensors.OutsideFallback, now, trace)
+	resultData.InsideFallback = insideFallback
+	resultData.OutsideFallback = outsideFallback
+	if !insideOk || !outsideOk {
 		resultData.ShouldBeOn = false
-		resultData.Reason = sensor.ReasonNoEnoughData
+		if (!insideOk && inside.Scanned.IsZero()) || (!outsideOk && outside.Scanned.IsZero()) {
+			resultData.Reason = sensor.ReasonNoData
+		} else {
+			resultData.Reason = sensor.ReasonNoEnoughData
+		}
 		return
 	}
-	if inside.Temperature < fanConfig.MinTempInside {
+	mode, cfg := fanSchedule.Evaluate(now, z.fanConfig)
+	if !trace.CheckTrue("schedule "+string(mode), mode != control.ScheduleForbidden) {
 		resultData.ShouldBeOn = false
-		resultData.Reason = sensor.ReasonInsideTempTooLow
+		resultData.Reason = sensor.ReasonBlockedBySchedule
 		return
 	}
-	if outside.Temperature < fanConfig.MinTempOutside {
-		resultData.ShouldBeOn = false
-		resultData.Reason = sensor.ReasonOutsideTempTooLow
-		return
+	input := control.Input{
+		Inside:         inside,
+		Outside:        outside,
+		InsideHistory:  &z.Store.Inside,
+		OutsideHistory: &z.Store.Outside,
+		Config:         cfg,
+		Now:            now,
+		Trace:          trace,
 	}
-	if inside.Humidity < fanConfig.MinHumidityInside {
-		resultData.ShouldBeOn = false
-		resultData.Reason = sensor.ReasonInsideHumidityTooLow
-		return
+	primaryOnly := insideFallback == sensor.FallbackNone && outsideFallback == sensor.FallbackNone
+	if !trace.CheckTrue("trend history", primaryOnly) {
+		// the histories hold the readings of the failed primary sensors, so the trend is unknown and taken as 0
+		input.InsideHistory = nil
+		input.OutsideHistory = nil
 	}
-	deltaDp := inside.DewPoint - outside.DewPoint
-	if deltaDp < fanConfig.MinDiff {
-		resultData.ShouldBeOn = false
-		resultData.Reason = sensor.ReasonDewPointUnderHyst
+	if z.rules.Evaluate(input, resultData) {
 		return
 	}
-	if deltaDp >= fanConfig.MinDiff+fanConfig.Hysteresis {
-		resultData.ShouldBeOn = true
-		resultData.Reason = sensor.ReasonDewPointOverHyst
-		return
-	}
-	if deltaDp >= fanConfig.MinDiff && deltaDp < fanConfig.MinDiff+fanConfig.Hysteresis {
-		// we don't change the fan state since we don't know if the dew point is rising or falling
-		resultData.Reason = sensor.ReasonDewPointInBetween
-		return
+	if cfg.MoldIndexLimit > 0 {
+		trace.Check("mold index", z.mold.Index, control.Less, cfg.MoldIndexLimit)
 	}
-	resultData.ShouldBeOn = false
-	resultData.Reason = sensor.ReasonUnknown
+	input.Config = z.mold.Adjust(cfg)
+	z.controller.Compute(input, resultData)
 }
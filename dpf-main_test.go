@@ -1,12 +1,23 @@
 package main
 
 import (
+	"dpf-bt/control"
 	"dpf-bt/sensor"
 	"reflect"
 	"testing"
 	"time"
 )
 
+// testZone creates an unnamed zone with the given sensor readings, fan configuration and previous result.
+func testZone(inside, outside sensor.SensorData, fanConfig sensor.FanConfig, lastResult sensor.ResultData) *zone {
+	z := newZone("")
+	z.Sensors.InsideData = inside
+	z.Sensors.OutsideData = outside
+	z.fanConfig = fanConfig
+	z.result = lastResult
+	return z
+}
+
 func TestComputeResults(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -251,15 +262,221 @@ func TestComputeResults(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Set global variables used in computeResults
-			remoteOverride = tt.remoteOverride
-			fanConfig = tt.fanConfig
+			z := testZone(tt.inside, tt.outside, tt.fanConfig, tt.lastResult)
+			z.remoteOverride = tt.remoteOverride
+
+			z.computeResults(nil)
+
+			if !reflect.DeepEqual(z.result, tt.expectedResult) {
+				t.Errorf("expected ResultData = %+v, got %+v", tt.expectedResult, z.result)
+			}
+		})
+	}
+}
+
+func TestComputeResultsSchedule(t *testing.T) {
+	schedule, err := control.NewSchedule("UTC", []control.ScheduleWindowConfig{
+		{From: "22:00", To: "06:00", Mode: "forbidden"},
+		{From: "06:00", To: "09:00", Mode: "preferred", MinDiff: 2.0},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		name           string
+		now            time.Time
+		expectedResult sensor.ResultData
+	}{
+		{
+			name: "BlockedBySchedule",
+			now:  time.Date(2025, 7, 7, 3, 0, 0, 0, time.UTC),
+			expectedResult: sensor.ResultData{
+				ShouldBeOn: false,
+				Reason:     sensor.ReasonBlockedBySchedule,
+			},
+		},
+		{
+			name: "PreferredWindowLowersMinDiff",
+			now:  time.Date(2025, 7, 7, 7, 0, 0, 0, time.UTC),
+			expectedResult: sensor.ResultData{
+				ShouldBeOn: true,
+				Reason:     sensor.ReasonDewPointOverHyst,
+			},
+		},
+		{
+			name: "NoWindowUsesMinDiff",
+			now:  time.Date(2025, 7, 7, 12, 0, 0, 0, time.UTC),
+			expectedResult: sensor.ResultData{
+				ShouldBeOn: false,
+				Reason:     sensor.ReasonDewPointUnderHyst,
+			},
+		},
+	}
+
+	defer func() {
+		clock = time.Now
+		fanSchedule = nil
+	}()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock = func() time.Time { return tt.now }
+			fanSchedule = schedule
+			z := testZone(sensor.SensorData{DewPoint: 13.0, Scanned: tt.now},
+				sensor.SensorData{DewPoint: 10.0, Scanned: tt.now},
+				sensor.FanConfig{MinDiff: 4.0, Hysteresis: 1.0},
+				sensor.ResultData{ShouldBeOn: true})
 
-			computeResults(tt.inside, tt.outside, &tt.lastResult)
+			z.computeResults(nil)
 
-			if !reflect.DeepEqual(tt.lastResult, tt.expectedResult) {
-				t.Errorf("expected ResultData = %+v, got %+v", tt.expectedResult, tt.lastResult)
+			if !reflect.DeepEqual(z.result, tt.expectedResult) {
+				t.Errorf("expected ResultData = %+v, got %+v", tt.expectedResult, z.result)
 			}
 		})
 	}
 }
+
+func TestComputeResultsOverrideExpiry(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	defer func() {
+		clock = time.Now
+	}()
+	clock = func() time.Time { return now }
+	z := testZone(sensor.SensorData{DewPoint: 10.0, Scanned: now},
+		sensor.SensorData{DewPoint: 9.0, Scanned: now},
+		sensor.FanConfig{MinDiff: 4.0, Hysteresis: 1.0},
+		sensor.ResultData{})
+
+	z.remoteOverride = 1
+	z.overrideUntil = now.Add(90 * time.Second)
+	z.computeResults(nil)
+	expected := sensor.ResultData{
+		ShouldBeOn:        true,
+		Reason:            sensor.ReasonSoftOverrideOn,
+		OverrideRemaining: 90 * time.Second,
+	}
+	if !reflect.DeepEqual(z.result, expected) {
+		t.Errorf("expected ResultData = %+v, got %+v", expected, z.result)
+	}
+
+	clock = func() time.Time { return now.Add(2 * time.Minute) }
+	z.Sensors.InsideData.Scanned = clock()
+	z.Sensors.OutsideData.Scanned = clock()
+	z.computeResults(nil)
+	expected = sensor.ResultData{
+		ShouldBeOn: false,
+		Reason:     sensor.ReasonDewPointUnderHyst,
+	}
+	if !reflect.DeepEqual(z.result, expected) {
+		t.Errorf("expected ResultData = %+v, got %+v", expected, z.result)
+	}
+	if z.remoteOverride != 0 || !z.overrideUntil.IsZero() {
+		t.Errorf("expected override to be reset, got %d until %v", z.remoteOverride, z.overrideUntil)
+	}
+}
+
+func TestComputeResultsSpeedReset(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	defer func() {
+		clock = time.Now
+	}()
+	clock = func() time.Time { return now }
+	z := testZone(sensor.SensorData{Temperature: 20, Humidity: 70, DewPoint: 14.0, Scanned: now},
+		sensor.SensorData{Temperature: 15, Humidity: 60, DewPoint: 8.0, Scanned: now},
+		sensor.FanConfig{MinDiff: 4.0, Hysteresis: 1.0, MinHumidityInside: 50, MinTempInside: 10, MinTempOutside: -10,
+			MinSpeed: 30, FullSpeedDiff: 3.0},
+		sensor.ResultData{})
+	z.controller = &control.ProportionalController{}
+	z.computeResults(nil)
+	if !z.result.ShouldBeOn || z.result.Speed <= 30 || z.result.Speed >= 100 {
+		t.Fatalf("expected a partial speed of the proportional controller, got %+v", z.result)
+	}
+
+	// a remote override runs the fan at full speed
+	z.remoteOverride = 1
+	z.computeResults(nil)
+	if z.result.Reason != sensor.ReasonSoftOverrideOn || z.result.Speed != 0 || z.result.FanSpeed() != 100 {
+		t.Errorf("expected full speed with the override, got %+v", z.result)
+	}
+
+	// so does another controller after a reload
+	z.remoteOverride = 0
+	z.computeResults(nil)
+	z.controller = &control.DewPointController{}
+	z.computeResults(nil)
+	if !z.result.ShouldBeOn || z.result.FanSpeed() != 100 {
+		t.Errorf("expected full speed with the dew point controller, got %+v", z.result)
+	}
+}
+
+func TestComputeResultsFallback(t *testing.T) {
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	defer func() {
+		clock = time.Now
+	}()
+	clock = func() time.Time { return now }
+	z := testZone(sensor.SensorData{DewPoint: 15.0, Scanned: now},
+		sensor.SensorData{DewPoint: 9.0, Scanned: now.Add(-2 * time.Hour)},
+		sensor.FanConfig{MinDiff: 4.0, Hysteresis: 1.0},
+		sensor.ResultData{})
+
+	z.computeResults(nil)
+	expected := sensor.ResultData{ShouldBeOn: false, Reason: sensor.ReasonNoEnoughData}
+	if !reflect.DeepEqual(z.result, expected) {
+		t.Errorf("expected ResultData = %+v, got %+v", expected, z.result)
+	}
+
+	z.Sensors.OutsideFallback.HoldTime = 3 * time.Hour
+	z.computeResults(nil)
+	expected = sensor.ResultData{
+		ShouldBeOn:      true,
+		Reason:          sensor.ReasonDewPointOverHyst,
+		OutsideFallback: sensor.FallbackLastValue,
+	}
+	if !reflect.DeepEqual(z.result, expected) {
+		t.Errorf("expected ResultData = %+v, got %+v", expected, z.result)
+	}
+
+	z.Sensors.OutsideBackup = sensor.SensorData{DewPoint: 14.0, Scanned: now}
+	z.computeResults(nil)
+	expected = sensor.ResultData{
+		ShouldBeOn:      false,
+		Reason:          sensor.ReasonDewPointUnderHyst,
+		OutsideFallback: sensor.FallbackBackup,
+	}
+	if !reflect.DeepEqual(z.result, expected) {
+		t.Errorf("expected ResultData = %+v, got %+v", expected, z.result)
+	}
+}
+
+func TestComputeResultsFallbackTrend(t *testing.T) {
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	defer func() {
+		clock = time.Now
+	}()
+	clock = func() time.Time { return now }
+	z := testZone(sensor.SensorData{Temperature: 20, Humidity: 80, DewPoint: 14.5, Scanned: now},
+		sensor.SensorData{Temperature: 12, Humidity: 80, DewPoint: 9.0, Scanned: now.Add(-6 * time.Minute)},
+		sensor.FanConfig{MinDiff: 4.0, Hysteresis: 1.0, MinTrend: 0.5, MinHumidityInside: 50, MinTempInside: 10,
+			MinTempOutside: -10},
+		sensor.ResultData{})
+	// the outside dew point was rising quickly before the sensor failed
+	for i := range 5 {
+		scanned := now.Add(time.Duration(i-10) * time.Minute)
+		z.Store.Inside.AddSensorData(sensor.SensorData{DewPoint: 14.5, Scanned: scanned})
+		z.Store.Outside.AddSensorData(sensor.SensorData{DewPoint: 5.0 + float64(i), Scanned: scanned})
+	}
+	z.Sensors.OutsideBackup = sensor.SensorData{Temperature: 12, Humidity: 80, DewPoint: 10.0, Scanned: now}
+
+	trace := control.NewDecision("", now)
+	z.computeResults(trace)
+	if z.result.Reason != sensor.ReasonDewPointInBetween || z.result.DpTrend != 0 {
+		t.Errorf("expected no trend from the stale history, got %+v", z.result)
+	}
+	found := false
+	for _, check := range trace.Checks {
+		found = found || check.Name == "trend history" && !check.Passed
+	}
+	if !found {
+		t.Errorf("expected the missing trend history in the trace, got %v", trace.Checks)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"dpf-bt/control"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+)
+
+const (
+	// moldStateFile is the name of the file beside the binary that keeps the mold index across restarts.
+	moldStateFile = "mold-index.json"
+	// moldSaveInterval is the interval for saving the mold index.
+	moldSaveInterval = 10 * time.Minute
+)
+
+// loadMoldIndex restores the mold index of all zones from the given file. The zones are matched by name.
+// A missing file is not an error, since it doesn't exist before the first save.
+func loadMoldIndex(path string) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		lg.Errorf("Couldn't read mold index: %s", err)
+		return
+	}
+	var state map[string]control.MoldIndex
+	if err = json.Unmarshal(content, &state); err != nil {
+		lg.Errorf("Couldn't parse mold index: %s", err)
+		return
+	}
+	for _, z := range zones {
+		if mold, ok := state[z.Name]; ok {
+			z.mold = mold
+			lg.Infof("Mold index of zone '%s': %.2f (%s)", z.title(), z.mold.Index, z.mold.Level())
+		}
+	}
+}
+
+// saveMoldIndex writes the mold index of all zones to the given file.
+func saveMoldIndex(path string) {
+	state := make(map[string]control.MoldIndex, len(zones))
+	for _, z := range zones {
+		state[z.Name] = z.mold
+	}
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		lg.Errorf("Couldn't encode mold index: %s", err)
+		return
+	}
+	if err = writeFileAtomic(path, content); err != nil {
+		lg.Errorf("Couldn't write mold index: %s", err)
+	}
+}
+
+// writeFileAtomic writes the content to a temporary file first and renames it, so that a power loss doesn't leave
+// a broken file behind.
+func writeFileAtomic(path string, content []byte) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// persistMoldIndex saves the mold index of all zones periodically.
+func persistMoldIndex(path string) {
+	ticker := time.NewTicker(moldSaveInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		saveMoldIndex(path)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"dpf-bt/bluetooth"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	bt "tinygo.org/x/bluetooth"
+)
+
+// runScan implements the "scan" command. It scans for the given time, lists the supported sensors that were
+// found and optionally writes the chosen inside and outside sensors into the configuration file.
+func runScan(args []string) {
+	flags := flag.NewFlagSet("scan", flag.ExitOnError)
+	duration := flags.Int("duration", 20, "scan duration in seconds")
+	inside := flags.String("inside", "", "number from the list or MAC address of the inside sensor to configure")
+	outside := flags.String("outside", "", "number from the list or MAC address of the outside sensor to configure")
+	zoneName := flags.String("zone", "", "name of the zone to configure if the configuration has several zones")
+	configFile := flags.String("config", "", "configuration file to update (default config.json next to the app)")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(flags.Output(),
+			"Usage: %s scan [-duration <seconds>] [-inside <n|MAC>] [-outside <n|MAC>] [-zone <name>] "+
+				"[-config <file>]\n", filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if *duration < 1 || flags.NArg() > 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	adapter := bt.DefaultAdapter
+	if err := adapter.Enable(); err != nil {
+		lg.Error("Check: 1) rfkill unblock bluetooth, 2) sudo systemctl start bluetooth")
+		lg.Fatalf("failed to enable BLE adapter: %v", err)
+	}
+	lg.Infof("Scanning for %d seconds...", *duration)
+	discovery := bluetooth.NewDiscovery()
+	time.AfterFunc(time.Duration(*duration)*time.Second, func() {
+		_ = adapter.StopScan()
+	})
+	err := adapter.Scan(func(_ *bt.Adapter, scanResult bt.ScanResult) {
+		discovery.AddScanResult(scanResult)
+	})
+	if err != nil {
+		lg.Fatalf("failed to scan - %s", err)
+	}
+	sensors := discovery.Sensors()
+	printDiscoveredSensors(os.Stdout, sensors)
+
+	if *inside == "" && *outside == "" {
+		return
+	}
+	insideMac, err := resolveSensor(*inside, sensors)
+	if err != nil {
+		lg.Fatalf("Invalid inside sensor! %s", err)
+	}
+	outsideMac, err := resolveSensor(*outside, sensors)
+	if err != nil {
+		lg.Fatalf("Invalid outside sensor! %s", err)
+	}
+	path := *configFile
+	if path == "" {
+		path = defaultConfigPath()
+	}
+	if err = writeSensorMACs(path, *zoneName, insideMac, outsideMac); err != nil {
+		lg.Fatalf("Couldn't update %s: %s", path, err)
+	}
+	lg.Infof("Updated %s", path)
+}
+
+// defaultConfigPath returns the path of the config.json beside the binary.
+func defaultConfigPath() string {
+	pathOfBinary, err := os.Executable()
+	if err != nil {
+		lg.Fatalf("Couldn't get path of executable: %s", err)
+	}
+	return filepath.Join(filepath.Dir(pathOfBinary), "config.json")
+}
+
+// printDiscoveredSensors prints the sensors as a numbered table.
+func printDiscoveredSensors(w io.Writer, sensors []bluetooth.DiscoveredSensor) {
+	if len(sensors) == 0 {
+		_, _ = fmt.Fprintln(w, "No supported sensors found.")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "#\tMAC\tSensor\tRSSI\tTemp\tHum\tBattery\tSeen\t")
+	for i, s := range sensors {
+		battery := "-"
+		switch {
+		case s.Reading.BatteryPercent > 0:
+			battery = fmt.Sprintf("%d%%", s.Reading.BatteryPercent)
+		case s.Reading.BatteryMV > 0:
+			battery = fmt.Sprintf("%dmV", s.Reading.BatteryMV)
+		}
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%.1f°C\t%.1f%%\t%s\t%dx\t\n", i+1, s.MacAddress, s.Decoder, s.RSSI,
+			s.Reading.Temperature, s.Reading.Humidity, battery, s.Count)
+	}
+	_ = tw.Flush()
+}
+
+// resolveSensor returns the MAC address of the chosen sensor, which is either its number in the list of the
+// discovered sensors or a MAC address. An empty choice returns an empty MAC address.
+func resolveSensor(choice string, sensors []bluetooth.DiscoveredSensor) (string, error) {
+	if choice == "" {
+		return "", nil
+	}
+	if n, err := strconv.Atoi(choice); err == nil {
+		if n < 1 || n > len(sensors) {
+			return "", fmt.Errorf("no sensor with number %d", n)
+		}
+		return sensors[n-1].MacAddress, nil
+	}
+	mac, err := net.ParseMAC(choice)
+	if err != nil || len(mac) != 6 {
+		return "", fmt.Errorf("'%s' is neither a number from the list nor a MAC address", choice)
+	}
+	return strings.ToUpper(mac.String()), nil
+}
+
+// writeSensorMACs sets the MAC addresses of the "inside" and "outside" sections in the configuration file. An
+// empty MAC address leaves the section unchanged. If the configuration has zones, the zone with the given name
+// is updated; the name can be omitted if there is only one zone. A missing file is created.
+func writeSensorMACs(path, zoneName, insideMac, outsideMac string) error {
+	return updateConfigFile(path, func(config map[string]any) error {
+		target := config
+		if zoneList, ok := config["zones"].([]any); ok && len(zoneList) > 0 {
+			target = nil
+			for i, entry := range zoneList {
+				zoneMap, ok := entry.(map[string]any)
+				if !ok {
+					return fmt.Errorf("invalid zone %d", i+1)
+				}
+				name, _ := zoneMap["name"].(string)
+				if name == "" {
+					name = fmt.Sprintf("Zone%d", i+1)
+				}
+				if name == zoneName || zoneName == "" && len(zoneList) == 1 {
+					target = zoneMap
+					break
+				}
+			}
+			if target == nil {
+				return fmt.Errorf("zone '%s' not found, use -zone to choose one of the zones", zoneName)
+			}
+		} else if zoneName != "" {
+			return fmt.Errorf("zone '%s' not found, the configuration has no zones", zoneName)
+		}
+
+		for section, mac := range map[string]string{"inside": insideMac, "outside": outsideMac} {
+			if mac == "" {
+				continue
+			}
+			sectionMap, ok := target[section].(map[string]any)
+			if !ok {
+				sectionMap = make(map[string]any)
+				target[section] = sectionMap
+			}
+			sectionMap["mac"] = mac
+		}
+		return nil
+	})
+}
+
+// updateConfigFile reads the JSON configuration file, changes it with update and writes it back with sorted keys,
+// which drops the original key order and formatting. A missing file is treated as an empty configuration. The
+// file is replaced atomically, so that a running service watching it never reads a half-written file.
+func updateConfigFile(path string, update func(config map[string]any) error) error {
+	config := make(map[string]any)
+	content, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err = json.Unmarshal(content, &config); err != nil {
+			return err
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+	if err = update(config); err != nil {
+		return err
+	}
+	content, err = json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(content, '\n'))
+}
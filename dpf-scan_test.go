@@ -0,0 +1,127 @@
+package main
+
+import (
+	"dpf-bt/bluetooth"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResolveSensor(t *testing.T) {
+	sensors := []bluetooth.DiscoveredSensor{{MacAddress: "C4:7C:8D:6A:12:34"}, {MacAddress: "A4:C1:38:8F:1A:2B"}}
+	tests := []struct {
+		name     string
+		choice   string
+		expected string
+		wantErr  bool
+	}{
+		{name: "Empty", choice: "", expected: ""},
+		{name: "Number", choice: "2", expected: "A4:C1:38:8F:1A:2B"},
+		{name: "NumberOutOfRange", choice: "3", wantErr: true},
+		{name: "Mac", choice: "9d:8b:00:00:18:bd", expected: "9D:8B:00:00:18:BD"},
+		{name: "Invalid", choice: "cellar", wantErr: true},
+		{name: "LongAddress", choice: "00:00:00:00:fe:80:00:00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveSensor(tt.choice, sensors)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected '%s', got '%s'", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestWriteSensorMACs(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		zone       string
+		inside     string
+		outside    string
+		expected   string
+		wantErr    bool
+		skipCreate bool
+	}{
+		{
+			name:    "SingleZone",
+			content: `{"inside": {"mac": "old", "temperature-calibration": -1.5}, "lcd": {"delay": 1}}`,
+			inside:  "A4:C1:38:8F:1A:2B",
+			outside: "C4:7C:8D:6A:12:34",
+			expected: `{"inside": {"mac": "A4:C1:38:8F:1A:2B", "temperature-calibration": -1.5}, "lcd": {"delay": 1},
+				"outside": {"mac": "C4:7C:8D:6A:12:34"}}`,
+		},
+		{
+			name:       "MissingFile",
+			skipCreate: true,
+			outside:    "C4:7C:8D:6A:12:34",
+			expected:   `{"outside": {"mac": "C4:7C:8D:6A:12:34"}}`,
+		},
+		{
+			name:    "NamedZone",
+			content: `{"zones": [{"name": "cellar", "inside": {"mac": "old"}}, {"name": "garage"}]}`,
+			zone:    "garage",
+			inside:  "A4:C1:38:8F:1A:2B",
+			expected: `{"zones": [{"name": "cellar", "inside": {"mac": "old"}},
+				{"name": "garage", "inside": {"mac": "A4:C1:38:8F:1A:2B"}}]}`,
+		},
+		{
+			name:     "DefaultZoneName",
+			content:  `{"zones": [{"inside": {"mac": "old"}}, {}]}`,
+			zone:     "Zone2",
+			outside:  "C4:7C:8D:6A:12:34",
+			expected: `{"zones": [{"inside": {"mac": "old"}}, {"outside": {"mac": "C4:7C:8D:6A:12:34"}}]}`,
+		},
+		{
+			name:    "ZoneRequired",
+			content: `{"zones": [{"name": "cellar"}, {"name": "garage"}]}`,
+			inside:  "A4:C1:38:8F:1A:2B",
+			wantErr: true,
+		},
+		{
+			name:    "NoZones",
+			content: `{"inside": {"mac": "old"}}`,
+			zone:    "cellar",
+			inside:  "A4:C1:38:8F:1A:2B",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if !tt.skipCreate {
+				if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := writeSensorMACs(path, tt.zone, tt.inside, tt.outside)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr {
+				return
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got, expected any
+			if err = json.Unmarshal(content, &got); err != nil {
+				t.Fatalf("invalid JSON written: %s", err)
+			}
+			if err = json.Unmarshal([]byte(tt.expected), &expected); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("expected %v, got %v", expected, got)
+			}
+		})
+	}
+}
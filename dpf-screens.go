@@ -1,12 +1,19 @@
 package main
 
 import (
+	"dpf-bt/control"
 	"dpf-bt/display"
 	"time"
 )
 
+// screensPerZone is the number of screens that are shown for each zone during one rotation. The last two are
+// the fault screen, which is only shown during a fan fault, and the battery screen, which is only shown while a
+// sensor has a low battery.
+const screensPerZone = 11
+
 // showScreens manages the periodic display of different screens on an LCD, using sensor data and fan status.
-// It cycles through multiple screen types, including main, results, and info, based on a timed sequence.
+// It cycles through multiple screen types, including main, results, info, mold risk, fan faults and low batteries,
+// for every zone based on a timed sequence and shows the start screen at the end of each rotation.
 func showScreens() {
 	func() {
 		// Create a ticker to trigger events every 'lcdScreenChange' seconds
@@ -15,26 +22,62 @@ func showScreens() {
 		step := 0
 		// Loop to handle toggling and communication through channels
 		for {
-			computeResults(sensors.InsideData, sensors.OutsideData, &resultData)
-			ioPins.SetFan(resultData.ShouldBeOn)
-			resultData.IsOn = ioPins.ReadFanSense()
+			for _, z := range zones {
+				z.updateFan()
+			}
 			select {
 			case <-ticker.C:
-				switch step {
-				case 0, 3, 6:
-					display.MainScreen(disp, sensors.InsideData, sensors.OutsideData)
-				case 1, 4, 7:
-					display.ResultScreen(disp, resultData, sensors.InsideData, sensors.OutsideData, fanConfig)
-				case 2, 5:
-					display.InfoScreen(disp, sensors.InsideData, sensors.OutsideData)
-				case 8:
-					display.StartScreen(disp, buildTime, ipAddress)
-				}
-				step += 1
-				if step > 8 {
-					step = 0
+				for !showScreen(step) {
+					step += 1
 				}
+				step = (step + 1) % (len(zones)*screensPerZone + 1)
 			}
 		}
 	}()
 }
+
+// showScreen shows the screen of the given step of the rotation. The step after the screens of all zones shows
+// the start screen. It returns false if the screen is skipped.
+func showScreen(step int) bool {
+	if step >= len(zones)*screensPerZone {
+		display.StartScreen(disp, buildTime, ipAddress)
+		return true
+	}
+	z := zones[step/screensPerZone]
+	switch step % screensPerZone {
+	case 0, 3, 6:
+		// the middle main screen shows the absolute humidity and the mixing ratio
+		display.MainScreen(disp, z.title(), z.Sensors.InsideData, z.Sensors.OutsideData, step%screensPerZone/3%2)
+	case 1, 4, 7:
+		label, unit, diff, diffMin := z.resultDiff()
+		display.ResultScreen(disp, z.Name, z.result, z.Sensors.InsideData, z.Sensors.OutsideData, label, unit, diff,
+			diffMin, clock())
+	case 2, 5:
+		display.InfoScreen(disp, z.title(), z.Sensors.InsideData, z.Sensors.OutsideData)
+	case 8:
+		display.MoldScreen(disp, z.title(), z.mold.Index, z.mold.Level(),
+			control.CriticalHumidity(z.Sensors.InsideData.Temperature), z.Sensors.InsideData)
+	case 9:
+		if !z.result.Fault.IsFault() {
+			return false
+		}
+		display.FaultScreen(disp, z.title(), z.result, z.faults.FaultDuration(clock()))
+	case 10:
+		devices := z.lowBatteryDevices()
+		if len(devices) == 0 {
+			return false
+		}
+		display.BatteryScreen(disp, z.title(), devices)
+	}
+	return true
+}
+
+// resultDiff returns the label, the unit and the value of the difference the fan controller of the zone decides
+// on, and the difference needed to switch the fan on.
+func (z *zone) resultDiff() (string, string, float64, float64) {
+	if z.fanConfig.Controller == control.AbsHumidityName {
+		return "Ah diff", "g", z.result.AhDiff, z.fanConfig.MinAbsDiff + z.fanConfig.AbsHysteresis
+	}
+	return "Dp diff", "C", z.Sensors.InsideData.DewPoint - z.Sensors.OutsideData.DewPoint,
+		z.fanConfig.MinDiff + z.fanConfig.Hysteresis
+}
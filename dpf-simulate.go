@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/d2r2/go-logger"
+	"github.com/spf13/viper"
+)
+
+// maxSimulationStep is the longest time a simulated fan state is counted for. Longer gaps between two readings
+// would switch the real fan off due to outdated data, so they are not counted as on-time.
+const maxSimulationStep = 5 * time.Minute
+
+// reading is a single record of a recorded time series with the inside and outside temperature and humidity.
+// The JSON and CSV names match the field names of the InfluxDB measurement.
+type reading struct {
+	Time        time.Time `json:"time"`
+	TempInside  float64   `json:"temp_i"`
+	HumInside   float64   `json:"hum_i"`
+	TempOutside float64   `json:"temp_o"`
+	HumOutside  float64   `json:"hum_o"`
+}
+
+// simulationResult holds the statistics of a simulation run.
+type simulationResult struct {
+	Duration    time.Duration
+	OnTime      time.Duration
+	SwitchCount int
+	Reasons     map[string]time.Duration
+	// Moisture is the estimated amount of water in grams that was removed by the fan. Venting while the
+	// outside air is more humid than the inside air adds water, which is subtracted.
+	Moisture float64
+}
+
+// runSimulation implements the "simulate" command. It replays a recorded time series through the fan control
+// of the first zone of each given configuration file and prints the results side by side.
+func runSimulation(args []string) {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	input := flags.String("input", "", "CSV or JSONL file with the columns time, temp_i, hum_i, temp_o and hum_o")
+	airflow := flags.Float64("airflow", 100, "airflow of the fan in m³/h to estimate the removed moisture")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(flags.Output(), "Usage: %s simulate -input <file> [-airflow <m³/h>] <config.json>...\n",
+			filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if *input == "" || flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	readings, err := readReadingsFile(*input)
+	if err != nil {
+		lg.Fatalf("Couldn't read %s: %s", *input, err)
+	}
+	lg.Infof("Simulating %d readings from %s to %s", len(readings),
+		readings[0].Time.Format(time.DateTime), readings[len(readings)-1].Time.Format(time.DateTime))
+
+	// the switch messages of every simulated switch would flood the output
+	_ = logger.ChangePackageLogLevel("control", logger.WarnLevel)
+	defer func() {
+		clock = time.Now
+	}()
+	results := make([]simulationResult, 0, flags.NArg())
+	for _, configFile := range flags.Args() {
+		viper.Reset()
+		viper.SetConfigFile(configFile)
+		zones = nil
+		if err := readConfig(); err != nil {
+			lg.Fatalf("Invalid configuration %s: %s", configFile, err)
+		}
+		results = append(results, simulate(zones[0], readings, *airflow))
+	}
+	printSimulationResults(os.Stdout, flags.Args(), results)
+}
+
+// readReadingsFile reads the recorded time series from a JSONL file (extension .jsonl or .json) or a CSV file.
+func readReadingsFile(path string) ([]reading, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	var readings []reading
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".json":
+		readings, err = readJSONLReadings(file)
+	default:
+		readings, err = readCSVReadings(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(readings) == 0 {
+		return nil, errors.New("no readings found")
+	}
+	slices.SortFunc(readings, func(a, b reading) int {
+		return a.Time.Compare(b.Time)
+	})
+	return readings, nil
+}
+
+// readJSONLReadings reads one JSON object per line. Empty lines are skipped.
+func readJSONLReadings(r io.Reader) ([]reading, error) {
+	var readings []reading
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var rd reading
+		if err := json.Unmarshal(scanner.Bytes(), &rd); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		readings = append(readings, rd)
+	}
+	return readings, scanner.Err()
+}
+
+// readCSVReadings reads a CSV file with a header line. The columns are found by name, so additional columns
+// are ignored. Lines starting with '#' are skipped, so the annotated CSV of an InfluxDB query that pivots the
+// fields into columns (with the time in the column "_time") can be used directly.
+func readCSVReadings(r io.Reader) ([]reading, error) {
+	csvReader := csv.NewReader(r)
+	csvReader.Comment = '#'
+	csvReader.FieldsPerRecord = -1
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.TrimSpace(name)] = i
+	}
+	if _, ok := columns["time"]; !ok {
+		if i, ok := columns["_time"]; ok {
+			columns["time"] = i
+		}
+	}
+	for _, name := range []string{"time", "temp_i", "hum_i", "temp_o", "hum_o"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column '%s'", name)
+		}
+	}
+
+	var readings []reading
+	for i, record := range records[1:] {
+		// an annotated CSV contains a header line for every table
+		if record[columns["time"]] == "time" || record[columns["time"]] == "_time" {
+			continue
+		}
+		var rd reading
+		var values [4]float64
+		if rd.Time, err = time.Parse(time.RFC3339, record[columns["time"]]); err != nil {
+			return nil, fmt.Errorf("line %d: invalid time: %w", i+2, err)
+		}
+		for j, name := range []string{"temp_i", "hum_i", "temp_o", "hum_o"} {
+			if values[j], err = strconv.ParseFloat(record[columns[name]], 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s: %w", i+2, name, err)
+			}
+		}
+		rd.TempInside, rd.HumInside, rd.TempOutside, rd.HumOutside = values[0], values[1], values[2], values[3]
+		readings = append(readings, rd)
+	}
+	return readings, nil
+}
+
+// simulate runs the readings through the fan control of the zone with a virtual clock. The fan is assumed to
+// follow the computed state immediately. The airflow in m³/h is used to estimate the removed moisture.
+func simulate(z *zone, readings []reading, airflow float64) simulationResult {
+	result := simulationResult{Reasons: make(map[string]time.Duration)}
+	if len(readings) == 0 {
+		return result
+	}
+	now := readings[0].Time
+	clock = func() time.Time { return now }
+	for i, rd := range readings {
+		now = rd.Time
+		inside := newSensorData("Inside", rd.TempInside, rd.HumInside, now)
+		outside := newSensorData("Outside", rd.TempOutside, rd.HumOutside, now)
+		z.Sensors.InsideData = inside
+		z.Sensors.OutsideData = outside
+		z.Store.Inside.AddSensorData(inside)
+		z.Store.Outside.AddSensorData(outside)
+
+		// the zone has no GPIO pins, so the fan state isn't sensed
+		z.updateFan()
+		z.result.IsOn = z.result.ShouldBeOn
+
+		if i == len(readings)-1 {
+			break
+		}
+		step := min(readings[i+1].Time.Sub(now), maxSimulationStep)
+		result.Duration += step
+		result.Reasons[z.result.ReasonText()] += step
+		if z.result.IsOn {
+			result.OnTime += step
+			speed := float64(z.result.FanSpeed()) / 100
+			result.Moisture += airflow * speed * step.Hours() * (inside.AbsHumidity - outside.AbsHumidity)
+		}
+	}
+	result.SwitchCount = z.guard.SwitchCount()
+	return result
+}
+
+// printSimulationResults prints the results of all configurations as a table with one column per configuration.
+func printSimulationResults(w io.Writer, names []string, results []simulationResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	row := func(label string, value func(r simulationResult) string) {
+		_, _ = fmt.Fprintf(tw, "%s\t", label)
+		for _, r := range results {
+			_, _ = fmt.Fprintf(tw, "%s\t", value(r))
+		}
+		_, _ = fmt.Fprintln(tw)
+	}
+
+	_, _ = fmt.Fprint(tw, "\t")
+	for _, name := range names {
+		_, _ = fmt.Fprintf(tw, "%s\t", filepath.Base(name))
+	}
+	_, _ = fmt.Fprintln(tw)
+	row("Simulated time", func(r simulationResult) string {
+		return fmt.Sprintf("%.1fh", r.Duration.Hours())
+	})
+	row("Fan on-time", func(r simulationResult) string {
+		return fmt.Sprintf("%.1fh (%.1f%%)", r.OnTime.Hours(), percentOf(r.OnTime, r.Duration))
+	})
+	row("Switch count", func(r simulationResult) string {
+		return strconv.Itoa(r.SwitchCount)
+	})
+	row("Moisture removed", func(r simulationResult) string {
+		return fmt.Sprintf("%.0fg", r.Moisture)
+	})
+
+	var reasons []string
+	for _, r := range results {
+		for reason := range r.Reasons {
+			if !slices.Contains(reasons, reason) {
+				reasons = append(reasons, reason)
+			}
+		}
+	}
+	slices.Sort(reasons)
+	for _, reason := range reasons {
+		row(reason, func(r simulationResult) string {
+			return fmt.Sprintf("%.1f%%", percentOf(r.Reasons[reason], r.Duration))
+		})
+	}
+	_ = tw.Flush()
+}
+
+// percentOf returns the share of part in total in percent.
+func percentOf(part, total time.Duration) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"dpf-bt/sensor"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadCSVReadings(t *testing.T) {
+	expected := []reading{
+		{Time: time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC), TempInside: 18.5, HumInside: 75, TempOutside: 22,
+			HumOutside: 50},
+		{Time: time.Date(2025, 8, 1, 12, 1, 0, 0, time.UTC), TempInside: 18.6, HumInside: 74.5, TempOutside: 22.1,
+			HumOutside: 49.8},
+	}
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name: "Plain",
+			content: "time,temp_i,hum_i,temp_o,hum_o\n" +
+				"2025-08-01T12:00:00Z,18.5,75,22,50\n" +
+				"2025-08-01T12:01:00Z,18.6,74.5,22.1,49.8\n",
+		},
+		{
+			name: "InfluxExport",
+			content: "#group,false,false,true,true,false,false,false,false\n" +
+				"#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,double,double,double,double\n" +
+				",result,table,_start,_time,hum_i,hum_o,temp_i,temp_o\n" +
+				",_result,0,2025-08-01T00:00:00Z,2025-08-01T12:00:00Z,75,50,18.5,22\n" +
+				",_result,0,2025-08-01T00:00:00Z,2025-08-01T12:01:00Z,74.5,49.8,18.6,22.1\n",
+		},
+		{
+			name:    "MissingColumn",
+			content: "time,temp_i,hum_i,temp_o\n2025-08-01T12:00:00Z,18.5,75,22\n",
+			wantErr: true,
+		},
+		{
+			name:    "InvalidValue",
+			content: "time,temp_i,hum_i,temp_o,hum_o\n2025-08-01T12:00:00Z,18.5,n/a,22,50\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readCSVReadings(strings.NewReader(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error = %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, expected) {
+				t.Errorf("expected readings = %+v, got %+v", expected, got)
+			}
+		})
+	}
+}
+
+func TestReadJSONLReadings(t *testing.T) {
+	content := `{"time": "2025-08-01T12:00:00Z", "temp_i": 18.5, "hum_i": 75, "temp_o": 22, "hum_o": 50}
+
+{"time": "2025-08-01T12:01:00Z", "temp_i": 18.6, "hum_i": 74.5, "temp_o": 22.1, "hum_o": 49.8}
+`
+	got, err := readJSONLReadings(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[1].HumInside != 74.5 || !got[0].Time.Equal(time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected readings %+v", got)
+	}
+
+	if _, err = readJSONLReadings(strings.NewReader("{\"time\": 1}\n")); err == nil {
+		t.Errorf("expected error for invalid line")
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	start := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	var readings []reading
+	// one hour with a humid inside, then one hour with a humid outside, one reading per minute
+	for i := 0; i <= 120; i++ {
+		rd := reading{Time: start.Add(time.Duration(i) * time.Minute), TempInside: 18, HumInside: 80,
+			TempOutside: 18, HumOutside: 40}
+		if i >= 60 {
+			rd.HumInside, rd.HumOutside = 40, 80
+		}
+		readings = append(readings, rd)
+	}
+	defer func() {
+		clock = time.Now
+	}()
+	z := newZone("")
+	z.fanConfig = sensor.FanConfig{MinDiff: 3.0, Hysteresis: 1.0}
+
+	got := simulate(z, readings, 100)
+
+	if got.Duration != 2*time.Hour {
+		t.Errorf("expected duration of 2h, got %v", got.Duration)
+	}
+	if got.OnTime != time.Hour {
+		t.Errorf("expected on-time of 1h, got %v", got.OnTime)
+	}
+	if got.SwitchCount != 2 {
+		t.Errorf("expected 2 switches, got %d", got.SwitchCount)
+	}
+	if got.Reasons["dp > hysteresis"] != time.Hour || got.Reasons["dp < hysteresis"] != time.Hour {
+		t.Errorf("unexpected reasons %v", got.Reasons)
+	}
+	// 100 m³/h for one hour with a difference of about 6.2 g/m³
+	if got.Moisture < 600 || got.Moisture > 640 {
+		t.Errorf("expected about 620g of removed moisture, got %.1f", got.Moisture)
+	}
+}
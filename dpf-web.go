@@ -1,11 +1,17 @@
 package main
 
 import (
+	"dpf-bt/bluetooth"
+	"dpf-bt/control"
 	"dpf-bt/sensor"
+	"dpf-bt/utility"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/d2r2/go-logger"
+	"math"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 )
@@ -16,27 +22,125 @@ type sensorData struct {
 	Temperature float64 `json:"temperature"`
 	Humidity    float64 `json:"humidity"`
 	DewPoint    float64 `json:"dew_point"`
+	AbsHumidity float64 `json:"abs_humidity"`
+	MixingRatio float64 `json:"mixing_ratio"`
+	TempSlope   float64 `json:"temperature_slope"`
+	HumSlope    float64 `json:"humidity_slope"`
+	DpSlope     float64 `json:"dew_point_slope"`
 	BatLevel    float64 `json:"bat_level"`
+	BatPercent  int     `json:"bat_percent"`
 	RSSI        int16   `json:"rssi"`
 	Uptime      uint32  `json:"up_time_in_sec"`
+	// BatLow reports a low battery, BatDaysLeft the estimated days until the battery of a sensor is empty.
+	BatLow      bool    `json:"bat_low"`
+	BatDaysLeft float64 `json:"bat_days_left,omitempty"`
+	// RejectedOutliers and RejectedRate count the readings rejected by the outlier and the rate of change filter.
+	RejectedOutliers int          `json:"rejected_outliers"`
+	RejectedRate     int          `json:"rejected_rate"`
+	Role             string       `json:"role,omitempty"`
+	MacAddress       string       `json:"mac,omitempty"`
+	LastSeen         string       `json:"last_seen,omitempty"`
+	Stats            *sensorStats `json:"stats,omitempty"`
+}
+
+// sensorStats represents the statistics of the advertisements of a sensor. The intervals are in seconds, the
+// packet rate and loss refer to the last 10 minutes. The gap histogram counts the gaps between advertisements up
+// to the given number of seconds; the last bucket counts the longer gaps.
+type sensorStats struct {
+	Name             string      `json:"name,omitempty"`
+	MacAddress       string      `json:"mac,omitempty"`
+	Packets          int         `json:"packets"`
+	PacketsPerMinute float64     `json:"packets_per_minute"`
+	ExpectedInterval float64     `json:"expected_interval"`
+	ObservedInterval float64     `json:"observed_interval"`
+	PacketLoss       float64     `json:"packet_loss_percent"`
+	GapHistogram     []gapBucket `json:"gap_histogram"`
+	RSSIMin          int16       `json:"rssi_min"`
+	RSSIAvg          float64     `json:"rssi_avg"`
+	RSSIMax          int16       `json:"rssi_max"`
+	Reboots          int         `json:"reboots"`
+	LastReboot       string      `json:"last_reboot"`
+}
+
+// gapBucket represents a bucket of the gap histogram with the longest gap in seconds, 0 for the last bucket.
+type gapBucket struct {
+	MaxSeconds int `json:"max_seconds"`
+	Count      int `json:"count"`
 }
 
 // info represents the main structure for current system data, including sensor readings and fan control states.
+// The top level fields describe the first zone, so clients that only know a single zone keep working. Zones
+// holds the data of all zones.
 type info struct {
-	Update         string       `json:"update"`
-	Sensors        []sensorData `json:"sensors"`
-	Reason         int          `json:"reason"`
-	Venting        bool         `json:"venting"`
-	Override       bool         `json:"override"`
-	RemoteOverride int          `json:"remote_override"`
-	DiffMin        float64      `json:"diff_min"`
-	Hysteresis     float64      `json:"hysteresis"`
+	Update string `json:"update"`
+	zoneInfo
+	Zones     []zoneInfo     `json:"zones"`
+	Bluetooth *bluetoothInfo `json:"bluetooth,omitempty"`
+}
+
+// bluetoothInfo represents the state of the Bluetooth scanner and its recoveries.
+type bluetoothInfo struct {
+	Scanning          bool   `json:"scanning"`
+	LastAdvertisement string `json:"last_advertisement"`
+	Recoveries        int    `json:"recoveries"`
+	LastRecovery      string `json:"last_recovery"`
+	LastError         string `json:"last_error"`
+}
+
+// zoneInfo represents the sensor readings and fan control states of a single ventilation zone.
+type zoneInfo struct {
+	Name            string       `json:"name"`
+	Sensors         []sensorData `json:"sensors"`
+	Devices         []sensorData `json:"devices"`
+	Reason          int          `json:"reason"`
+	ReasonText      string       `json:"reason_text"`
+	DpTrend         float64      `json:"dp_trend"`
+	AhDiff          float64      `json:"ah_diff"`
+	Venting         bool         `json:"venting"`
+	Speed           int          `json:"speed"`
+	Override        bool         `json:"override"`
+	RemoteOverride  int          `json:"remote_override"`
+	DiffMin         float64      `json:"diff_min"`
+	Hysteresis      float64      `json:"hysteresis"`
+	Controller      string       `json:"controller"`
+	SwitchCount     int          `json:"switch_count"`
+	LastSwitch      string       `json:"last_switch"`
+	MinOnMinutes    int          `json:"min_on_minutes"`
+	MinOffMinutes   int          `json:"min_off_minutes"`
+	Schedule        string       `json:"schedule"`
+	OverrideUntil   string       `json:"override_until"`
+	OverrideLeft    int          `json:"override_remaining"`
+	MoldIndex       float64      `json:"mold_index"`
+	MoldLevel       string       `json:"mold_level"`
+	Degraded        bool         `json:"degraded"`
+	InsideFallback  string       `json:"inside_fallback"`
+	OutsideFallback string       `json:"outside_fallback"`
+	Switch          string       `json:"switch"`
+	Fault           int          `json:"fault"`
+	FaultText       string       `json:"fault_text"`
+	FaultSeconds    int          `json:"fault_seconds"`
+	LowBattery      bool         `json:"low_battery"`
+}
+
+// externalData represents values of an external source, e.g. a weather service, that replace a stale sensor.
+// Sensor is "inside" or "outside". Zone selects the zone by name, an empty Zone applies the values to all zones.
+type externalData struct {
+	Zone        string  `json:"zone,omitempty"`
+	Sensor      string  `json:"sensor"`
+	Temperature float64 `json:"temperature"`
+	Humidity    float64 `json:"humidity"`
 }
 
 // remoteControl represents the structure for managing remote override control for the fan system.
-// Override defines the override state, where the fan is forced to a specific state through external input.
+// Override defines the override state, where the fan is forced to a specific state through external input
+// (0 = automatic, 1 = on, 2 = off). The override can be limited either by a Duration in seconds or by an
+// Until timestamp in RFC 3339 format. Without a limit, the override stays active until it is reset.
+// Zone selects the zone by name, an empty Zone applies the override to all zones.
 type remoteControl struct {
-	Override int `json:"override"`
+	Zone     string `json:"zone,omitempty"`
+	Override int    `json:"override"`
+	Duration int    `json:"duration,omitempty"`
+	Until    string `json:"until,omitempty"`
 }
 
 const (
@@ -45,10 +149,9 @@ const (
 )
 
 type webServer struct {
-	sensorStore    *sensor.SensorStore
-	resultData     *sensor.ResultData
-	fanConfig      *sensor.FanConfig
-	remoteOverride *int
+	zones []*zone
+	// scanner supervises the Bluetooth scan, nil during a replay.
+	scanner *bluetooth.Supervisor
 }
 
 var lgWeb = logger.NewPackageLogger("web", logger.InfoLevel)
@@ -56,16 +159,18 @@ var lgWeb = logger.NewPackageLogger("web", logger.InfoLevel)
 // startWebserver initializes and starts a web server to display sensor data and control fan settings interactively.
 func startWebserver() {
 	srv := &webServer{
-		sensorStore:    &sensorStore,
-		resultData:     &resultData,
-		fanConfig:      &fanConfig,
-		remoteOverride: &remoteOverride,
+		zones:   zones,
+		scanner: supervisor,
 	}
 
 	go func() {
 		http.HandleFunc("/", srv.handleMainPage)
 		http.HandleFunc("/info", srv.handleInfo)
 		http.HandleFunc("/override", srv.handleOverride)
+		http.HandleFunc("/decisions", srv.handleDecisions)
+		http.HandleFunc("/external", srv.handleExternal)
+		http.HandleFunc("/events", srv.handleEvents)
+		http.HandleFunc("/sensors/{mac}/stats", srv.handleSensorStats)
 
 		lgWeb.Fatal(http.ListenAndServe(webServerHost+webServerPort, nil))
 	}()
@@ -74,21 +179,31 @@ func startWebserver() {
 func (s *webServer) handleMainPage(w http.ResponseWriter, _ *http.Request) {
 	var b strings.Builder
 
-	shouldBeOn := s.getFanStateText(s.resultData.ShouldBeOn)
-	isOn := s.getFanStateText(s.resultData.IsOn)
-	dewPointDiff := s.sensorStore.Inside.AverageDewPoint() - s.sensorStore.Outside.AverageDewPoint()
-
-	b.WriteString("Dew Point Fan\n-----------------------------------------------------\n")
-	_, _ = fmt.Fprintf(&b, "Inside   DP: %6.1f, Temp: %5.1f°C, Humidity: %5.1f%%\n",
-		s.sensorStore.Inside.AverageDewPoint(),
-		s.sensorStore.Inside.AverageTemperature(),
-		s.sensorStore.Inside.AverageHumidity())
-	_, _ = fmt.Fprintf(&b, "Outside  DP: %6.1f, Temp: %5.1f°C, Humidity: %5.1f%%\n",
-		s.sensorStore.Outside.AverageDewPoint(),
-		s.sensorStore.Outside.AverageTemperature(),
-		s.sensorStore.Outside.AverageHumidity())
-	_, _ = fmt.Fprintf(&b, "Diff     DP: %6.1f\n", dewPointDiff)
-	_, _ = fmt.Fprintf(&b, "Fan should be %s                         Fan is %s", shouldBeOn, isOn)
+	b.WriteString("Dew Point Fan\n")
+	for _, z := range s.zones {
+		shouldBeOn := s.getFanStateText(z.result.ShouldBeOn)
+		isOn := s.getFanStateText(z.result.IsOn)
+		dewPointDiff := z.Store.Inside.AverageDewPoint() - z.Store.Outside.AverageDewPoint()
+
+		if z.Name != "" {
+			_, _ = fmt.Fprintf(&b, "\n%s\n", z.Name)
+		}
+		b.WriteString("-----------------------------------------------------\n")
+		_, _ = fmt.Fprintf(&b, "Inside   DP: %6.1f, Temp: %5.1f°C, Humidity: %5.1f%%\n",
+			z.Store.Inside.AverageDewPoint(),
+			z.Store.Inside.AverageTemperature(),
+			z.Store.Inside.AverageHumidity())
+		_, _ = fmt.Fprintf(&b, "Outside  DP: %6.1f, Temp: %5.1f°C, Humidity: %5.1f%%\n",
+			z.Store.Outside.AverageDewPoint(),
+			z.Store.Outside.AverageTemperature(),
+			z.Store.Outside.AverageHumidity())
+		_, _ = fmt.Fprintf(&b, "Diff     DP: %6.1f\n", dewPointDiff)
+		_, _ = fmt.Fprintf(&b, "Mold index:  %4.2f (%s)\n", z.mold.Index, z.mold.Level())
+		_, _ = fmt.Fprintf(&b, "Fan should be %s                         Fan is %s\n", shouldBeOn, isOn)
+		if z.result.Fault != sensor.FaultNone {
+			_, _ = fmt.Fprintf(&b, "Fan state:   %s\n", sensor.FaultName[z.result.Fault])
+		}
+	}
 
 	_, _ = fmt.Fprint(w, b.String())
 }
@@ -100,14 +215,24 @@ func (s *webServer) handleInfo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	inf := &info{
-		Update:         time.Now().Format(time.DateTime),
-		Sensors:        s.getSensorData(),
-		Reason:         int(s.resultData.Reason),
-		Venting:        s.resultData.ShouldBeOn,
-		Override:       s.resultData.ShouldBeOn != s.resultData.IsOn,
-		RemoteOverride: *s.remoteOverride,
-		DiffMin:        s.fanConfig.MinDiff,
-		Hysteresis:     s.fanConfig.Hysteresis,
+		Update: clock().Format(time.DateTime),
+		Zones:  make([]zoneInfo, 0, len(s.zones)),
+	}
+	for _, z := range s.zones {
+		inf.Zones = append(inf.Zones, s.getZoneInfo(z))
+	}
+	if len(inf.Zones) > 0 {
+		inf.zoneInfo = inf.Zones[0]
+	}
+	if s.scanner != nil {
+		status := s.scanner.Status()
+		inf.Bluetooth = &bluetoothInfo{
+			Scanning:          status.Scanning,
+			LastAdvertisement: formatTime(status.LastAdvertisement),
+			Recoveries:        status.Recoveries,
+			LastRecovery:      formatTime(status.LastRecovery),
+			LastError:         status.LastError,
+		}
 	}
 
 	if err := s.writeJSON(w, inf); err != nil {
@@ -129,37 +254,310 @@ func (s *webServer) handleOverride(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	lgWeb.Infof("POST API called with override: %d", remote.Override)
-	*s.remoteOverride = remote.Override
+	until, err := remote.expiry(clock())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	targets := s.zones
+	if remote.Zone != "" {
+		z := findZone(remote.Zone)
+		if z == nil {
+			http.Error(w, fmt.Sprintf("unknown zone '%s'", remote.Zone), http.StatusBadRequest)
+			return
+		}
+		targets = []*zone{z}
+	}
+
+	lgWeb.Infof("POST API called with override: %d until: %s zone: %s", remote.Override, formatTime(until),
+		remote.Zone)
+	for _, z := range targets {
+		z.remoteOverride = remote.Override
+		z.overrideUntil = until
+	}
+	if !until.IsZero() {
+		remote.Until = until.Format(time.RFC3339)
+	}
 
 	if err := s.writeJSON(w, remote); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
-func (s *webServer) getSensorData() []sensorData {
-	return []sensorData{
-		{
-			Name:        "Inside",
-			Temperature: s.sensorStore.Inside.AverageTemperature(),
-			Humidity:    s.sensorStore.Inside.AverageHumidity(),
-			DewPoint:    s.sensorStore.Inside.AverageDewPoint(),
-			BatLevel:    float64(sensors.InsideData.BatLevel) / 1000,
-			RSSI:        sensors.InsideData.RSSI,
-			Uptime:      sensors.InsideData.Uptime,
-		},
-		{
-			Name:        "Outside",
-			Temperature: s.sensorStore.Outside.AverageTemperature(),
-			Humidity:    s.sensorStore.Outside.AverageHumidity(),
-			DewPoint:    s.sensorStore.Outside.AverageDewPoint(),
-			BatLevel:    float64(sensors.OutsideData.BatLevel) / 1000,
-			RSSI:        sensors.OutsideData.RSSI,
-			Uptime:      sensors.OutsideData.Uptime,
-		},
+// handleDecisions returns the last decisions with all evaluated checks, the oldest first. The optional query
+// parameter "zone" selects a zone by name, otherwise the decisions of all zones are returned.
+func (s *webServer) handleDecisions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	targets := s.zones
+	if name := r.URL.Query().Get("zone"); name != "" {
+		z := findZone(name)
+		if z == nil {
+			http.Error(w, fmt.Sprintf("unknown zone '%s'", name), http.StatusBadRequest)
+			return
+		}
+		targets = []*zone{z}
+	}
+	decisions := make([]control.Decision, 0, len(targets)*maxDecisions)
+	for _, z := range targets {
+		decisions = append(decisions, z.decisions.Decisions()...)
+	}
+	slices.SortStableFunc(decisions, func(a, b control.Decision) int {
+		return a.Time.Compare(b.Time)
+	})
+
+	if err := s.writeJSON(w, decisions); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleEvents returns the last events of all zones, the oldest first.
+func (s *webServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := s.writeJSON(w, events.list()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleSensorStats returns the statistics of the advertisements of the sensor with the MAC address of the path.
+func (s *webServer) handleSensorStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mac := r.PathValue("mac")
+	for _, z := range s.zones {
+		for _, device := range z.Devices {
+			if !strings.EqualFold(device.MacAddress, mac) {
+				continue
+			}
+			stats := newSensorStats(&device.Stats)
+			stats.Name = device.Name
+			stats.MacAddress = device.MacAddress
+			if err := s.writeJSON(w, stats); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+	http.Error(w, fmt.Sprintf("unknown sensor '%s'", mac), http.StatusNotFound)
+}
+
+// handleExternal takes the values of an external source for the inside or outside sensor. They are used as
+// a fallback if the sensor is stale and the external source is enabled for it.
+func (s *webServer) handleExternal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var data externalData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if data.Sensor != "inside" && data.Sensor != "outside" {
+		http.Error(w, fmt.Sprintf("invalid sensor '%s', must be inside or outside", data.Sensor), http.StatusBadRequest)
+		return
+	}
+	if data.Temperature < -40 || data.Temperature > 80 || data.Humidity < 0 || data.Humidity > 100 {
+		http.Error(w, "invalid values, temperature must be between -40 and 80°C and humidity between 0 and 100%",
+			http.StatusBadRequest)
+		return
+	}
+	targets := s.zones
+	if data.Zone != "" {
+		z := findZone(data.Zone)
+		if z == nil {
+			http.Error(w, fmt.Sprintf("unknown zone '%s'", data.Zone), http.StatusBadRequest)
+			return
+		}
+		targets = []*zone{z}
+	}
+
+	reading := newSensorData("External", data.Temperature, data.Humidity, clock())
+	for _, z := range targets {
+		if data.Sensor == "inside" {
+			z.Sensors.InsideExternal = reading
+		} else {
+			z.Sensors.OutsideExternal = reading
+		}
+	}
+
+	if err := s.writeJSON(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// expiry validates the remote control request and returns the time when the override expires. The time is zero
+// if the override doesn't expire or the automatic mode is requested.
+func (r *remoteControl) expiry(now time.Time) (time.Time, error) {
+	if r.Override < 0 || r.Override > 2 {
+		return time.Time{}, fmt.Errorf("invalid override %d, must be 0 (auto), 1 (on) or 2 (off)", r.Override)
+	}
+	if r.Duration < 0 {
+		return time.Time{}, fmt.Errorf("invalid duration %d, must not be negative", r.Duration)
+	}
+	if r.Duration > 0 && r.Until != "" {
+		return time.Time{}, errors.New("duration and until must not be used together")
+	}
+	if r.Override == 0 {
+		return time.Time{}, nil
+	}
+	if r.Duration > 0 {
+		return now.Add(time.Duration(r.Duration) * time.Second), nil
+	}
+	if r.Until != "" {
+		until, err := time.Parse(time.RFC3339, r.Until)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid until '%s', must be in RFC 3339 format", r.Until)
+		}
+		if !until.After(now) {
+			return time.Time{}, fmt.Errorf("invalid until '%s', must be in the future", r.Until)
+		}
+		return until, nil
+	}
+	return time.Time{}, nil
+}
+
+// getZoneInfo collects the sensor readings and fan control states of the zone.
+func (s *webServer) getZoneInfo(z *zone) zoneInfo {
+	return zoneInfo{
+		Name:            z.Name,
+		Sensors:         s.getSensorData(z),
+		Devices:         s.getDeviceData(z),
+		Reason:          int(z.result.Reason),
+		ReasonText:      z.result.ReasonText(),
+		DpTrend:         z.result.DpTrend,
+		AhDiff:          z.result.AhDiff,
+		Venting:         z.result.ShouldBeOn,
+		Speed:           z.result.FanSpeed(),
+		Override:        z.result.Fault == sensor.FaultHardwareOverride,
+		RemoteOverride:  z.remoteOverride,
+		DiffMin:         z.fanConfig.MinDiff,
+		Hysteresis:      z.fanConfig.Hysteresis,
+		Controller:      z.controller.Name(),
+		SwitchCount:     z.guard.SwitchCount(),
+		LastSwitch:      formatTime(z.guard.LastSwitch()),
+		MinOnMinutes:    z.fanConfig.MinOnMinutes,
+		MinOffMinutes:   z.fanConfig.MinOffMinutes,
+		Schedule:        s.getScheduleMode(z),
+		OverrideUntil:   formatTime(z.overrideUntil),
+		OverrideLeft:    int(z.result.OverrideRemaining.Seconds()),
+		MoldIndex:       math.Round(z.mold.Index*100) / 100,
+		MoldLevel:       z.mold.Level(),
+		Degraded:        z.result.InsideFallback != sensor.FallbackNone || z.result.OutsideFallback != sensor.FallbackNone,
+		InsideFallback:  sensor.FallbackName[z.result.InsideFallback],
+		OutsideFallback: sensor.FallbackName[z.result.OutsideFallback],
+		Switch:          sensor.SwitchPositionName[z.result.Switch],
+		Fault:           int(z.result.Fault),
+		FaultText:       sensor.FaultName[z.result.Fault],
+		FaultSeconds:    int(z.faults.FaultDuration(clock()).Seconds()),
+		LowBattery:      len(z.lowBatteryDevices()) > 0,
+	}
+}
+
+// getSensorData returns the combined inside and outside readings of the zone. The rejected readings are counted
+// over all sensors of the role, and the battery is low if it is low in any sensor of the role.
+func (s *webServer) getSensorData(z *zone) []sensorData {
+	inside := newSensorInfo("Inside", &z.Store.Inside, z.Sensors.InsideData)
+	inside.setFilterStats(z.roleFilterStats(sensor.RoleInside))
+	inside.BatLow = z.roleLowBattery(sensor.RoleInside)
+	outside := newSensorInfo("Outside", &z.Store.Outside, z.Sensors.OutsideData)
+	outside.setFilterStats(z.roleFilterStats(sensor.RoleOutside))
+	outside.BatLow = z.roleLowBattery(sensor.RoleOutside)
+	return []sensorData{inside, outside}
+}
+
+// getDeviceData returns the averaged readings of every sensor of the zone with its role and MAC address.
+func (s *webServer) getDeviceData(z *zone) []sensorData {
+	devices := make([]sensorData, 0, len(z.Devices))
+	for _, device := range z.Devices {
+		data := newSensorInfo(device.Name, &device.Store, device.Data)
+		data.Role = string(device.Role)
+		data.MacAddress = device.MacAddress
+		data.LastSeen = formatTime(device.Data.Scanned)
+		data.setFilterStats(device.Store.FilterStats())
+		data.BatLow = device.LowBattery
+		data.Stats = newSensorStats(&device.Stats)
+		if daysLeft, ok := device.Battery.DaysLeft(); ok {
+			data.BatDaysLeft = utility.RoundDouble(daysLeft, 1)
+		}
+		devices = append(devices, data)
+	}
+	return devices
+}
+
+// newSensorInfo creates the sensor data of the REST API with the averages and slopes of the history and the
+// battery level, RSSI and uptime of the last reading.
+func newSensorInfo(name string, store *sensor.SensorDataList, last sensor.SensorData) sensorData {
+	return sensorData{
+		Name:        name,
+		Temperature: store.AverageTemperature(),
+		Humidity:    store.AverageHumidity(),
+		DewPoint:    store.AverageDewPoint(),
+		AbsHumidity: store.AverageAbsHumidity(),
+		MixingRatio: store.AverageMixingRatio(),
+		TempSlope:   store.TemperatureSlope(),
+		HumSlope:    store.HumiditySlope(),
+		DpSlope:     store.DewPointSlope(),
+		BatLevel:    float64(last.BatLevel) / 1000,
+		BatPercent:  last.BatPercent,
+		RSSI:        last.RSSI,
+		Uptime:      last.Uptime,
 	}
 }
 
+// newSensorStats creates the statistics of the REST API from the statistics of a sensor.
+func newSensorStats(linkStats *sensor.LinkStats) *sensorStats {
+	now := clock()
+	stats := &sensorStats{
+		Packets:          linkStats.Packets,
+		PacketsPerMinute: utility.RoundDouble(linkStats.PacketsPerMinute(now), 1),
+		GapHistogram:     make([]gapBucket, 0, len(linkStats.GapHistogram)),
+		RSSIMin:          linkStats.RSSIMin,
+		RSSIAvg:          utility.RoundDouble(linkStats.RSSIAverage(), 1),
+		RSSIMax:          linkStats.RSSIMax,
+		PacketLoss:       packetLossPercent(linkStats, now),
+		Reboots:          linkStats.Reboots,
+		LastReboot:       formatTime(linkStats.LastReboot),
+	}
+	if interval, ok := linkStats.ExpectedInterval(); ok {
+		stats.ExpectedInterval = utility.RoundDouble(interval.Seconds(), 1)
+	}
+	if interval, ok := linkStats.ObservedInterval(); ok {
+		stats.ObservedInterval = utility.RoundDouble(interval.Seconds(), 1)
+	}
+	for i, count := range linkStats.GapHistogram {
+		bucket := gapBucket{Count: count}
+		if i < len(sensor.GapBuckets) {
+			bucket.MaxSeconds = int(sensor.GapBuckets[i].Seconds())
+		}
+		stats.GapHistogram = append(stats.GapHistogram, bucket)
+	}
+	return stats
+}
+
+// setFilterStats sets the numbers of rejected readings.
+func (d *sensorData) setFilterStats(stats sensor.FilterStats) {
+	d.RejectedOutliers = stats.Outliers
+	d.RejectedRate = stats.RateLimited
+}
+
+func (s *webServer) getScheduleMode(z *zone) string {
+	mode, _ := fanSchedule.Evaluate(clock(), z.fanConfig)
+	return string(mode)
+}
+
 func (s *webServer) getFanStateText(state bool) string {
 	if state {
 		return "ON"
@@ -175,3 +573,11 @@ func (s *webServer) writeJSON(w http.ResponseWriter, v interface{}) error {
 	_, err = w.Write(j)
 	return err
 }
+
+// formatTime formats a timestamp for the JSON output. A zero time results in an empty string.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.DateTime)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"dpf-bt/sensor"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRemoteControlExpiry(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		remote        remoteControl
+		expectedUntil time.Time
+		expectError   bool
+	}{
+		{
+			name:   "AutomaticMode",
+			remote: remoteControl{Override: 0},
+		},
+		{
+			name:   "PermanentOverride",
+			remote: remoteControl{Override: 1},
+		},
+		{
+			name:          "OverrideWithDuration",
+			remote:        remoteControl{Override: 1, Duration: 3600},
+			expectedUntil: now.Add(time.Hour),
+		},
+		{
+			name:          "OverrideWithUntil",
+			remote:        remoteControl{Override: 2, Until: "2025-05-01T14:30:00Z"},
+			expectedUntil: time.Date(2025, 5, 1, 14, 30, 0, 0, time.UTC),
+		},
+		{
+			name:   "AutomaticModeIgnoresDuration",
+			remote: remoteControl{Override: 0, Duration: 600},
+		},
+		{
+			name:        "InvalidOverride",
+			remote:      remoteControl{Override: 3},
+			expectError: true,
+		},
+		{
+			name:        "NegativeOverride",
+			remote:      remoteControl{Override: -1},
+			expectError: true,
+		},
+		{
+			name:        "NegativeDuration",
+			remote:      remoteControl{Override: 1, Duration: -10},
+			expectError: true,
+		},
+		{
+			name:        "DurationAndUntil",
+			remote:      remoteControl{Override: 1, Duration: 60, Until: "2025-05-01T14:30:00Z"},
+			expectError: true,
+		},
+		{
+			name:        "InvalidUntil",
+			remote:      remoteControl{Override: 1, Until: "tomorrow"},
+			expectError: true,
+		},
+		{
+			name:        "UntilInThePast",
+			remote:      remoteControl{Override: 1, Until: "2025-05-01T11:00:00Z"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until, err := tt.remote.expiry(now)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !until.Equal(tt.expectedUntil) {
+				t.Errorf("expected until %v, got %v", tt.expectedUntil, until)
+			}
+		})
+	}
+}
+
+func TestHandleSensorStats(t *testing.T) {
+	now := time.Now()
+	device := sensor.NewDevice("Inside", "9D:8B:00:00:18:BD", sensor.RoleInside, sensor.SensorCalibration{}, 20)
+	device.Stats.Add(-70, 100, now.Add(-20*time.Second))
+	device.Stats.Add(-60, 110, now.Add(-10*time.Second))
+	srv := &webServer{zones: []*zone{{Zone: &sensor.Zone{Devices: []*sensor.Device{device}}}}}
+
+	tests := []struct {
+		name           string
+		method         string
+		mac            string
+		expectedStatus int
+	}{
+		{name: "Known", method: http.MethodGet, mac: "9d:8b:00:00:18:bd", expectedStatus: http.StatusOK},
+		{name: "Unknown", method: http.MethodGet, mac: "9D:F2:00:00:14:B5", expectedStatus: http.StatusNotFound},
+		{name: "WrongMethod", method: http.MethodPost, mac: "9D:8B:00:00:18:BD",
+			expectedStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/sensors/"+tt.mac+"/stats", nil)
+			r.SetPathValue("mac", tt.mac)
+			w := httptest.NewRecorder()
+			srv.handleSensorStats(w, r)
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var stats sensorStats
+			if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+				t.Fatalf("invalid JSON: %s", err)
+			}
+			if stats.Name != "Inside" || stats.Packets != 2 || stats.RSSIAvg != -65 || stats.ExpectedInterval != 10 ||
+				len(stats.GapHistogram) != len(sensor.GapBuckets)+1 || stats.GapHistogram[2].Count != 1 {
+				t.Errorf("unexpected stats %+v", stats)
+			}
+		})
+	}
+}
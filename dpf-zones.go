@@ -0,0 +1,299 @@
+package main
+
+import (
+	"dpf-bt/control"
+	"dpf-bt/gpio"
+	"dpf-bt/sensor"
+	"dpf-bt/utility"
+	"fmt"
+	"time"
+)
+
+// zone holds the configuration and the runtime state of a ventilation zone: its sensors, the rules, the fan
+// controller, the switch guard, the GPIO pins, the remote override, the mold index, the last decisions and the
+// fault monitor of the fan.
+type zone struct {
+	*sensor.Zone
+	fanConfig      sensor.FanConfig
+	gpioConfig     gpio.Config
+	rules          control.Rules
+	controller     control.Controller
+	guard          control.SwitchGuard
+	pins           gpio.Gpio
+	result         sensor.ResultData
+	mold           control.MoldIndex
+	decisions      *control.DecisionLog
+	faults         control.FaultMonitor
+	remoteOverride int
+	// lowBatteryPercent and batteryWarnDays define when a sensor has a low battery.
+	lowBatteryPercent int
+	batteryWarnDays   float64
+	// overrideUntil is the expiry of a timed remote override. It is zero if the override doesn't expire.
+	overrideUntil time.Time
+}
+
+// newZone creates a zone with the given name, empty sensor history and the default fan controller.
+func newZone(name string) *zone {
+	return &zone{
+		Zone:       sensor.NewZone(name, maxSensorData),
+		controller: &control.DewPointController{},
+		decisions:  control.NewDecisionLog(maxDecisions),
+	}
+}
+
+// title returns the name of the zone for the display. Unnamed zones are shown as "DPF".
+func (z *zone) title() string {
+	if z.Name == "" {
+		return "DPF"
+	}
+	return z.Name
+}
+
+// applyZones takes over the zones of a newly read configuration. On the first call, the zones are used as they
+// are. On a reload, the configuration of the existing zones is updated and their sensor history and state are
+// kept. Each zone is locked while it is updated, so that the Bluetooth scanner and the fan control don't use its
+// devices meanwhile. Added or removed zones need a restart, since the GPIO pins are only initialized at startup.
+func applyZones(configured []*zone) {
+	if len(zones) == 0 {
+		zones = configured
+		scanZones = make([]*sensor.Zone, len(zones))
+		for i, z := range zones {
+			scanZones[i] = z.Zone
+		}
+		return
+	}
+	if len(configured) != len(zones) {
+		lg.Warn("The number of zones has changed, restart the application to apply it.")
+	}
+	for i := range min(len(configured), len(zones)) {
+		existing, updated := zones[i], configured[i]
+		existing.Lock()
+		existing.Name = updated.Name
+		existing.Devices = mergeDevices(existing.Devices, updated.Devices)
+		existing.Store.Inside.SetWindow(updated.Store.Inside.Window())
+		existing.Store.Outside.SetWindow(updated.Store.Outside.Window())
+		existing.Sensors.InsideCombine = updated.Sensors.InsideCombine
+		existing.Sensors.OutsideCombine = updated.Sensors.OutsideCombine
+		existing.Sensors.InsideBackup.MacAddress = updated.Sensors.InsideBackup.MacAddress
+		existing.Sensors.InsideBackupCalibration = updated.Sensors.InsideBackupCalibration
+		existing.Sensors.OutsideBackup.MacAddress = updated.Sensors.OutsideBackup.MacAddress
+		existing.Sensors.OutsideBackupCalibration = updated.Sensors.OutsideBackupCalibration
+		existing.Sensors.InsideFallback = updated.Sensors.InsideFallback
+		existing.Sensors.OutsideFallback = updated.Sensors.OutsideFallback
+		existing.fanConfig = updated.fanConfig
+		existing.lowBatteryPercent = updated.lowBatteryPercent
+		existing.batteryWarnDays = updated.batteryWarnDays
+		existing.rules = updated.rules
+		existing.controller = updated.controller
+		if existing.gpioConfig != updated.gpioConfig {
+			lg.Warnf("The GPIO pins of zone '%s' have changed, restart the application to apply it.", existing.title())
+		}
+		existing.Unlock()
+	}
+}
+
+// mergeDevices returns the configured devices of a reloaded configuration. A device with the MAC address of an
+// existing device keeps its last reading, history, battery trend and statistics with the new filter and time
+// window.
+func mergeDevices(existing, configured []*sensor.Device) []*sensor.Device {
+	for _, device := range configured {
+		for _, old := range existing {
+			if old.MacAddress == device.MacAddress {
+				device.Data = old.Data
+				device.Data.Name = device.Name
+				filter, window := device.Store.Filter(), device.Store.Window()
+				device.Store = old.Store
+				device.Battery = old.Battery
+				device.LowBattery = old.LowBattery
+				device.Stats = old.Stats
+				device.Store.SetFilter(filter)
+				device.Store.SetWindow(window)
+			}
+		}
+	}
+	return configured
+}
+
+// roleFilterStats returns the sum of the rejected readings of the sensors of the zone with the given role.
+func (z *zone) roleFilterStats(role sensor.Role) sensor.FilterStats {
+	var stats sensor.FilterStats
+	for _, device := range z.DevicesWithRole(role) {
+		deviceStats := device.Store.FilterStats()
+		stats.Outliers += deviceStats.Outliers
+		stats.RateLimited += deviceStats.RateLimited
+	}
+	return stats
+}
+
+// roleLinkStats returns the highest packet loss in percent and the sum of the reboots of the sensors of the zone
+// with the given role.
+func (z *zone) roleLinkStats(role sensor.Role) (float64, int) {
+	now := clock()
+	loss, reboots := 0.0, 0
+	for _, device := range z.DevicesWithRole(role) {
+		loss = max(loss, packetLossPercent(&device.Stats, now))
+		reboots += device.Stats.Reboots
+	}
+	return loss, reboots
+}
+
+// packetLossPercent returns the packet loss of a sensor in percent, 0 if it is unknown.
+func packetLossPercent(stats *sensor.LinkStats, now time.Time) float64 {
+	loss, _ := stats.PacketLoss(now)
+	return utility.RoundDouble(loss*100, 1)
+}
+
+// selectReading returns the reading to be used for the inside or outside sensor of a zone, the fallback in use
+// and whether the reading is usable. The checks are recorded in trace, which may be nil.
+func selectReading(name string, primary, backup, external sensor.SensorData, cfg sensor.FallbackConfig,
+	now time.Time, trace *control.Decision) (sensor.SensorData, sensor.Fallback, bool) {
+	data, fallback, ok := sensor.SelectReading(primary, backup, external, cfg, now, sensor.MaxDataAge)
+	if trace.CheckTrue(name+" data received", !primary.Scanned.IsZero()) {
+		trace.Check(name+" data age", now.Sub(primary.Scanned).Seconds(), control.LessOrEqual, sensor.MaxDataAge.Seconds())
+	}
+	if fallback != sensor.FallbackNone {
+		trace.CheckTrue(name+" fallback "+sensor.FallbackName[fallback], true)
+	}
+	return data, fallback, ok
+}
+
+// newSensorData creates the sensor data of a temperature and humidity reading like the Bluetooth scanner does.
+func newSensorData(name string, temperature, humidity float64, scanned time.Time) sensor.SensorData {
+	return sensor.SensorData{
+		Name:        name,
+		Temperature: temperature,
+		Humidity:    humidity,
+		DewPoint:    utility.CalcDewPoint(temperature, humidity),
+		AbsHumidity: utility.CalcAbsoluteHumidity(temperature, humidity),
+		MixingRatio: utility.CalcMixingRatio(temperature, humidity),
+		Scanned:     scanned,
+	}
+}
+
+// findZone returns the zone with the given name or nil if there is no such zone.
+func findZone(name string) *zone {
+	for _, z := range zones {
+		if z.Name == name {
+			return z
+		}
+	}
+	return nil
+}
+
+// updateFan removes the readings that have left the time window, updates the mold index, computes the new fan
+// state, enforces the minimum run and pause time and sets the GPIO outputs. The sensed fan state and the switch
+// position are read back into the result and checked for a fault. The checks of the decision are added to the
+// decision log of the zone. The zone is locked meanwhile, so that the Bluetooth scanner doesn't change the readings.
+func (z *zone) updateFan() {
+	z.Lock()
+	defer z.Unlock()
+	now := clock()
+	z.Prune(now)
+	inside := z.Sensors.InsideData
+	z.mold.Update(inside.Temperature, inside.Humidity, inside.Scanned)
+	trace := control.NewDecision(z.title(), now)
+	z.computeResults(trace)
+	z.guard.Apply(&z.result, z.fanConfig, now, trace)
+	trace.Finish(z.result)
+	z.decisions.Add(*trace)
+	z.checkBatteries()
+	if z.pins == nil {
+		return
+	}
+	z.pins.SetFan(z.result.ShouldBeOn)
+	if speedControl, ok := z.pins.(gpio.SpeedControl); ok {
+		speedControl.SetFanSpeed(z.result.FanSpeed())
+	}
+	z.result.IsOn = z.pins.ReadFanSense()
+	z.result.Switch = sensor.SwitchUnknown
+	if switchSense, ok := z.pins.(gpio.SwitchSense); ok && z.gpioConfig.HasSwitch() {
+		z.result.Switch = switchPosition(switchSense.ReadSwitch())
+	}
+	z.checkFault(now)
+}
+
+// checkFault classifies a disagreement between the commanded and the sensed fan state and records an event when
+// the fault changes.
+func (z *zone) checkFault(now time.Time) {
+	previous := z.faults.Fault()
+	fault, changed := z.faults.Update(z.result.ShouldBeOn, z.result.IsOn, z.result.Switch, now,
+		time.Duration(z.fanConfig.FaultSeconds)*time.Second)
+	z.result.Fault = fault
+	if !changed {
+		return
+	}
+	if fault == sensor.FaultNone {
+		addEvent(z, "fault", sensor.FaultName[previous]+" cleared")
+		return
+	}
+	addEvent(z, "fault", fmt.Sprintf("%s - fan should be %s, is %s, switch %s", sensor.FaultName[fault],
+		onOff(z.result.ShouldBeOn), onOff(z.result.IsOn), sensor.SwitchPositionName[z.result.Switch]))
+}
+
+// checkBatteries updates the low battery state of the sensors of the zone and records an event when it changes.
+func (z *zone) checkBatteries() {
+	for _, device := range z.Devices {
+		if !device.CheckBattery(z.lowBatteryPercent, z.batteryWarnDays) {
+			continue
+		}
+		if device.LowBattery {
+			addEvent(z, "battery", fmt.Sprintf("low battery of sensor %s: %d%%%s", device.Name,
+				device.Battery.Percent(), daysLeftText(device)))
+		} else {
+			addEvent(z, "battery", fmt.Sprintf("battery of sensor %s ok: %d%%", device.Name, device.Battery.Percent()))
+		}
+	}
+}
+
+// lowBatteryDevices returns the sensors of the zone with a low battery.
+func (z *zone) lowBatteryDevices() []*sensor.Device {
+	var devices []*sensor.Device
+	for _, device := range z.Devices {
+		if device.LowBattery {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+// roleLowBattery returns whether a sensor with the given role has a low battery.
+func (z *zone) roleLowBattery(role sensor.Role) bool {
+	for _, device := range z.DevicesWithRole(role) {
+		if device.LowBattery {
+			return true
+		}
+	}
+	return false
+}
+
+// daysLeftText returns the estimated days until the battery of the sensor is empty for the event message, or an
+// empty text if there is no estimate.
+func daysLeftText(device *sensor.Device) string {
+	if daysLeft, ok := device.Battery.DaysLeft(); ok {
+		return fmt.Sprintf(", empty in about %.0f days", daysLeft)
+	}
+	return ""
+}
+
+// switchPosition converts the state of the switch pins to the switch position. If both pins are active, the
+// position is unknown.
+func switchPosition(on, off bool) sensor.SwitchPosition {
+	switch {
+	case on && off:
+		return sensor.SwitchUnknown
+	case on:
+		return sensor.SwitchOn
+	case off:
+		return sensor.SwitchOff
+	default:
+		return sensor.SwitchAuto
+	}
+}
+
+// onOff returns the text representation of a fan state.
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
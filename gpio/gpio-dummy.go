@@ -7,23 +7,40 @@ import "github.com/d2r2/go-logger"
 var lgGp = logger.NewPackageLogger("gpio", logger.InfoLevel)
 
 type gpioDummyData struct {
-	fanState bool
+	fanState *bool
+	speed    *int
 }
 
+// ReadFanSense returns the state the fan was switched to, since there is no real fan.
 func (g gpioDummyData) ReadFanSense() bool {
-	return false
+	return *g.fanState
+}
+
+// ReadSwitch returns the middle (auto) position.
+func (g gpioDummyData) ReadSwitch() (bool, bool) {
+	return false, false
 }
 
 func (g gpioDummyData) SetFan(on bool) {
-	if on != g.fanState {
+	if on != *g.fanState {
 		lgGp.Infof("Switching Fan to %v", on)
 	}
-	g.fanState = on
+	*g.fanState = on
+}
+
+func (g gpioDummyData) SetFanSpeed(percent int) {
+	percent = clampPercent(percent)
+	if percent != *g.speed {
+		lgGp.Infof("Setting fan speed to %d%%", percent)
+	}
+	*g.speed = percent
 }
 
-func New() (_ Gpio, err error) {
+func New(cfg Config) (_ Gpio, err error) {
 	err = nil
-	gpio := &gpioDummyData{fanState: false}
+	cfg = cfg.withDefaults()
+	lgGp.Infof("Dummy GPIO with fan pin %s, sense pin %s and PWM pin '%s'", cfg.FanPin, cfg.SensePin, cfg.PwmPin)
+	gpio := &gpioDummyData{fanState: new(bool), speed: new(int)}
 
 	return *gpio, err
 }
@@ -7,14 +7,21 @@ import (
 	"github.com/d2r2/go-logger"
 	gp "periph.io/x/conn/v3/gpio"
 	"periph.io/x/conn/v3/gpio/gpioreg"
+	"periph.io/x/conn/v3/physic"
 	"periph.io/x/host/v3"
 )
 
+// pwmFrequency is the frequency of the PWM signal. 25kHz is the standard for fans with a PWM input.
+const pwmFrequency = 25 * physic.KiloHertz
+
 var lgGpio = logger.NewPackageLogger("gpio", logger.InfoLevel)
 
 type gpioData struct {
-	sensePin gp.PinIO
-	fanPin   gp.PinIO
+	sensePin     gp.PinIO
+	fanPin       gp.PinIO
+	pwmPin       gp.PinIO
+	switchOnPin  gp.PinIO
+	switchOffPin gp.PinIO
 }
 
 func (g gpioData) ReadFanSense() bool {
@@ -22,6 +29,14 @@ func (g gpioData) ReadFanSense() bool {
 	return g.sensePin.Read() == !gp.High
 }
 
+func (g gpioData) ReadSwitch() (bool, bool) {
+	if g.switchOnPin == nil || g.switchOffPin == nil {
+		return false, false
+	}
+	// the switch connects the pins to ground, so a low level is the active position
+	return g.switchOnPin.Read() == gp.Low, g.switchOffPin.Read() == gp.Low
+}
+
 func (g gpioData) SetFan(on bool) {
 	// the relay is active low, so we need to toggle it to turn it on/off
 	if on {
@@ -31,14 +46,25 @@ func (g gpioData) SetFan(on bool) {
 	}
 }
 
-func New() (_ Gpio, err error) {
+func (g gpioData) SetFanSpeed(percent int) {
+	if g.pwmPin == nil {
+		return
+	}
+	duty := gp.DutyMax * gp.Duty(clampPercent(percent)) / 100
+	if err := g.pwmPin.PWM(duty, pwmFrequency); err != nil {
+		lgGpio.Errorf("PWM could not be set on %s: %s", g.pwmPin.Name(), err)
+	}
+}
+
+func New(cfg Config) (_ Gpio, err error) {
 	_, err = host.Init()
 	if err != nil {
 		return nil, err
 	}
+	cfg = cfg.withDefaults()
 	gpio := &gpioData{
-		sensePin: gpioreg.ByName("GPIO22"),
-		fanPin:   gpioreg.ByName("GPIO25"),
+		sensePin: gpioreg.ByName(cfg.SensePin),
+		fanPin:   gpioreg.ByName(cfg.FanPin),
 	}
 	if gpio.sensePin == nil || gpio.fanPin == nil {
 		lgGpio.Error("GPIO pins not found")
@@ -46,14 +72,40 @@ func New() (_ Gpio, err error) {
 	}
 	err = gpio.sensePin.In(gp.Float, gp.NoEdge)
 	if err != nil {
-		lgGpio.Error("Pin22 could not be configured as floating input")
+		lgGpio.Errorf("%s could not be configured as floating input", cfg.SensePin)
 		return nil, err
 	}
 	err = gpio.fanPin.Out(gp.High)
 	if err != nil {
-		lgGpio.Error("Pin25 could not be configured as output")
+		lgGpio.Errorf("%s could not be configured as output", cfg.FanPin)
 		return nil, err
 	}
+	if cfg.PwmPin != "" {
+		gpio.pwmPin = gpioreg.ByName(cfg.PwmPin)
+		if gpio.pwmPin == nil {
+			lgGpio.Errorf("PWM pin %s not found", cfg.PwmPin)
+			return nil, errors.New("PWM pin not found")
+		}
+		err = gpio.pwmPin.Out(gp.Low)
+		if err != nil {
+			lgGpio.Errorf("%s could not be configured as output", cfg.PwmPin)
+			return nil, err
+		}
+	}
+	if cfg.HasSwitch() {
+		gpio.switchOnPin = gpioreg.ByName(cfg.SwitchOnPin)
+		gpio.switchOffPin = gpioreg.ByName(cfg.SwitchOffPin)
+		if gpio.switchOnPin == nil || gpio.switchOffPin == nil {
+			lgGpio.Errorf("Switch pins %s and %s not found", cfg.SwitchOnPin, cfg.SwitchOffPin)
+			return nil, errors.New("switch pins not found")
+		}
+		for _, pin := range []gp.PinIO{gpio.switchOnPin, gpio.switchOffPin} {
+			if err = pin.In(gp.PullUp, gp.NoEdge); err != nil {
+				lgGpio.Errorf("%s could not be configured as input with pull-up", pin.Name())
+				return nil, err
+			}
+		}
+	}
 
 	return *gpio, err
 }
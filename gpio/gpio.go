@@ -10,3 +10,72 @@ type Gpio interface {
 	// SetFan controls the power state of the fan by turning it on or off based on the provided boolean value.
 	SetFan(on bool)
 }
+
+// SpeedControl is an optional interface for fan outputs that support a variable speed, e.g. EC fans with a
+// PWM input. Implementations of Gpio that support it can be detected with a type assertion.
+type SpeedControl interface {
+
+	// SetFanSpeed sets the speed of the fan in percent (0-100).
+	SetFanSpeed(percent int)
+}
+
+// SwitchSense is an optional interface for reading the position of the on-off-on switch that overrides the fan
+// state in hardware. Implementations of Gpio that support it can be detected with a type assertion.
+type SwitchSense interface {
+
+	// ReadSwitch returns whether the switch is in the on and in the off position. Both are false in the middle
+	// (auto) position.
+	ReadSwitch() (on bool, off bool)
+}
+
+// Config holds the names of the GPIO pins, e.g. "GPIO25". An empty PwmPin disables the speed output. The
+// switch position is only read if both SwitchOnPin and SwitchOffPin are set.
+type Config struct {
+	FanPin       string
+	SensePin     string
+	PwmPin       string
+	SwitchOnPin  string
+	SwitchOffPin string
+}
+
+// HasSwitch returns true if the pins for the switch position are configured.
+func (c Config) HasSwitch() bool {
+	return c.SwitchOnPin != "" && c.SwitchOffPin != ""
+}
+
+const (
+	// DefaultFanPin is the pin of the solid state relay that is used if no fan pin is configured.
+	DefaultFanPin = "GPIO25"
+	// DefaultSensePin is the pin that reads the fan state if no sense pin is configured.
+	DefaultSensePin = "GPIO22"
+)
+
+// withDefaults returns the configuration with the default pins for empty fan and sense pins.
+func (c Config) withDefaults() Config {
+	if c.FanPin == "" {
+		c.FanPin = DefaultFanPin
+	}
+	if c.SensePin == "" {
+		c.SensePin = DefaultSensePin
+	}
+	return c
+}
+
+// Pins returns the used pins by their configuration key, with the default pins for empty fan and sense pins.
+// Optional pins that aren't configured are left out.
+func (c Config) Pins() map[string]string {
+	c = c.withDefaults()
+	pins := map[string]string{"fanPin": c.FanPin, "sensePin": c.SensePin}
+	for key, pin := range map[string]string{"pwmPin": c.PwmPin, "switchOnPin": c.SwitchOnPin,
+		"switchOffPin": c.SwitchOffPin} {
+		if pin != "" {
+			pins[key] = pin
+		}
+	}
+	return pins
+}
+
+// clampPercent limits the given value to the range of 0 to 100 percent.
+func clampPercent(percent int) int {
+	return min(max(percent, 0), 100)
+}
@@ -0,0 +1,112 @@
+package sensor
+
+import "time"
+
+const (
+	// batteryTrendInterval is the minimum time between two samples of the battery trend.
+	batteryTrendInterval = time.Hour
+	// maxBatterySamples limits the battery trend to 30 days of hourly samples.
+	maxBatterySamples = 30 * 24
+	// minBatteryTrendSpan is the time the samples must span before the trend is estimated.
+	minBatteryTrendSpan = 24 * time.Hour
+	// batteryReplacedRise is the rise of the battery level that is considered a battery replacement, which
+	// starts a new trend.
+	batteryReplacedRise = 20
+	// batteryHysteresis is the rise above the low battery threshold that clears a low battery warning.
+	batteryHysteresis = 10
+)
+
+// batterySample is the battery level of a sensor at a point in time.
+type batterySample struct {
+	time    time.Time
+	percent int
+}
+
+// BatteryTrend tracks the battery level of a sensor over days to estimate when the battery will be empty.
+type BatteryTrend struct {
+	samples []batterySample
+}
+
+// Add records the battery level at most once per hour. An unknown level of 0 is ignored. A large rise of the
+// level means that the battery was replaced, so the samples of the old battery are dropped.
+func (b *BatteryTrend) Add(percent int, now time.Time) {
+	if percent <= 0 {
+		return
+	}
+	if n := len(b.samples); n > 0 {
+		last := b.samples[n-1]
+		if percent >= last.percent+batteryReplacedRise {
+			b.samples = nil
+		} else if now.Sub(last.time) < batteryTrendInterval {
+			return
+		}
+	}
+	if len(b.samples) >= maxBatterySamples {
+		b.samples = b.samples[1:]
+	}
+	b.samples = append(b.samples, batterySample{time: now, percent: percent})
+}
+
+// Percent returns the last recorded battery level, 0 if unknown.
+func (b *BatteryTrend) Percent() int {
+	if len(b.samples) == 0 {
+		return 0
+	}
+	return b.samples[len(b.samples)-1].percent
+}
+
+// PercentPerDay returns the change of the battery level per day from the least squares regression of the
+// samples. It returns false if the samples span less than a day.
+func (b *BatteryTrend) PercentPerDay() (float64, bool) {
+	n := len(b.samples)
+	if n < 2 || b.samples[n-1].time.Sub(b.samples[0].time) < minBatteryTrendSpan {
+		return 0, false
+	}
+	start := b.samples[0].time
+	var sumX, sumY, sumXY, sumXX float64
+	for _, sample := range b.samples {
+		x := sample.time.Sub(start).Hours() / 24
+		y := float64(sample.percent)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	denominator := float64(n)*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, false
+	}
+	return (float64(n)*sumXY - sumX*sumY) / denominator, true
+}
+
+// DaysLeft estimates the days until the battery is empty from the trend. It returns false if there is no
+// trend yet or the level doesn't fall.
+func (b *BatteryTrend) DaysLeft() (float64, bool) {
+	perDay, ok := b.PercentPerDay()
+	if !ok || perDay >= 0 {
+		return 0, false
+	}
+	return float64(b.Percent()) / -perDay, true
+}
+
+// CheckBattery updates the low battery state of the device. The battery is low if its level is at most
+// lowPercent or if it is estimated to be empty within warnDays. The state is cleared when the level has risen
+// well above lowPercent and no early end is estimated. It returns whether the state has changed.
+func (d *Device) CheckBattery(lowPercent int, warnDays float64) bool {
+	percent := d.Battery.Percent()
+	if percent == 0 {
+		return false
+	}
+	daysLeft, estimated := d.Battery.DaysLeft()
+	early := estimated && daysLeft <= warnDays
+	low := d.LowBattery
+	switch {
+	case percent <= lowPercent || early:
+		low = true
+	case percent > lowPercent+batteryHysteresis:
+		low = false
+	}
+	changed := low != d.LowBattery
+	d.LowBattery = low
+	return changed
+}
@@ -0,0 +1,73 @@
+package sensor
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBatteryTrend(t *testing.T) {
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	var trend BatteryTrend
+	trend.Add(0, start)
+	if trend.Percent() != 0 {
+		t.Errorf("expected an unknown level to be ignored, got %d%%", trend.Percent())
+	}
+	trend.Add(60, start)
+	trend.Add(55, start.Add(30*time.Minute))
+	if trend.Percent() != 60 {
+		t.Errorf("expected one sample per hour, got %d%%", trend.Percent())
+	}
+	if _, ok := trend.DaysLeft(); ok {
+		t.Errorf("expected no estimate within the first day")
+	}
+	for day := 1; day <= 4; day++ {
+		trend.Add(60-2*day, start.Add(time.Duration(day)*24*time.Hour))
+	}
+	if perDay, ok := trend.PercentPerDay(); !ok || math.Abs(perDay+2) > 1e-9 {
+		t.Errorf("expected -2%% per day, got %.2f", perDay)
+	}
+	if daysLeft, ok := trend.DaysLeft(); !ok || math.Abs(daysLeft-26) > 1e-9 {
+		t.Errorf("expected 26 days left, got %.2f", daysLeft)
+	}
+
+	// a new battery starts a new trend
+	trend.Add(100, start.Add(5*24*time.Hour))
+	if _, ok := trend.DaysLeft(); ok || trend.Percent() != 100 {
+		t.Errorf("expected a new trend at 100%%, got %d%%", trend.Percent())
+	}
+}
+
+func TestCheckBattery(t *testing.T) {
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name            string
+		lowBattery      bool
+		percents        []int
+		expectedLow     bool
+		expectedChanged bool
+	}{
+		{name: "Full", percents: []int{90}},
+		{name: "Unknown", lowBattery: true, percents: []int{0}, expectedLow: true},
+		{name: "Low", percents: []int{20}, expectedLow: true, expectedChanged: true},
+		{name: "StillLow", lowBattery: true, percents: []int{18}, expectedLow: true},
+		{name: "Hysteresis", lowBattery: true, percents: []int{25}, expectedLow: true},
+		{name: "Replaced", lowBattery: true, percents: []int{100}, expectedChanged: true},
+		{name: "EmptySoon", percents: []int{50, 45, 40, 35}, expectedLow: true, expectedChanged: true},
+		{name: "SlowDischarge", percents: []int{50, 50, 49, 49}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device := &Device{LowBattery: tt.lowBattery}
+			for day, percent := range tt.percents {
+				device.Battery.Add(percent, start.Add(time.Duration(day)*24*time.Hour))
+			}
+			changed := device.CheckBattery(20, 14)
+			if device.LowBattery != tt.expectedLow || changed != tt.expectedChanged {
+				t.Errorf("expected low %v and changed %v, got %v and %v", tt.expectedLow, tt.expectedChanged,
+					device.LowBattery, changed)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package sensor
+
+import (
+	"errors"
+	"math"
+	"slices"
+)
+
+// Apply calibrates the raw temperature and humidity of a sensor with the gains and offsets.
+func (c SensorCalibration) Apply(temperature, humidity float64) (float64, float64) {
+	return temperature*gainOrOne(c.TemperatureGain) + c.Temperature, humidity*gainOrOne(c.HumidityGain) + c.Humidity
+}
+
+// gainOrOne returns the gain, or 1 for an unset gain.
+func gainOrOne(gain float64) float64 {
+	if gain == 0 {
+		return 1
+	}
+	return gain
+}
+
+// CalibrationFit is the result of a linear calibration: the gain, the offset and the root mean square error that
+// remains after applying them.
+type CalibrationFit struct {
+	Gain     float64
+	Offset   float64
+	RMSError float64
+	Samples  int
+}
+
+// minCalibrationGain and maxCalibrationGain limit a plausible gain. A gain outside these limits comes from noise
+// rather than a slope error of the sensor.
+const (
+	minCalibrationGain = 0.8
+	maxCalibrationGain = 1.25
+)
+
+// FitCalibration fits reference = gain * raw + offset to the pairs of raw and reference values with least squares.
+// If the raw values span less than minSpread, e.g. because all readings were taken at the same temperature, or
+// the gain isn't plausible, only the offset is fitted with a gain of 1. It needs at least two pairs.
+func FitCalibration(raw, reference []float64, minSpread float64) (CalibrationFit, error) {
+	n := len(raw)
+	if n < 2 || len(reference) != n {
+		return CalibrationFit{}, errors.New("at least two pairs of readings are needed")
+	}
+	var meanRaw, meanRef float64
+	for i := range raw {
+		meanRaw += raw[i] / float64(n)
+		meanRef += reference[i] / float64(n)
+	}
+	fit := CalibrationFit{Gain: 1, Offset: meanRef - meanRaw, Samples: n}
+	if slices.Max(raw)-slices.Min(raw) >= minSpread {
+		var covariance, variance float64
+		for i := range raw {
+			covariance += (raw[i] - meanRaw) * (reference[i] - meanRef)
+			variance += (raw[i] - meanRaw) * (raw[i] - meanRaw)
+		}
+		if gain := covariance / variance; gain >= minCalibrationGain && gain <= maxCalibrationGain {
+			fit.Gain = gain
+			fit.Offset = meanRef - gain*meanRaw
+		}
+	}
+	var sumSquares float64
+	for i := range raw {
+		residual := reference[i] - (fit.Gain*raw[i] + fit.Offset)
+		sumSquares += residual * residual
+	}
+	fit.RMSError = math.Sqrt(sumSquares / float64(n))
+	return fit, nil
+}
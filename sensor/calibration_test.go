@@ -0,0 +1,97 @@
+package sensor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestApplyCalibration(t *testing.T) {
+	tests := []struct {
+		name                string
+		calibration         SensorCalibration
+		expectedTemperature float64
+		expectedHumidity    float64
+	}{
+		{name: "none", calibration: SensorCalibration{}, expectedTemperature: 20, expectedHumidity: 50},
+		{name: "offsets only", calibration: SensorCalibration{Temperature: -0.5, Humidity: 2},
+			expectedTemperature: 19.5, expectedHumidity: 52},
+		{name: "gains and offsets", calibration: SensorCalibration{Temperature: 1, Humidity: -3, TemperatureGain: 0.9,
+			HumidityGain: 1.1}, expectedTemperature: 19, expectedHumidity: 52},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			temperature, humidity := tt.calibration.Apply(20, 50)
+			if math.Abs(temperature-tt.expectedTemperature) > 1e-9 || math.Abs(humidity-tt.expectedHumidity) > 1e-9 {
+				t.Errorf("expected %.2f/%.2f, got %.2f/%.2f", tt.expectedTemperature, tt.expectedHumidity,
+					temperature, humidity)
+			}
+		})
+	}
+}
+
+func TestFitCalibration(t *testing.T) {
+	tests := []struct {
+		name           string
+		raw            []float64
+		reference      []float64
+		expectedGain   float64
+		expectedOffset float64
+		expectedError  float64
+		wantErr        bool
+	}{
+		{
+			name:           "two points",
+			raw:            []float64{10, 30},
+			reference:      []float64{10.5, 28.5},
+			expectedGain:   0.9,
+			expectedOffset: 1.5,
+		},
+		{
+			name:           "several points",
+			raw:            []float64{40, 50, 60, 70},
+			reference:      []float64{42, 53, 64, 75},
+			expectedGain:   1.1,
+			expectedOffset: -2,
+		},
+		{
+			name:           "small spread fits offset only",
+			raw:            []float64{20.0, 20.5, 21.0},
+			reference:      []float64{19.5, 20.1, 20.4},
+			expectedGain:   1,
+			expectedOffset: -0.5,
+			expectedError:  0.0816,
+		},
+		{
+			name:           "implausible gain fits offset only",
+			raw:            []float64{10, 20},
+			reference:      []float64{10, 30},
+			expectedGain:   1,
+			expectedOffset: 5,
+			expectedError:  5,
+		},
+		{
+			name:      "single point",
+			raw:       []float64{20},
+			reference: []float64{21},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fit, err := FitCalibration(tt.raw, tt.reference, 2)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if math.Abs(fit.Gain-tt.expectedGain) > 1e-9 || math.Abs(fit.Offset-tt.expectedOffset) > 1e-9 ||
+				math.Abs(fit.RMSError-tt.expectedError) > 1e-4 || fit.Samples != len(tt.raw) {
+				t.Errorf("expected gain %.3f, offset %.2f and error %.4f, got %+v", tt.expectedGain,
+					tt.expectedOffset, tt.expectedError, fit)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package sensor
+
+import (
+	"dpf-bt/utility"
+	"time"
+)
+
+// MaxDataAge is the age after which a sensor reading is outdated.
+const MaxDataAge = 5 * time.Minute
+
+// Role defines how the readings of a sensor are used by its zone.
+type Role string
+
+const (
+	// RoleInside marks a sensor of the room that is vented.
+	RoleInside Role = "inside"
+	// RoleOutside marks a sensor of the outside air.
+	RoleOutside Role = "outside"
+	// RoleReference marks a reference sensor, e.g. for the calibration of the other sensors. It isn't used by the
+	// fan control.
+	RoleReference Role = "reference"
+	// RoleMonitor marks a sensor that is only monitored and not used by the fan control.
+	RoleMonitor Role = "monitor"
+)
+
+// Roles contains all valid roles.
+var Roles = []Role{RoleInside, RoleOutside, RoleReference, RoleMonitor}
+
+// Combine defines how the readings of several sensors with the same role are combined.
+type Combine string
+
+const (
+	// CombineAverage averages the temperature and the humidity of the sensors.
+	CombineAverage Combine = "average"
+	// CombineMin uses the reading with the lowest dew point.
+	CombineMin Combine = "min"
+	// CombineMax uses the reading with the highest dew point.
+	CombineMax Combine = "max"
+)
+
+// Device is a configured sensor of a zone with a display name, a role and its calibration. It holds the last
+// reading, the history of the readings, the battery trend and the statistics of the advertisements of this sensor.
+type Device struct {
+	Name        string
+	MacAddress  string
+	Role        Role
+	Calibration SensorCalibration
+	Data        SensorData
+	Store       SensorDataList
+	Battery     BatteryTrend
+	LowBattery  bool
+	Stats       LinkStats
+}
+
+// NewDevice creates a device with an empty history of the specified maximum capacity.
+func NewDevice(name, macAddress string, role Role, calibration SensorCalibration, maxData int) *Device {
+	return &Device{
+		Name:        name,
+		MacAddress:  macAddress,
+		Role:        role,
+		Calibration: calibration,
+		Store:       *NewSensorDataStore(maxData),
+	}
+}
+
+// DevicesWithRole returns the devices of the zone with the given role.
+func (z *Zone) DevicesWithRole(role Role) []*Device {
+	var devices []*Device
+	for _, device := range z.Devices {
+		if device.Role == role {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+// UpdateRole combines the last readings of the inside or outside devices into the inside or outside data of the
+// zone and adds it to the history. It is called after a new reading of one of the devices. Other roles are
+// ignored.
+func (z *Zone) UpdateRole(role Role, now time.Time) {
+	var readings []SensorData
+	for _, device := range z.DevicesWithRole(role) {
+		if !device.Data.Scanned.IsZero() {
+			readings = append(readings, device.Data)
+		}
+	}
+	if len(readings) == 0 {
+		return
+	}
+	switch role {
+	case RoleInside:
+		data := CombineReadings(readings, z.Sensors.InsideCombine, now, MaxDataAge)
+		data.Name = "Inside"
+		z.Sensors.InsideData = data
+		z.Store.Inside.AddSensorData(data)
+	case RoleOutside:
+		data := CombineReadings(readings, z.Sensors.OutsideCombine, now, MaxDataAge)
+		data.Name = "Outside"
+		z.Sensors.OutsideData = data
+		z.Store.Outside.AddSensorData(data)
+	}
+}
+
+// Prune removes the readings that are older than the time window before now from the inside and outside history
+// of the zone and from the history of each of its devices.
+func (z *Zone) Prune(now time.Time) {
+	z.Store.Inside.Prune(now)
+	z.Store.Outside.Prune(now)
+	for _, device := range z.Devices {
+		device.Store.Prune(now)
+	}
+}
+
+// InsertHistory adds older readings of the device, e.g. from the log of the sensor, to its history. If the device
+// is the only inside or outside device of the zone, the readings are added to the inside or outside history of
+// the zone as well. Stores with a time window only keep the readings within the window before their newest entry,
+// so most readings of a longer gap are dropped again and only reach the handler of the download.
+func (z *Zone) InsertHistory(device *Device, readings []SensorData) {
+	device.Store.InsertSensorData(readings)
+	if len(z.DevicesWithRole(device.Role)) != 1 {
+		return
+	}
+	switch device.Role {
+	case RoleInside:
+		z.Store.Inside.InsertSensorData(readings)
+	case RoleOutside:
+		z.Store.Outside.InsertSensorData(readings)
+	}
+}
+
+// CombineReadings combines the readings of several sensors with the given method. Only readings that are not
+// older than maxAge are used. If all readings are outdated, the newest one is returned, so that the outdated
+// data is detected by the fan control. An average reading has the lowest battery level, RSSI and uptime of the
+// sensors and no MAC address. Sensors that don't report a battery level are left out of it.
+func CombineReadings(readings []SensorData, method Combine, now time.Time, maxAge time.Duration) SensorData {
+	var current []SensorData
+	newest := readings[0]
+	for _, reading := range readings {
+		if now.Sub(reading.Scanned) <= maxAge {
+			current = append(current, reading)
+		}
+		if reading.Scanned.After(newest.Scanned) {
+			newest = reading
+		}
+	}
+	switch {
+	case len(current) == 0:
+		return newest
+	case len(current) == 1:
+		return current[0]
+	}
+
+	switch method {
+	case CombineMin, CombineMax:
+		selected := current[0]
+		for _, reading := range current[1:] {
+			if method == CombineMin && reading.DewPoint < selected.DewPoint ||
+				method == CombineMax && reading.DewPoint > selected.DewPoint {
+				selected = reading
+			}
+		}
+		return selected
+	default:
+		combined := SensorData{
+			BatLevel:   current[0].BatLevel,
+			BatPercent: current[0].BatPercent,
+			RSSI:       current[0].RSSI,
+			Uptime:     current[0].Uptime,
+			Scanned:    newest.Scanned,
+		}
+		for _, reading := range current {
+			combined.Temperature += reading.Temperature / float64(len(current))
+			combined.Humidity += reading.Humidity / float64(len(current))
+			combined.BatLevel = minKnown(combined.BatLevel, reading.BatLevel)
+			combined.BatPercent = minKnown(combined.BatPercent, reading.BatPercent)
+			combined.RSSI = min(combined.RSSI, reading.RSSI)
+			combined.Uptime = min(combined.Uptime, reading.Uptime)
+		}
+		combined.Temperature = utility.RoundDouble(combined.Temperature, 1)
+		combined.Humidity = utility.RoundDouble(combined.Humidity, 1)
+		return withDerivedValues(combined)
+	}
+}
+
+// minKnown returns the lower of two battery values. A value of 0 is unknown and only returned if both are unknown.
+func minKnown[T int | uint16](a, b T) T {
+	if a == 0 || b != 0 && b < a {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,97 @@
+package sensor
+
+import (
+	"dpf-bt/utility"
+	"testing"
+	"time"
+)
+
+func TestCombineReadings(t *testing.T) {
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	north := SensorData{MacAddress: "N", Temperature: 18.0, Humidity: 70.0, DewPoint: 12.5, BatLevel: 2900, RSSI: -60,
+		Scanned: now.Add(-time.Minute)}
+	south := SensorData{MacAddress: "S", Temperature: 16.0, Humidity: 60.0, DewPoint: 8.3, BatLevel: 3000, RSSI: -80,
+		Scanned: now}
+	stale := SensorData{MacAddress: "X", Temperature: 30.0, Humidity: 90.0, DewPoint: 28.2,
+		Scanned: now.Add(-time.Hour)}
+	tests := []struct {
+		name     string
+		readings []SensorData
+		method   Combine
+		expected SensorData
+	}{
+		{name: "Single", readings: []SensorData{north}, method: CombineAverage, expected: north},
+		{name: "Min", readings: []SensorData{north, south, stale}, method: CombineMin, expected: south},
+		{name: "Max", readings: []SensorData{north, south, stale}, method: CombineMax, expected: north},
+		{name: "AllStale", readings: []SensorData{stale}, method: CombineMax, expected: stale},
+		{name: "StaleIgnored", readings: []SensorData{stale, south}, method: CombineMax, expected: south},
+		{
+			name:     "Average",
+			readings: []SensorData{north, south, stale},
+			method:   CombineAverage,
+			expected: SensorData{
+				Temperature: 17.0,
+				Humidity:    65.0,
+				DewPoint:    utility.CalcDewPoint(17.0, 65.0),
+				AbsHumidity: utility.CalcAbsoluteHumidity(17.0, 65.0),
+				MixingRatio: utility.CalcMixingRatio(17.0, 65.0),
+				BatLevel:    2900,
+				RSSI:        -80,
+				Scanned:     now,
+			},
+		},
+		{
+			// the battery of the second sensor is unknown and must not hide the level of the first one
+			name: "AverageUnknownBattery",
+			readings: []SensorData{{Temperature: 18.0, Humidity: 70.0, BatLevel: 2900, BatPercent: 80, Scanned: now},
+				{Temperature: 16.0, Humidity: 60.0, Scanned: now}},
+			method: CombineAverage,
+			expected: SensorData{
+				Temperature: 17.0,
+				Humidity:    65.0,
+				DewPoint:    utility.CalcDewPoint(17.0, 65.0),
+				AbsHumidity: utility.CalcAbsoluteHumidity(17.0, 65.0),
+				MixingRatio: utility.CalcMixingRatio(17.0, 65.0),
+				BatLevel:    2900,
+				BatPercent:  80,
+				Scanned:     now,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CombineReadings(tt.readings, tt.method, now, MaxDataAge); got != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestUpdateRole(t *testing.T) {
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	z := NewZone("Cellar", 10)
+	z.Sensors.InsideCombine = CombineMax
+	z.Devices = []*Device{
+		NewDevice("North", "N", RoleInside, SensorCalibration{}, 10),
+		NewDevice("South", "S", RoleInside, SensorCalibration{}, 10),
+		NewDevice("Attic", "A", RoleMonitor, SensorCalibration{}, 10),
+	}
+	z.Devices[0].Data = SensorData{Name: "North", DewPoint: 9.0, Scanned: now}
+	z.UpdateRole(RoleInside, now)
+	z.Devices[1].Data = SensorData{Name: "South", DewPoint: 11.0, Scanned: now}
+	z.UpdateRole(RoleInside, now)
+	z.Devices[2].Data = SensorData{Name: "Attic", DewPoint: 15.0, Scanned: now}
+	z.UpdateRole(RoleMonitor, now)
+
+	if z.Sensors.InsideData.DewPoint != 11.0 || z.Sensors.InsideData.Name != "Inside" {
+		t.Errorf("expected the inside data of the south device, got %+v", z.Sensors.InsideData)
+	}
+	if z.Store.Inside.Size() != 2 || z.Store.Outside.Size() != 0 {
+		t.Errorf("expected 2 inside and 0 outside readings, got %d and %d", z.Store.Inside.Size(),
+			z.Store.Outside.Size())
+	}
+	if len(z.DevicesWithRole(RoleInside)) != 2 || len(z.DevicesWithRole(RoleReference)) != 0 {
+		t.Errorf("unexpected devices with role")
+	}
+}
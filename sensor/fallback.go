@@ -0,0 +1,29 @@
+package sensor
+
+import "time"
+
+// SelectReading returns the reading to be used for a sensor at the given time. A reading is current if it was
+// scanned within maxAge. If the primary reading isn't current, the backup sensor, the external source (if
+// enabled) and the last known primary reading (if it is younger than the hold time) are used, in this order.
+// Returns false if there is no usable reading, in which case the primary reading is returned.
+func SelectReading(primary, backup, external SensorData, cfg FallbackConfig, now time.Time,
+	maxAge time.Duration) (SensorData, Fallback, bool) {
+	if isCurrent(primary, now, maxAge) {
+		return primary, FallbackNone, true
+	}
+	if isCurrent(backup, now, maxAge) {
+		return backup, FallbackBackup, true
+	}
+	if cfg.UseExternal && isCurrent(external, now, maxAge) {
+		return external, FallbackExternal, true
+	}
+	if cfg.HoldTime > 0 && isCurrent(primary, now, cfg.HoldTime) {
+		return primary, FallbackLastValue, true
+	}
+	return primary, FallbackNone, false
+}
+
+// isCurrent checks if the reading was scanned within maxAge.
+func isCurrent(data SensorData, now time.Time, maxAge time.Duration) bool {
+	return !data.Scanned.IsZero() && now.Sub(data.Scanned) <= maxAge
+}
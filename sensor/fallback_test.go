@@ -0,0 +1,52 @@
+package sensor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSelectReading(t *testing.T) {
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	fresh := now.Add(-time.Minute)
+	stale := now.Add(-2 * time.Hour)
+	tests := []struct {
+		name             string
+		primary          time.Time
+		backup           time.Time
+		external         time.Time
+		cfg              FallbackConfig
+		expectedScanned  time.Time
+		expectedFallback Fallback
+		expectedOk       bool
+	}{
+		{name: "PrimaryIsCurrent", primary: fresh, backup: fresh, expectedScanned: fresh,
+			expectedFallback: FallbackNone, expectedOk: true},
+		{name: "NoData", expectedFallback: FallbackNone, expectedOk: false},
+		{name: "StaleWithoutFallback", primary: stale, expectedScanned: stale, expectedFallback: FallbackNone,
+			expectedOk: false},
+		{name: "Backup", primary: stale, backup: fresh, external: fresh, cfg: FallbackConfig{UseExternal: true},
+			expectedScanned: fresh, expectedFallback: FallbackBackup, expectedOk: true},
+		{name: "ExternalDisabled", primary: stale, external: fresh, expectedScanned: stale,
+			expectedFallback: FallbackNone, expectedOk: false},
+		{name: "External", primary: stale, backup: stale, external: fresh, cfg: FallbackConfig{UseExternal: true},
+			expectedScanned: fresh, expectedFallback: FallbackExternal, expectedOk: true},
+		{name: "LastValue", primary: stale, external: stale,
+			cfg:             FallbackConfig{UseExternal: true, HoldTime: 3 * time.Hour},
+			expectedScanned: stale, expectedFallback: FallbackLastValue, expectedOk: true},
+		{name: "LastValueTooOld", primary: stale, cfg: FallbackConfig{HoldTime: time.Hour},
+			expectedScanned: stale, expectedFallback: FallbackNone, expectedOk: false},
+		{name: "LastValueNeverReceived", cfg: FallbackConfig{HoldTime: time.Hour},
+			expectedFallback: FallbackNone, expectedOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, fallback, ok := SelectReading(SensorData{Scanned: tt.primary}, SensorData{Scanned: tt.backup},
+				SensorData{Scanned: tt.external}, tt.cfg, now, 5*time.Minute)
+			if !got.Scanned.Equal(tt.expectedScanned) || fallback != tt.expectedFallback || ok != tt.expectedOk {
+				t.Errorf("expected %v, %s, %v, got %v, %s, %v", tt.expectedScanned, FallbackName[tt.expectedFallback],
+					tt.expectedOk, got.Scanned, FallbackName[fallback], ok)
+			}
+		})
+	}
+}
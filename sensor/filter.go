@@ -0,0 +1,165 @@
+package sensor
+
+import (
+	"dpf-bt/utility"
+	"math"
+	"slices"
+	"time"
+)
+
+// hampelMinSigma is the lowest standard deviation in °C or % that the Hampel filter assumes. The sensors have a
+// resolution of 0.1, so a window of identical readings would otherwise reject every change.
+const hampelMinSigma = 0.2
+
+// hampelMinSamples is the number of raw readings the Hampel filter needs before it rejects readings.
+const hampelMinSamples = 3
+
+// maxRateRejections is the number of consecutive readings the rate limit rejects before it accepts the next one as
+// the new baseline. Otherwise, a corrupt reading that was accepted, e.g. before the Hampel filter has enough
+// readings, would block all correct readings until the maximum rate allows the difference.
+const maxRateRejections = 3
+
+// FilterConfig configures the filters of the readings of a sensor. A zero value disables the filter.
+type FilterConfig struct {
+	// HampelWindow is the number of last raw readings whose median is compared with a new reading.
+	HampelWindow int
+	// HampelThreshold is the number of standard deviations, estimated from the median absolute deviation, a
+	// reading may differ from the median.
+	HampelThreshold float64
+	// MaxTempRate is the largest change of the temperature in °C per minute compared to the last accepted reading.
+	MaxTempRate float64
+	// MaxHumRate is the largest change of the humidity in % per minute compared to the last accepted reading.
+	MaxHumRate float64
+	// EmaAlpha is the smoothing factor of the exponential moving average between 0 and 1. Smaller values smooth
+	// more, 0 and 1 disable the smoothing.
+	EmaAlpha float64
+}
+
+// FilterStats counts the readings that were rejected by the filters.
+type FilterStats struct {
+	Outliers    int
+	RateLimited int
+}
+
+// Rejected returns the number of all rejected readings.
+func (s FilterStats) Rejected() int {
+	return s.Outliers + s.RateLimited
+}
+
+// SetFilter sets the filters that are applied by AddFilteredSensorData. The history of the readings is kept.
+func (store *SensorDataList) SetFilter(filter FilterConfig) {
+	store.filter = filter
+}
+
+// Filter returns the filter configuration of the store.
+func (store *SensorDataList) Filter() FilterConfig {
+	return store.filter
+}
+
+// FilterStats returns the number of rejected readings.
+func (store *SensorDataList) FilterStats() FilterStats {
+	return store.stats
+}
+
+// AddFilteredSensorData passes the reading through the filters and adds it to the store if it isn't rejected.
+// A reading is rejected if the Hampel filter considers it an outlier or if it changes faster than the maximum
+// rate compared to the last accepted reading. After maxRateRejections consecutive rate rejections, the next
+// reading is accepted as the new baseline without smoothing. An accepted reading is smoothed with the exponential
+// moving average, unless the last accepted reading is outdated. It returns the added reading and whether it was
+// accepted.
+func (store *SensorDataList) AddFilteredSensorData(sensor SensorData) (SensorData, bool) {
+	outlier := store.isOutlier(sensor)
+	if store.filter.HampelWindow > 0 {
+		store.raw = append(store.raw, sensor)
+		if excess := len(store.raw) - store.filter.HampelWindow; excess > 0 {
+			store.raw = store.raw[excess:]
+		}
+	}
+	if outlier {
+		store.stats.Outliers++
+		return sensor, false
+	}
+	last, ok := store.Last()
+	if ok && store.exceedsRate(last, sensor) {
+		if store.rateRejections < maxRateRejections {
+			store.rateRejections++
+			store.stats.RateLimited++
+			return sensor, false
+		}
+		// the last accepted reading is probably corrupt, so this reading becomes the new baseline without smoothing
+		ok = false
+	}
+	store.rateRejections = 0
+	if alpha := store.filter.EmaAlpha; ok && alpha > 0 && alpha < 1 && sensor.Scanned.Sub(last.Scanned) <= MaxDataAge {
+		sensor.Temperature = utility.RoundDouble(alpha*sensor.Temperature+(1-alpha)*last.Temperature, 1)
+		sensor.Humidity = utility.RoundDouble(alpha*sensor.Humidity+(1-alpha)*last.Humidity, 1)
+		sensor = withDerivedValues(sensor)
+	}
+	store.AddSensorData(sensor)
+	return sensor, true
+}
+
+// Last returns the last entry of the store and whether there is one.
+func (store *SensorDataList) Last() (SensorData, bool) {
+	if len(store.data) == 0 {
+		return SensorData{}, false
+	}
+	return store.data[len(store.data)-1], true
+}
+
+// isOutlier checks the temperature and the humidity of the reading against the median of the last raw readings.
+func (store *SensorDataList) isOutlier(sensor SensorData) bool {
+	if store.filter.HampelWindow <= 0 || len(store.raw) < hampelMinSamples {
+		return false
+	}
+	return isHampelOutlier(store.raw, sensor.Temperature, store.filter.HampelThreshold,
+		func(s SensorData) float64 { return s.Temperature }) ||
+		isHampelOutlier(store.raw, sensor.Humidity, store.filter.HampelThreshold,
+			func(s SensorData) float64 { return s.Humidity })
+}
+
+// isHampelOutlier checks whether the value differs from the median of the window by more than threshold times the
+// standard deviation estimated from the median absolute deviation.
+func isHampelOutlier(window []SensorData, value, threshold float64, field func(SensorData) float64) bool {
+	values := make([]float64, len(window))
+	for i, s := range window {
+		values[i] = field(s)
+	}
+	m := median(values)
+	for i, v := range values {
+		values[i] = math.Abs(v - m)
+	}
+	// 1.4826 scales the median absolute deviation to the standard deviation of normally distributed values
+	sigma := max(1.4826*median(values), hampelMinSigma)
+	return math.Abs(value-m) > threshold*sigma
+}
+
+// exceedsRate checks whether the temperature or the humidity changed faster than the maximum rate since the last
+// accepted reading. Readings less than a second apart are treated as a second apart.
+func (store *SensorDataList) exceedsRate(last, sensor SensorData) bool {
+	minutes := max(sensor.Scanned.Sub(last.Scanned), time.Second).Minutes()
+	if rate := store.filter.MaxTempRate; rate > 0 && math.Abs(sensor.Temperature-last.Temperature) > rate*minutes {
+		return true
+	}
+	rate := store.filter.MaxHumRate
+	return rate > 0 && math.Abs(sensor.Humidity-last.Humidity) > rate*minutes
+}
+
+// median returns the median of the values. The slice is sorted in place.
+func median(values []float64) float64 {
+	slices.Sort(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+// withDerivedValues calculates the dew point, the absolute humidity and the mixing ratio of the reading from its
+// temperature and humidity.
+func withDerivedValues(sensor SensorData) SensorData {
+	sensor.DewPoint = utility.CalcDewPoint(sensor.Temperature, sensor.Humidity)
+	sensor.AbsHumidity = utility.CalcAbsoluteHumidity(sensor.Temperature, sensor.Humidity)
+	sensor.MixingRatio = utility.CalcMixingRatio(sensor.Temperature, sensor.Humidity)
+	return sensor
+}
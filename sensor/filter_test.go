@@ -0,0 +1,140 @@
+package sensor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAddFilteredSensorData(t *testing.T) {
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	type input struct {
+		offset      time.Duration
+		temperature float64
+		humidity    float64
+	}
+	steady := []input{
+		{0, 20.0, 60.0}, {10 * time.Second, 20.1, 60.2}, {20 * time.Second, 20.0, 60.1},
+		{30 * time.Second, 20.1, 60.0},
+	}
+	tests := []struct {
+		name                string
+		filter              FilterConfig
+		inputs              []input
+		expectedAccepted    []bool
+		expectedStats       FilterStats
+		expectedTemperature float64
+	}{
+		{
+			name:                "NoFilter",
+			inputs:              append(steady, input{40 * time.Second, 255.9, 60.0}),
+			expectedAccepted:    []bool{true, true, true, true, true},
+			expectedTemperature: 255.9,
+		},
+		{
+			name:                "HampelOutlier",
+			filter:              FilterConfig{HampelWindow: 5, HampelThreshold: 3},
+			inputs:              append(steady, input{40 * time.Second, 255.9, 60.0}, input{50 * time.Second, 20.2, 60.1}),
+			expectedAccepted:    []bool{true, true, true, true, false, true},
+			expectedStats:       FilterStats{Outliers: 1},
+			expectedTemperature: 20.2,
+		},
+		{
+			name:                "HampelHumidityOutlier",
+			filter:              FilterConfig{HampelWindow: 5, HampelThreshold: 3},
+			inputs:              append(steady, input{40 * time.Second, 20.1, 0}),
+			expectedAccepted:    []bool{true, true, true, true, false},
+			expectedStats:       FilterStats{Outliers: 1},
+			expectedTemperature: 20.1,
+		},
+		{
+			name:   "HampelFollowsLevelShift",
+			filter: FilterConfig{HampelWindow: 5, HampelThreshold: 3},
+			inputs: append(steady, input{40 * time.Second, 25.0, 60.0}, input{50 * time.Second, 25.0, 60.0},
+				input{60 * time.Second, 25.1, 60.0}, input{70 * time.Second, 25.0, 60.0}),
+			expectedAccepted:    []bool{true, true, true, true, false, false, false, true},
+			expectedStats:       FilterStats{Outliers: 3},
+			expectedTemperature: 25.0,
+		},
+		{
+			name:                "RateLimit",
+			filter:              FilterConfig{MaxTempRate: 2, MaxHumRate: 10},
+			inputs:              append(steady, input{40 * time.Second, 21.0, 60.0}, input{50 * time.Second, 20.3, 66}),
+			expectedAccepted:    []bool{true, true, true, true, false, false},
+			expectedStats:       FilterStats{RateLimited: 2},
+			expectedTemperature: 20.1,
+		},
+		{
+			name:   "RateLimitCorruptFirstReading",
+			filter: FilterConfig{HampelWindow: 5, HampelThreshold: 3, MaxTempRate: 2, MaxHumRate: 10, EmaAlpha: 0.5},
+			inputs: []input{{0, 255.9, 60.0}, {10 * time.Second, 20.0, 60.0}, {20 * time.Second, 20.1, 60.2},
+				{30 * time.Second, 20.0, 60.1}, {40 * time.Second, 20.1, 60.0}, {50 * time.Second, 20.0, 60.0}},
+			expectedAccepted:    []bool{true, false, false, false, true, true},
+			expectedStats:       FilterStats{RateLimited: 3},
+			expectedTemperature: 20.1,
+		},
+		{
+			name:                "RateLimitAfterGap",
+			filter:              FilterConfig{MaxTempRate: 2},
+			inputs:              append(steady, input{2 * time.Minute, 22.0, 60.0}),
+			expectedAccepted:    []bool{true, true, true, true, true},
+			expectedTemperature: 22.0,
+		},
+		{
+			name:                "ExponentialMovingAverage",
+			filter:              FilterConfig{EmaAlpha: 0.5},
+			inputs:              []input{{0, 20.0, 60.0}, {10 * time.Second, 21.0, 62.0}, {20 * time.Second, 21.0, 62.0}},
+			expectedAccepted:    []bool{true, true, true},
+			expectedTemperature: 20.8,
+		},
+		{
+			name:                "ExponentialMovingAverageResetsAfterGap",
+			filter:              FilterConfig{EmaAlpha: 0.5},
+			inputs:              []input{{0, 20.0, 60.0}, {10 * time.Minute, 22.0, 62.0}},
+			expectedAccepted:    []bool{true, true},
+			expectedTemperature: 22.0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewSensorDataStore(20)
+			store.SetFilter(tt.filter)
+			for i, in := range tt.inputs {
+				_, accepted := store.AddFilteredSensorData(withDerivedValues(SensorData{
+					Temperature: in.temperature,
+					Humidity:    in.humidity,
+					Scanned:     start.Add(in.offset),
+				}))
+				if accepted != tt.expectedAccepted[i] {
+					t.Errorf("reading %d: expected accepted %v, got %v", i, tt.expectedAccepted[i], accepted)
+				}
+			}
+			if store.FilterStats() != tt.expectedStats {
+				t.Errorf("expected stats %+v, got %+v", tt.expectedStats, store.FilterStats())
+			}
+			if last, _ := store.Last(); last.Temperature != tt.expectedTemperature {
+				t.Errorf("expected last temperature %.1f, got %.1f", tt.expectedTemperature, last.Temperature)
+			}
+		})
+	}
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []float64
+		expected float64
+	}{
+		{name: "single", values: []float64{3}, expected: 3},
+		{name: "odd", values: []float64{5, 1, 3}, expected: 3},
+		{name: "even", values: []float64{4, 1, 3, 2}, expected: 2.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := median(tt.values); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -1,11 +1,28 @@
 package sensor
 
-import "dpf-bt/utility"
+import (
+	"dpf-bt/utility"
+	"slices"
+	"time"
+)
 
-// SensorDataList struct manages a list of up to 20 SensorData entries.
+// minSampleInterval is the shortest average interval between two entries for which a store with a time window
+// keeps the whole window. The limit of the entries is derived from it, so that the combined stores of a zone,
+// which get an entry for every advertisement of each of its sensors, aren't cut short at the usual intervals.
+const minSampleInterval = time.Second
+
+// SensorDataList struct manages a list of up to 20 SensorData entries. With a time window, it keeps the entries of
+// the window instead and the averages are weighted by time. The optional filters keep the last raw readings and
+// count the rejected ones.
 type SensorDataList struct {
 	data          []SensorData
 	maxSensorData int
+	window        time.Duration
+	filter        FilterConfig
+	raw           []SensorData
+	stats         FilterStats
+	// rateRejections counts the consecutive readings rejected by the rate limit.
+	rateRejections int
 }
 
 // SensorStore struct manages collections of sensor data for inside and outside environments.
@@ -28,58 +45,204 @@ func NewSensorDataStore(maxData int) *SensorDataList {
 	}
 }
 
-// AddSensorData adds a new SensorData to the store. It removes the oldest entry if the limit is exceeded.
+// NewZone initializes a Zone with the given name and sensor data stores of the specified maximum capacity.
+func NewZone(name string, maxData int) *Zone {
+	return &Zone{
+		Name: name,
+		Store: SensorStore{
+			Inside:  *NewSensorDataStore(maxData),
+			Outside: *NewSensorDataStore(maxData),
+		},
+	}
+}
+
+// SetWindow sets the time span of the readings that are kept and averaged. A zero window keeps the maximum number
+// of entries of the store regardless of their age.
+func (store *SensorDataList) SetWindow(window time.Duration) {
+	store.window = window
+}
+
+// Window returns the time window of the store, which is zero for a store with a fixed number of entries.
+func (store *SensorDataList) Window() time.Duration {
+	return store.window
+}
+
+// AddSensorData adds a new SensorData to the store. It removes the oldest entry if the limit is exceeded. With a
+// time window, the entries that are older than the window before the new entry are removed as well.
 func (store *SensorDataList) AddSensorData(sensor SensorData) {
-	if len(store.data) >= store.maxSensorData {
+	limit := store.maxSensorData
+	if store.window > 0 {
+		limit = max(int(store.window/minSampleInterval), store.maxSensorData)
+		store.Prune(sensor.Scanned)
+	}
+	if len(store.data) >= limit {
 		// If the list exceeds the limit, remove the oldest entry
-		store.data = store.data[1:]
+		store.data = store.data[len(store.data)-limit+1:]
 	}
 	// Add the new sensor data to the list
 	store.data = append(store.data, sensor)
 }
 
-// AverageTemperature calculates the average temperature from all SensorData entries in the store.
-// Returns 0 if there are no SensorData entries.
-func (store *SensorDataList) AverageTemperature() float64 {
-	if len(store.data) == 0 {
-		return 0
+// Prune removes the entries that are older than the time window before now, so that the averages and slopes
+// describe the current window even if no readings arrive, e.g. after a sensor has stopped. A store without a time
+// window is left unchanged.
+func (store *SensorDataList) Prune(now time.Time) {
+	if store.window <= 0 {
+		return
+	}
+	start := now.Add(-store.window)
+	outdated := 0
+	for outdated < len(store.data) && store.data[outdated].Scanned.Before(start) {
+		outdated++
 	}
+	store.data = store.data[outdated:]
+}
 
-	var totalTemperature float64
-	for _, sensor := range store.data {
-		totalTemperature += sensor.Temperature
+// InsertSensorData adds older readings to the store in the order of their time. As with AddSensorData, the oldest
+// entries are removed if the limit is exceeded or if they are outside the time window before the newest entry.
+func (store *SensorDataList) InsertSensorData(readings []SensorData) {
+	data := append(slices.Clone(store.data), readings...)
+	slices.SortStableFunc(data, func(a, b SensorData) int {
+		return a.Scanned.Compare(b.Scanned)
+	})
+	store.data = store.data[:0]
+	for _, sensor := range data {
+		store.AddSensorData(sensor)
 	}
-	return utility.RoundDouble(totalTemperature/float64(len(store.data)), 1)
+}
+
+// AverageTemperature calculates the average temperature from all SensorData entries in the store.
+// Returns 0 if there are no SensorData entries.
+func (store *SensorDataList) AverageTemperature() float64 {
+	return store.average(func(sensor SensorData) float64 { return sensor.Temperature })
 }
 
 // AverageHumidity calculates the average humidity of all SensorData entries in the store.
 // Returns 0 if there are no SensorData entries.
 func (store *SensorDataList) AverageHumidity() float64 {
+	return store.average(func(sensor SensorData) float64 { return sensor.Humidity })
+}
+
+// AverageDewPoint calculates the average dew point of all SensorData in the store.
+// Returns 0 if there are no SensorData entries.
+func (store *SensorDataList) AverageDewPoint() float64 {
+	return store.average(func(sensor SensorData) float64 { return sensor.DewPoint })
+}
+
+// AverageAbsHumidity calculates the average absolute humidity in g/m³ of all SensorData in the store.
+// Returns 0 if there are no SensorData entries.
+func (store *SensorDataList) AverageAbsHumidity() float64 {
+	return store.average(func(sensor SensorData) float64 { return sensor.AbsHumidity })
+}
+
+// AverageMixingRatio calculates the average mixing ratio in g/kg of all SensorData in the store.
+// Returns 0 if there are no SensorData entries.
+func (store *SensorDataList) AverageMixingRatio() float64 {
+	return store.average(func(sensor SensorData) float64 { return sensor.MixingRatio })
+}
+
+// average calculates the mean of the given value over all SensorData entries, rounded to one decimal place.
+// With a time window, the mean is weighted by time. Returns 0 if there are no SensorData entries.
+func (store *SensorDataList) average(value func(SensorData) float64) float64 {
 	if len(store.data) == 0 {
 		return 0
 	}
-
-	var totalHumidity float64
+	if store.window > 0 {
+		if mean, ok := store.timeWeightedAverage(value); ok {
+			return utility.RoundDouble(mean, 1)
+		}
+	}
+	var total float64
 	for _, sensor := range store.data {
-		totalHumidity += sensor.Humidity
+		total += value(sensor)
 	}
-	return utility.RoundDouble(totalHumidity/float64(len(store.data)), 1)
+	return utility.RoundDouble(total/float64(len(store.data)), 1)
 }
 
-// AverageDewPoint calculates the average dew point of all SensorData in the store.
-// Returns 0 if there are no SensorData entries.
-func (store *SensorDataList) AverageDewPoint() float64 {
-	if len(store.data) == 0 {
+// timeWeightedAverage calculates the mean of the given value with the trapezoidal rule, so that every span of time
+// has the same weight regardless of how many readings were received in it. Gaps longer than MaxDataAge, in which
+// the sensor was considered stale, are left out. Returns false if the entries don't span any time.
+func (store *SensorDataList) timeWeightedAverage(value func(SensorData) float64) (float64, bool) {
+	var sum, total float64
+	for i := 1; i < len(store.data); i++ {
+		dt := store.data[i].Scanned.Sub(store.data[i-1].Scanned)
+		if dt <= 0 || dt > MaxDataAge {
+			continue
+		}
+		sum += (value(store.data[i-1]) + value(store.data[i])) / 2 * dt.Seconds()
+		total += dt.Seconds()
+	}
+	if total == 0 {
+		return 0, false
+	}
+	return sum / total, true
+}
+
+// Coverage returns the share of the time window before now that is covered by readings, between 0 and 1. The time
+// between two readings counts as covered unless it is longer than MaxDataAge; the time after the last reading
+// doesn't. Returns 0 for a store without a time window.
+func (store *SensorDataList) Coverage(now time.Time) float64 {
+	if store.window <= 0 {
 		return 0
 	}
+	start := now.Add(-store.window)
+	var covered time.Duration
+	for i := 1; i < len(store.data); i++ {
+		from, to := store.data[i-1].Scanned, store.data[i].Scanned
+		if to.Sub(from) > MaxDataAge || !to.After(start) {
+			continue
+		}
+		if from.Before(start) {
+			from = start
+		}
+		covered += to.Sub(from)
+	}
+	return min(covered.Seconds()/store.window.Seconds(), 1)
+}
+
+// DewPointSlope calculates the rate of change of the dew point in °C per hour.
+// Returns 0 if there are less than two SensorData entries or they have the same timestamp.
+func (store *SensorDataList) DewPointSlope() float64 {
+	return store.slope(func(sensor SensorData) float64 { return sensor.DewPoint })
+}
+
+// TemperatureSlope calculates the rate of change of the temperature in °C per hour.
+// Returns 0 if there are less than two SensorData entries or they have the same timestamp.
+func (store *SensorDataList) TemperatureSlope() float64 {
+	return store.slope(func(sensor SensorData) float64 { return sensor.Temperature })
+}
+
+// HumiditySlope calculates the rate of change of the humidity in % per hour.
+// Returns 0 if there are less than two SensorData entries or they have the same timestamp.
+func (store *SensorDataList) HumiditySlope() float64 {
+	return store.slope(func(sensor SensorData) float64 { return sensor.Humidity })
+}
 
-	var totalDewPoint float64
+// slope calculates the least squares regression slope of the given value over the scan time in units per hour.
+// Using the scan time instead of the sample index makes the result independent of the advertisement interval.
+func (store *SensorDataList) slope(value func(SensorData) float64) float64 {
+	if len(store.data) < 2 {
+		return 0
+	}
+	start := store.data[0].Scanned
+	var sumX, sumY, sumXY, sumXX float64
 	for _, sensor := range store.data {
-		totalDewPoint += sensor.DewPoint
+		x := sensor.Scanned.Sub(start).Hours()
+		y := value(sensor)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	n := float64(len(store.data))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0
 	}
-	return utility.RoundDouble(totalDewPoint/float64(len(store.data)), 1)
+	return utility.RoundDouble((n*sumXY-sumX*sumY)/denominator, 2)
 }
 
+// Size returns the number of SensorData entries in the store.
 func (store *SensorDataList) Size() int {
 	return len(store.data)
 }
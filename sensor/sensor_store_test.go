@@ -1,7 +1,9 @@
 package sensor
 
 import (
+	"math"
 	"testing"
+	"time"
 )
 
 func TestNewSensorDataStore(t *testing.T) {
@@ -283,3 +285,238 @@ func TestAverageDewPoint(t *testing.T) {
 		})
 	}
 }
+
+func TestDewPointSlope(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		data     []SensorData
+		expected float64
+	}{
+		{
+			name:     "no data",
+			data:     []SensorData{},
+			expected: 0,
+		},
+		{
+			name: "single entry",
+			data: []SensorData{
+				{DewPoint: 10.0, Scanned: start},
+			},
+			expected: 0,
+		},
+		{
+			name: "same timestamp",
+			data: []SensorData{
+				{DewPoint: 10.0, Scanned: start},
+				{DewPoint: 12.0, Scanned: start},
+			},
+			expected: 0,
+		},
+		{
+			name: "rising one degree per hour",
+			data: []SensorData{
+				{DewPoint: 10.0, Scanned: start},
+				{DewPoint: 10.5, Scanned: start.Add(30 * time.Minute)},
+				{DewPoint: 11.0, Scanned: start.Add(60 * time.Minute)},
+			},
+			expected: 1.0,
+		},
+		{
+			name: "falling with irregular intervals",
+			data: []SensorData{
+				{DewPoint: 12.0, Scanned: start},
+				{DewPoint: 11.9, Scanned: start.Add(3 * time.Minute)},
+				{DewPoint: 11.0, Scanned: start.Add(30 * time.Minute)},
+			},
+			expected: -2.0,
+		},
+		{
+			name: "constant",
+			data: []SensorData{
+				{DewPoint: 8.0, Scanned: start},
+				{DewPoint: 8.0, Scanned: start.Add(time.Minute)},
+				{DewPoint: 8.0, Scanned: start.Add(2 * time.Minute)},
+			},
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &SensorDataList{
+				data: tt.data,
+			}
+			result := store.DewPointSlope()
+			if result != tt.expected {
+				t.Errorf("expected %.2f, got %.2f", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestTemperatureAndHumiditySlope(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := &SensorDataList{
+		data: []SensorData{
+			{Temperature: 20.0, Humidity: 60.0, Scanned: start},
+			{Temperature: 21.0, Humidity: 58.0, Scanned: start.Add(2 * time.Hour)},
+		},
+	}
+	if result := store.TemperatureSlope(); result != 0.5 {
+		t.Errorf("expected temperature slope 0.50, got %.2f", result)
+	}
+	if result := store.HumiditySlope(); result != -1.0 {
+		t.Errorf("expected humidity slope -1.00, got %.2f", result)
+	}
+}
+
+func TestTimeWindow(t *testing.T) {
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	type input struct {
+		offset      time.Duration
+		temperature float64
+	}
+	tests := []struct {
+		name             string
+		inputs           []input
+		now              time.Duration
+		expectedSize     int
+		expectedAverage  float64
+		expectedCoverage float64
+	}{
+		{
+			name:             "single reading",
+			inputs:           []input{{0, 20}},
+			now:              time.Minute,
+			expectedSize:     1,
+			expectedAverage:  20,
+			expectedCoverage: 0,
+		},
+		{
+			// a burst of readings at 24°C must not outweigh the 8 minutes at 20°C
+			name: "burst is weighted by time",
+			inputs: []input{{0, 20}, {4 * time.Minute, 20}, {8 * time.Minute, 20}, {8*time.Minute + 10*time.Second, 24},
+				{8*time.Minute + 20*time.Second, 24}, {8*time.Minute + 30*time.Second, 24},
+				{8*time.Minute + 40*time.Second, 24}},
+			now:              10 * time.Minute,
+			expectedSize:     7,
+			expectedAverage:  20.3,
+			expectedCoverage: 0.866,
+		},
+		{
+			name:             "old readings leave the window",
+			inputs:           []input{{0, 10}, {5 * time.Minute, 20}, {12 * time.Minute, 22}, {14 * time.Minute, 24}},
+			now:              14 * time.Minute,
+			expectedSize:     3,
+			expectedAverage:  23,
+			expectedCoverage: 0.2,
+		},
+		{
+			// the sensor stopped 8 minutes ago, so only its last two readings are within the window
+			name:             "readings leave the window without new ones",
+			inputs:           []input{{0, 10}, {2 * time.Minute, 20}, {4 * time.Minute, 22}, {6 * time.Minute, 24}},
+			now:              14 * time.Minute,
+			expectedSize:     2,
+			expectedAverage:  23,
+			expectedCoverage: 0.2,
+		},
+		{
+			name:             "long gap is left out",
+			inputs:           []input{{0, 10}, {time.Minute, 10}, {7 * time.Minute, 30}, {9 * time.Minute, 30}},
+			now:              10 * time.Minute,
+			expectedSize:     4,
+			expectedAverage:  23.3,
+			expectedCoverage: 0.3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewSensorDataStore(5)
+			store.SetWindow(10 * time.Minute)
+			for _, in := range tt.inputs {
+				store.AddSensorData(SensorData{Temperature: in.temperature, Scanned: start.Add(in.offset)})
+			}
+			store.Prune(start.Add(tt.now))
+			if store.Size() != tt.expectedSize {
+				t.Errorf("expected size %d, got %d", tt.expectedSize, store.Size())
+			}
+			if got := store.AverageTemperature(); got != tt.expectedAverage {
+				t.Errorf("expected average %.1f, got %.1f", tt.expectedAverage, got)
+			}
+			if got := store.Coverage(start.Add(tt.now)); math.Abs(got-tt.expectedCoverage) > 0.001 {
+				t.Errorf("expected coverage %.3f, got %.3f", tt.expectedCoverage, got)
+			}
+		})
+	}
+}
+
+func TestTimeWindowLimit(t *testing.T) {
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	store := NewSensorDataStore(5)
+	store.SetWindow(10 * time.Minute)
+	// a combined store of a zone with several sensors gets an entry every second
+	for i := range 900 {
+		store.AddSensorData(SensorData{Temperature: 20, Scanned: start.Add(time.Duration(i) * time.Second)})
+	}
+	if store.Size() != 600 {
+		t.Errorf("expected the 600 entries of the window, got %d", store.Size())
+	}
+	if covered := store.Coverage(start.Add(15 * time.Minute)); covered < 0.99 {
+		t.Errorf("expected the whole window to be covered, got %.3f", covered)
+	}
+}
+
+func TestInsertSensorData(t *testing.T) {
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	reading := func(minutes int, temperature float64) SensorData {
+		return SensorData{Temperature: temperature, Scanned: start.Add(time.Duration(minutes) * time.Minute)}
+	}
+	tests := []struct {
+		name          string
+		window        time.Duration
+		existing      []SensorData
+		inserted      []SensorData
+		expectedTemps []float64
+	}{
+		{
+			name:          "ordered by time",
+			existing:      []SensorData{reading(30, 23), reading(31, 24)},
+			inserted:      []SensorData{reading(10, 21), reading(0, 20), reading(20, 22)},
+			expectedTemps: []float64{20, 21, 22, 23, 24},
+		},
+		{
+			name:          "limit keeps the newest",
+			existing:      []SensorData{reading(60, 26), reading(61, 27), reading(62, 28), reading(63, 29)},
+			inserted:      []SensorData{reading(0, 20), reading(10, 21), reading(20, 22)},
+			expectedTemps: []float64{21, 22, 26, 27, 28, 29},
+		},
+		{
+			name:          "window before the newest entry",
+			window:        30 * time.Minute,
+			existing:      []SensorData{reading(60, 26)},
+			inserted:      []SensorData{reading(20, 22), reading(40, 24), reading(50, 25)},
+			expectedTemps: []float64{24, 25, 26},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewSensorDataStore(6)
+			store.SetWindow(tt.window)
+			for _, data := range tt.existing {
+				store.AddSensorData(data)
+			}
+			store.InsertSensorData(tt.inserted)
+			if store.Size() != len(tt.expectedTemps) {
+				t.Fatalf("expected %d entries, got %d", len(tt.expectedTemps), store.Size())
+			}
+			for i, expected := range tt.expectedTemps {
+				if store.data[i].Temperature != expected {
+					t.Errorf("entry %d: expected %.1f, got %.1f", i, expected, store.data[i].Temperature)
+				}
+			}
+		})
+	}
+}
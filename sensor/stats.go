@@ -0,0 +1,169 @@
+package sensor
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	// statsWindow is the time span of the recent advertisements the packet rate and the packet loss are
+	// calculated from.
+	statsWindow = 10 * time.Minute
+	// maxRecentPackets limits the number of recent advertisements that are kept for statsWindow.
+	maxRecentPackets = 2000
+	// maxStatsGaps is the number of last gaps between advertisements the expected interval is estimated from.
+	maxStatsGaps = 100
+)
+
+// GapBuckets are the upper limits of the buckets of the gap histogram. The last bucket of the histogram counts
+// the longer gaps.
+var GapBuckets = []time.Duration{2 * time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute}
+
+// LinkStats collects statistics of the advertisements received from a sensor: the number of packets, the gaps
+// between them, the signal strength and the reboots of the sensor, which are detected by a decreasing uptime.
+type LinkStats struct {
+	Packets      int
+	Reboots      int
+	LastReboot   time.Time
+	RSSIMin      int16
+	RSSIMax      int16
+	GapHistogram []int
+	rssiSum      int64
+	first        time.Time
+	last         time.Time
+	lastUptime   uint32
+	recent       []time.Time
+	gaps         []time.Duration
+}
+
+// Add records an advertisement with its signal strength and the uptime of the sensor, which is 0 if the sensor
+// doesn't report it. It returns true if the uptime went backwards, i.e. the sensor has rebooted.
+func (s *LinkStats) Add(rssi int16, uptime uint32, now time.Time) bool {
+	if s.Packets == 0 {
+		s.first = now
+		s.RSSIMin, s.RSSIMax = rssi, rssi
+		s.GapHistogram = make([]int, len(GapBuckets)+1)
+	} else {
+		gap := now.Sub(s.last)
+		s.GapHistogram[gapBucket(gap)]++
+		if gap > 0 {
+			if len(s.gaps) >= maxStatsGaps {
+				s.gaps = s.gaps[1:]
+			}
+			s.gaps = append(s.gaps, gap)
+		}
+	}
+	s.Packets++
+	s.RSSIMin = min(s.RSSIMin, rssi)
+	s.RSSIMax = max(s.RSSIMax, rssi)
+	s.rssiSum += int64(rssi)
+	s.last = now
+	s.recent = append(s.recent, now)
+	s.pruneRecent(now)
+
+	rebooted := uptime > 0 && uptime < s.lastUptime
+	if rebooted {
+		s.Reboots++
+		s.LastReboot = now
+	}
+	if uptime > 0 {
+		s.lastUptime = uptime
+	}
+	return rebooted
+}
+
+// RSSIAverage returns the average signal strength of all advertisements, 0 without advertisements.
+func (s *LinkStats) RSSIAverage() float64 {
+	if s.Packets == 0 {
+		return 0
+	}
+	return float64(s.rssiSum) / float64(s.Packets)
+}
+
+// PacketsPerMinute returns the rate of the advertisements received within the last 10 minutes, or since the
+// first advertisement if the sensor has been seen for a shorter time.
+func (s *LinkStats) PacketsPerMinute(now time.Time) float64 {
+	return float64(s.recentPackets(now)) / s.recentSpan(now).Minutes()
+}
+
+// ExpectedInterval returns the interval the sensor advertises with. It is estimated as the median of the last
+// gaps, which isn't affected by a few lost advertisements. It returns false until two advertisements are received.
+func (s *LinkStats) ExpectedInterval() (time.Duration, bool) {
+	if len(s.gaps) == 0 {
+		return 0, false
+	}
+	gaps := slices.Clone(s.gaps)
+	slices.Sort(gaps)
+	n := len(gaps)
+	if n%2 == 1 {
+		return gaps[n/2], true
+	}
+	return (gaps[n/2-1] + gaps[n/2]) / 2, true
+}
+
+// ObservedInterval returns the average of the last gaps between the advertisements. It returns false until two
+// advertisements are received.
+func (s *LinkStats) ObservedInterval() (time.Duration, bool) {
+	if len(s.gaps) == 0 {
+		return 0, false
+	}
+	var sum time.Duration
+	for _, gap := range s.gaps {
+		sum += gap
+	}
+	return sum / time.Duration(len(s.gaps)), true
+}
+
+// PacketLoss returns the share of the advertisements within the last 10 minutes that were expected from the
+// expected interval but not received, between 0 and 1. It returns false without an expected interval.
+func (s *LinkStats) PacketLoss(now time.Time) (float64, bool) {
+	interval, ok := s.ExpectedInterval()
+	if !ok {
+		return 0, false
+	}
+	expected := float64(s.recentSpan(now)) / float64(interval)
+	if expected < 1 {
+		return 0, true
+	}
+	return max(0, 1-float64(s.recentPackets(now))/expected), true
+}
+
+// recentPackets returns the number of advertisements within the last 10 minutes.
+func (s *LinkStats) recentPackets(now time.Time) int {
+	start := now.Add(-statsWindow)
+	count := 0
+	for _, t := range s.recent {
+		if t.After(start) {
+			count++
+		}
+	}
+	return count
+}
+
+// recentSpan returns the time span the recent advertisements are counted in: the last 10 minutes, or the time
+// since the first advertisement, but at least a minute.
+func (s *LinkStats) recentSpan(now time.Time) time.Duration {
+	return max(min(statsWindow, now.Sub(s.first)), time.Minute)
+}
+
+// pruneRecent drops the recent advertisements that are older than statsWindow.
+func (s *LinkStats) pruneRecent(now time.Time) {
+	start := now.Add(-statsWindow)
+	i := 0
+	for i < len(s.recent) && !s.recent[i].After(start) {
+		i++
+	}
+	i = max(i, len(s.recent)-maxRecentPackets)
+	s.recent = s.recent[i:]
+}
+
+// gapBucket returns the index of the bucket of the gap histogram.
+func gapBucket(gap time.Duration) int {
+	for i, limit := range GapBuckets {
+		if gap <= limit {
+			return i
+		}
+	}
+	return len(GapBuckets)
+}
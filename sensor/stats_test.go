@@ -0,0 +1,92 @@
+package sensor
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLinkStats(t *testing.T) {
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	var stats LinkStats
+	if _, ok := stats.ExpectedInterval(); ok {
+		t.Errorf("expected no interval without advertisements")
+	}
+
+	// a sensor advertising every 10 seconds, every fourth advertisement is lost
+	for i := range 60 {
+		if i%4 == 3 {
+			continue
+		}
+		if stats.Add(int16(-60-i%3), uint32(1000+10*i), start.Add(time.Duration(i)*10*time.Second)) {
+			t.Errorf("unexpected reboot at advertisement %d", i)
+		}
+	}
+	now := start.Add(595 * time.Second)
+
+	if stats.Packets != 45 || stats.RSSIMin != -62 || stats.RSSIMax != -60 {
+		t.Errorf("unexpected packets %d and RSSI %d..%d", stats.Packets, stats.RSSIMin, stats.RSSIMax)
+	}
+	if avg := stats.RSSIAverage(); math.Abs(avg+61) > 0.1 {
+		t.Errorf("expected RSSI average of about -61, got %.2f", avg)
+	}
+	if interval, ok := stats.ExpectedInterval(); !ok || interval != 10*time.Second {
+		t.Errorf("expected interval 10s, got %s", interval)
+	}
+	if interval, ok := stats.ObservedInterval(); !ok || interval != 580*time.Second/44 {
+		t.Errorf("expected observed interval %s, got %s", 580*time.Second/44, interval)
+	}
+	if rate := stats.PacketsPerMinute(now); math.Abs(rate-45/(595.0/60)) > 1e-9 {
+		t.Errorf("expected %.2f packets per minute, got %.2f", 45/(595.0/60), rate)
+	}
+	if loss, ok := stats.PacketLoss(now); !ok || math.Abs(loss-(1-45/59.5)) > 1e-9 {
+		t.Errorf("expected packet loss %.3f, got %.3f", 1-45/59.5, loss)
+	}
+	expectedHistogram := []int{0, 0, 30, 14, 0, 0, 0}
+	if !slices.Equal(stats.GapHistogram, expectedHistogram) {
+		t.Errorf("expected gap histogram %v, got %v", expectedHistogram, stats.GapHistogram)
+	}
+
+	// the sensor stops advertising, so the rate drops with the packets leaving the window
+	if rate := stats.PacketsPerMinute(start.Add(15 * time.Minute)); math.Abs(rate-2.1) > 1e-9 {
+		t.Errorf("expected 2.1 packets per minute, got %.2f", rate)
+	}
+	if loss, _ := stats.PacketLoss(start.Add(30 * time.Minute)); loss != 1 {
+		t.Errorf("expected a packet loss of 100%%, got %.3f", loss)
+	}
+
+	// a decreasing uptime is a reboot, an unknown uptime isn't
+	rebootTime := start.Add(20 * time.Minute)
+	if !stats.Add(-60, 5, rebootTime) || stats.Reboots != 1 || !stats.LastReboot.Equal(rebootTime) {
+		t.Errorf("expected a reboot, got %d reboots", stats.Reboots)
+	}
+	if stats.Add(-60, 0, rebootTime.Add(10*time.Second)) || stats.Add(-60, 25, rebootTime.Add(20*time.Second)) {
+		t.Errorf("unexpected reboot, got %d reboots", stats.Reboots)
+	}
+	if stats.GapHistogram[len(GapBuckets)] != 1 {
+		t.Errorf("expected the long gap in the last bucket, got %v", stats.GapHistogram)
+	}
+}
+
+func TestGapBucket(t *testing.T) {
+	tests := []struct {
+		name     string
+		gap      time.Duration
+		expected int
+	}{
+		{name: "Duplicate", gap: 0, expected: 0},
+		{name: "Limit", gap: 2 * time.Second, expected: 0},
+		{name: "AboveLimit", gap: 2100 * time.Millisecond, expected: 1},
+		{name: "Minute", gap: 45 * time.Second, expected: 4},
+		{name: "Longer", gap: time.Hour, expected: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gapBucket(tt.gap); got != tt.expected {
+				t.Errorf("expected bucket %d for %s, got %d", tt.expected, tt.gap, got)
+			}
+		})
+	}
+}
@@ -1,41 +1,85 @@
 package sensor
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 // SensorData represents data collected from a sensor, including environmental measurements and metadata.
+// BatLevel is the battery voltage in millivolts and BatPercent the remaining capacity, 0 if unknown.
 type SensorData struct {
 	MacAddress  string
 	Name        string
 	BatLevel    uint16
+	BatPercent  int
 	RSSI        int16
 	Uptime      uint32
 	Temperature float64
 	Humidity    float64
 	DewPoint    float64
+	AbsHumidity float64
+	MixingRatio float64
 	Scanned     time.Time
 }
 
 // SensorCalibration represents calibration data for sensors, including adjustments for temperature and humidity.
+// Temperature and Humidity are the offsets that are added after the raw value has been multiplied by the gain.
+// A gain of 0 is treated as 1, so that a calibration with offsets only needs no gains.
 type SensorCalibration struct {
-	Temperature float64
-	Humidity    float64
+	Temperature     float64
+	Humidity        float64
+	TemperatureGain float64
+	HumidityGain    float64
 }
 
-// Sensors represent a collection of sensor data and calibration for both inside and outside environments.
+// Sensors represent a collection of sensor data for both inside and outside environments. InsideData and
+// OutsideData are combined from the devices of the zone with the inside and outside role. The backup sensors,
+// the values of an external source and the fallback configuration are used when the primary sensor is stale.
 type Sensors struct {
-	InsideData         SensorData
-	InsideCalibration  SensorCalibration
-	OutsideData        SensorData
-	OutsideCalibration SensorCalibration
+	InsideData               SensorData
+	InsideCombine            Combine
+	OutsideData              SensorData
+	OutsideCombine           Combine
+	InsideBackup             SensorData
+	InsideBackupCalibration  SensorCalibration
+	OutsideBackup            SensorData
+	OutsideBackupCalibration SensorCalibration
+	InsideExternal           SensorData
+	OutsideExternal          SensorData
+	InsideFallback           FallbackConfig
+	OutsideFallback          FallbackConfig
+}
+
+// Zone represents a ventilation zone with its sensor devices, the combined inside and outside data and the
+// history of their readings. Several zones can share the same outside sensor. The mutex serializes the changes of
+// the Bluetooth scanner, the fan control and a reload of the configuration.
+type Zone struct {
+	sync.Mutex
+	Name    string
+	Devices []*Device
+	Sensors Sensors
+	Store   SensorStore
 }
 
 // FanConfig is a configuration structure for controlling fan behavior based on environmental parameters and thresholds.
 type FanConfig struct {
+	Controller        string
 	MinDiff           float64
 	Hysteresis        float64
 	MinHumidityInside float64
 	MinTempInside     float64
 	MinTempOutside    float64
+	MinTrend          float64
+	MinAbsDiff        float64
+	AbsHysteresis     float64
+	MinOnMinutes      int
+	MinOffMinutes     int
+	MinSpeed          int
+	FullSpeedDiff     float64
+	MoldIndexLimit    float64
+	MoldMinDiff       float64
+	// FaultSeconds is the time the sensed fan state may differ from the commanded state before a fault is raised.
+	FaultSeconds int
 }
 
 // Reason represents a categorized outcome or state as an integer constant.
@@ -77,6 +121,33 @@ const (
 
 	// ReasonUnknown indicates an undefined or unknown reason.
 	ReasonUnknown
+
+	// ReasonDewPointRising indicates the dew point is within the hysteresis range and the difference is rising.
+	ReasonDewPointRising
+
+	// ReasonDewPointFalling indicates the dew point is within the hysteresis range and the difference is falling.
+	ReasonDewPointFalling
+
+	// ReasonAbsHumidityOverHyst indicates the absolute humidity difference is over the hysteresis limit.
+	ReasonAbsHumidityOverHyst
+
+	// ReasonAbsHumidityUnderHyst indicates the absolute humidity difference is under the hysteresis limit.
+	ReasonAbsHumidityUnderHyst
+
+	// ReasonAbsHumidityInBetween indicates the absolute humidity difference is within the hysteresis range.
+	ReasonAbsHumidityInBetween
+
+	// ReasonMinRunTime indicates the fan is kept on until the minimum run time has elapsed.
+	ReasonMinRunTime
+
+	// ReasonMinPauseTime indicates the fan is kept off until the minimum pause time has elapsed.
+	ReasonMinPauseTime
+
+	// ReasonBlockedBySchedule indicates the fan is blocked by a forbidden window of the schedule.
+	ReasonBlockedBySchedule
+
+	// ReasonRule indicates the fan state is set by a rule of the configuration, see ResultData.RuleReason.
+	ReasonRule
 )
 
 // ReasonName maps Reason constants to their corresponding string representations for descriptive purposes.
@@ -93,14 +164,137 @@ var ReasonName = map[Reason]string{
 	ReasonSoftOverrideOn:       "soft override on",
 	ReasonSoftOverrideOff:      "soft override off",
 	ReasonUnknown:              "unknown reason",
+	ReasonDewPointRising:       "dp rising",
+	ReasonDewPointFalling:      "dp falling",
+	ReasonAbsHumidityOverHyst:  "ah > hysteresis",
+	ReasonAbsHumidityUnderHyst: "ah < hysteresis",
+	ReasonAbsHumidityInBetween: "ah in between",
+	ReasonMinRunTime:           "hold min run time",
+	ReasonMinPauseTime:         "hold min pause",
+	ReasonBlockedBySchedule:    "blocked by schedule",
+	ReasonRule:                 "rule",
+}
+
+// Fallback describes the replacement that is used for a stale sensor. The values are used by the REST API,
+// so new values must be appended at the end.
+type Fallback int
+
+const (
+	// FallbackNone indicates the primary sensor is used.
+	FallbackNone Fallback = iota
+	// FallbackBackup indicates the backup sensor is used.
+	FallbackBackup
+	// FallbackExternal indicates the value of an external source is used.
+	FallbackExternal
+	// FallbackLastValue indicates the last known value of the primary sensor is used.
+	FallbackLastValue
+)
+
+// FallbackName maps Fallback constants to their corresponding string representations.
+var FallbackName = map[Fallback]string{
+	FallbackNone:      "none",
+	FallbackBackup:    "backup",
+	FallbackExternal:  "external",
+	FallbackLastValue: "last value",
+}
+
+// FallbackConfig defines the replacements for a stale sensor besides a backup sensor. UseExternal allows values
+// of an external source and HoldTime is how long the last known value of the sensor may be used.
+type FallbackConfig struct {
+	UseExternal bool
+	HoldTime    time.Duration
+}
+
+// SwitchPosition is the position of the on-off-on switch that overrides the fan state in hardware.
+type SwitchPosition int
+
+const (
+	// SwitchUnknown indicates the switch position isn't sensed.
+	SwitchUnknown SwitchPosition = iota
+	// SwitchAuto indicates the middle position, where the fan controller determines the fan state.
+	SwitchAuto
+	// SwitchOn indicates the fan is forced on.
+	SwitchOn
+	// SwitchOff indicates the fan is forced off.
+	SwitchOff
+)
+
+// SwitchPositionName maps SwitchPosition constants to their corresponding string representations.
+var SwitchPositionName = map[SwitchPosition]string{
+	SwitchUnknown: "unknown",
+	SwitchAuto:    "auto",
+	SwitchOn:      "on",
+	SwitchOff:     "off",
+}
+
+// Fault classifies a disagreement between the commanded and the sensed fan state. The values are used by the
+// REST API, so new values must be appended.
+type Fault int
+
+const (
+	// FaultNone indicates the fan follows the commanded state.
+	FaultNone Fault = iota
+	// FaultHardwareOverride indicates the fan state is forced by the switch.
+	FaultHardwareOverride
+	// FaultRelay indicates the fan is powered although it should be off, e.g. by a stuck relay.
+	FaultRelay
+	// FaultFan indicates the fan has no power although it should be on, e.g. due to a blown fuse.
+	FaultFan
+)
+
+// FaultName maps Fault constants to their corresponding string representations.
+var FaultName = map[Fault]string{
+	FaultNone:             "none",
+	FaultHardwareOverride: "hardware override",
+	FaultRelay:            "relay fault",
+	FaultFan:              "fan fault",
+}
+
+// IsFault returns true for a hardware fault. A hardware override is deliberate and not a fault.
+func (f Fault) IsFault() bool {
+	return f == FaultRelay || f == FaultFan
 }
 
 // ResultData represents the computational output for fan control based on sensor data and configuration thresholds.
+// Speed is the fan speed in percent while the fan is on, 0 means full speed. OverrideRemaining is the remaining
+// time of a timed remote override and 0 if no timed override is active. RuleReason is the custom reason of the
+// rule that decided, if Reason is ReasonRule. InsideFallback and OutsideFallback flag the degraded operation with
+// a replacement for a stale sensor. Switch is the sensed position of the override switch and Fault the
+// classification of a disagreement between ShouldBeOn and IsOn.
 type ResultData struct {
-	DpDiff     float64
-	ShouldBeOn bool
-	IsOn       bool
-	Reason     Reason
+	DpDiff            float64
+	DpTrend           float64
+	AhDiff            float64
+	ShouldBeOn        bool
+	IsOn              bool
+	Reason            Reason
+	Speed             int
+	OverrideRemaining time.Duration
+	RuleReason        string
+	InsideFallback    Fallback
+	OutsideFallback   Fallback
+	Switch            SwitchPosition
+	Fault             Fault
+}
+
+// ReasonText returns the text of the reason. For ReasonRule, it is the custom reason of the rule.
+func (r ResultData) ReasonText() string {
+	if r.Reason == ReasonRule && r.RuleReason != "" {
+		return r.RuleReason
+	}
+	return ReasonName[r.Reason]
+}
+
+// FanSpeed returns the speed in percent the fan should run with. It is 0 if the fan should be off and 100 if
+// the controller doesn't calculate a speed.
+func (r ResultData) FanSpeed() int {
+	if !r.ShouldBeOn {
+		return 0
+	}
+	if r.Speed <= 0 {
+		return 100
+	}
+	return r.Speed
 }
 
 // InfluxDbConfig represents the configuration settings for connecting to an InfluxDB instance.
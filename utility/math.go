@@ -2,6 +2,15 @@ package utility
 
 import "math"
 
+const (
+	// molarMassWater is the molar mass of water vapor in g/mol.
+	molarMassWater = 18.016
+	// gasConstant is the universal gas constant in J/(kmol*K).
+	gasConstant = 8314.3
+	// standardPressure is the standard atmospheric pressure at sea level in hPa.
+	standardPressure = 1013.25
+)
+
 // CalcDewPoint calculates the dew point temperature (°C) based on the given temperature (°C) and relative humidity (%).
 // It uses the Magnus formula with coefficients adjusted for temperature above or below 0°C.
 // Returns the calculated dew point temperature as a float64.
@@ -29,6 +38,34 @@ func CalcDewPoint(temperature, humidity float64) float64 {
 	return RoundDouble((b*v)/(a-v), 1)
 }
 
+// CalcAbsoluteHumidity calculates the absolute humidity (g/m³) based on the given temperature (°C) and
+// relative humidity (%). It uses the vapor pressure from the Magnus formula and the ideal gas law.
+func CalcAbsoluteHumidity(temperature, humidity float64) float64 {
+	// vapor pressure in hPa
+	dd := vaporPressure(temperature, humidity)
+	// absolute humidity = 10^5 * mw / R* * dd / TK with mw = 18.016 g/mol and R* = 8314.3 J/(kmol*K)
+	return RoundDouble(1e5*molarMassWater/gasConstant*dd/(temperature+273.15), 1)
+}
+
+// CalcMixingRatio calculates the mixing ratio (g water per kg dry air) based on the given temperature (°C) and
+// relative humidity (%). The standard atmospheric pressure is used, since the sensors don't measure the pressure.
+func CalcMixingRatio(temperature, humidity float64) float64 {
+	// vapor pressure in hPa
+	dd := vaporPressure(temperature, humidity)
+	// 622 is the ratio of the molar masses of water and dry air in g/kg
+	return RoundDouble(622*dd/(standardPressure-dd), 1)
+}
+
+// vaporPressure calculates the vapor pressure (hPa) based on the given temperature (°C) and relative humidity (%)
+// with the Magnus formula.
+func vaporPressure(temperature, humidity float64) float64 {
+	a, b := 7.5, 237.3
+	if temperature < 0 {
+		a, b = 7.6, 240.7
+	}
+	return 6.1078 * math.Pow(10, (a*temperature)/(b+temperature)) * (humidity / 100)
+}
+
 // RoundDouble rounds a float64 value to the specified number of decimal places based on the given precision.
 func RoundDouble(val float64, precision uint) float64 {
 	ratio := math.Pow(10, float64(precision))
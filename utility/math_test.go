@@ -159,3 +159,53 @@ func TestRoundDouble(t *testing.T) {
 		})
 	}
 }
+
+func TestCalcAbsoluteHumidity(t *testing.T) {
+	tests := []struct {
+		name        string
+		temperature float64
+		humidity    float64
+		expected    float64
+	}{
+		{name: "Room climate", temperature: 20.0, humidity: 50.0, expected: 8.6},
+		{name: "Warm and humid", temperature: 30.0, humidity: 80.0, expected: 24.3},
+		{name: "Cool cellar", temperature: 12.0, humidity: 75.0, expected: 8.0},
+		{name: "Below freezing", temperature: -5.0, humidity: 90.0, expected: 3.1},
+		{name: "Zero humidity", temperature: 20.0, humidity: 0.0, expected: 0.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := CalcAbsoluteHumidity(tt.temperature, tt.humidity)
+			if result != tt.expected {
+				t.Errorf("CalcAbsoluteHumidity(%v, %v) = %v, expected %v",
+					tt.temperature, tt.humidity, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCalcMixingRatio(t *testing.T) {
+	tests := []struct {
+		name        string
+		temperature float64
+		humidity    float64
+		expected    float64
+	}{
+		{name: "Room climate", temperature: 20.0, humidity: 50.0, expected: 7.3},
+		{name: "Warm and humid", temperature: 30.0, humidity: 80.0, expected: 21.6},
+		{name: "Cool cellar", temperature: 12.0, humidity: 75.0, expected: 6.5},
+		{name: "Below freezing", temperature: -5.0, humidity: 90.0, expected: 2.3},
+		{name: "Zero humidity", temperature: 20.0, humidity: 0.0, expected: 0.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := CalcMixingRatio(tt.temperature, tt.humidity)
+			if result != tt.expected {
+				t.Errorf("CalcMixingRatio(%v, %v) = %v, expected %v",
+					tt.temperature, tt.humidity, result, tt.expected)
+			}
+		})
+	}
+}